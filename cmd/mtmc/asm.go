@@ -0,0 +1,82 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"github.com/catdevman/go-mtmc/internal/assembler"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// asmMain implements "mtmc asm [-o out.bin] [file.asm]", which assembles a
+// source file, or stdin if none is given, into a flat binary for loading at
+// address 0. It returns the process exit status.
+func asmMain(args []string, stdin io.Reader, stdout, stderr io.Writer) int {
+	fs := flag.NewFlagSet("asm", flag.ContinueOnError)
+	fs.SetOutput(stderr)
+	fs.Usage = func() {
+		fmt.Fprintln(stderr, "usage: mtmc asm [-o out.bin] [file.asm]")
+		fs.PrintDefaults()
+	}
+	out := fs.String("o", "", "output file, - for stdout (default: the input name with a .bin extension, or stdout when reading stdin)")
+	files, err := parseInterspersed(fs, args)
+	if err != nil {
+		return 2
+	}
+	if len(files) > 1 {
+		fs.Usage()
+		return 2
+	}
+
+	var src []byte
+	if len(files) == 0 {
+		src, err = io.ReadAll(stdin)
+		if *out == "" {
+			*out = "-"
+		}
+	} else {
+		src, err = os.ReadFile(files[0])
+		if *out == "" {
+			*out = strings.TrimSuffix(files[0], filepath.Ext(files[0])) + ".bin"
+		}
+	}
+	if err != nil {
+		fmt.Fprintln(stderr, err)
+		return 1
+	}
+
+	program, err := assembler.Assemble(string(src))
+	if err != nil {
+		// Each line of the joined error is one "line N: ..." message.
+		fmt.Fprintln(stderr, err)
+		return 1
+	}
+	if *out == "-" {
+		_, err = stdout.Write(program.Bytes())
+	} else {
+		err = os.WriteFile(*out, program.Bytes(), 0o644)
+	}
+	if err != nil {
+		fmt.Fprintln(stderr, err)
+		return 1
+	}
+	return 0
+}
+
+// parseInterspersed parses flags that may come after positional arguments,
+// as in "mtmc asm prog.asm -o prog.bin", and returns the positional ones.
+func parseInterspersed(fs *flag.FlagSet, args []string) ([]string, error) {
+	var positional []string
+	for {
+		if err := fs.Parse(args); err != nil {
+			return nil, err
+		}
+		if fs.NArg() == 0 {
+			return positional, nil
+		}
+		positional = append(positional, fs.Arg(0))
+		args = fs.Args()[1:]
+	}
+}
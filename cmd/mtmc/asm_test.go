@@ -0,0 +1,68 @@
+package main
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestAsmMain(t *testing.T) {
+	const src = "LIW R1, 0xBEEF\nNOP\nADD R0, R1, R2\nHALT\n"
+	want := []byte{0xB1, 0x0B, 0xBE, 0xEF, 0x00, 0x00, 0x10, 0x12, 0xF0, 0x00}
+
+	dir := t.TempDir()
+	fixture := filepath.Join(dir, "fixture.asm")
+	bad := filepath.Join(dir, "bad.asm")
+	for path, data := range map[string]string{fixture: src, bad: "NOP\nFROB R1\n"} {
+		if err := os.WriteFile(path, []byte(data), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	tests := []struct {
+		name   string
+		args   []string
+		stdin  string
+		status int
+		out    string // where the binary should be, "-" for stdout
+		errors string // expected in stderr
+	}{
+		{"default output", []string{fixture}, "", 0, filepath.Join(dir, "fixture.bin"), ""},
+		{"-o after the file", []string{fixture, "-o", filepath.Join(dir, "after.bin")}, "", 0, filepath.Join(dir, "after.bin"), ""},
+		{"-o before the file", []string{"-o", filepath.Join(dir, "before.bin"), fixture}, "", 0, filepath.Join(dir, "before.bin"), ""},
+		{"-o stdout", []string{"-o", "-", fixture}, "", 0, "-", ""},
+		{"stdin", nil, src, 0, "-", ""},
+		{"assembly error", []string{bad}, "", 1, "", "line 2"},
+		{"missing file", []string{filepath.Join(dir, "none.asm")}, "", 1, "", "none.asm"},
+		{"two files", []string{fixture, bad}, "", 2, "", "usage"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var stdout, stderr bytes.Buffer
+			status := asmMain(tt.args, strings.NewReader(tt.stdin), &stdout, &stderr)
+			if status != tt.status {
+				t.Fatalf("status %d, want %d: %s", status, tt.status, stderr.String())
+			}
+			if !strings.Contains(stderr.String(), tt.errors) {
+				t.Errorf("stderr %q does not mention %q", stderr.String(), tt.errors)
+			}
+			var got []byte
+			switch tt.out {
+			case "":
+				return
+			case "-":
+				got = stdout.Bytes()
+			default:
+				var err error
+				if got, err = os.ReadFile(tt.out); err != nil {
+					t.Fatal(err)
+				}
+			}
+			if !bytes.Equal(got, want) {
+				t.Errorf("binary % X, want % X", got, want)
+			}
+		})
+	}
+}
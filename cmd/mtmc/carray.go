@@ -0,0 +1,69 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"github.com/catdevman/go-mtmc/internal/emulator"
+	"io"
+	"os"
+)
+
+// carrayMain implements "mtmc carray [-name prog] file", which prints a
+// program as a C array for embedding in firmware. An ELF executable's
+// segments are laid out at their addresses, with any gaps between them
+// zeroed. It returns the process exit status.
+func carrayMain(args []string, stdout, stderr io.Writer) int {
+	fs := flag.NewFlagSet("carray", flag.ContinueOnError)
+	fs.SetOutput(stderr)
+	fs.Usage = func() {
+		fmt.Fprintln(stderr, "usage: mtmc carray [-name prog] file")
+		fs.PrintDefaults()
+	}
+	name := fs.String("name", "prog", "name of the C array")
+	if err := fs.Parse(args); err != nil {
+		return 2
+	}
+	if fs.NArg() != 1 {
+		fs.Usage()
+		return 2
+	}
+
+	data, err := os.ReadFile(fs.Arg(0))
+	if err != nil {
+		fmt.Fprintln(stderr, err)
+		return 1
+	}
+	var base, entry uint16
+	if emulator.IsELF(data) {
+		img, err := emulator.ParseELF(data)
+		if err != nil {
+			fmt.Fprintln(stderr, err)
+			return 1
+		}
+		base, data = flatten(img.Segments)
+		entry = img.Entry
+	}
+	if err := emulator.WriteCArray(stdout, *name, data, base, entry); err != nil {
+		fmt.Fprintln(stderr, err)
+		return 1
+	}
+	return 0
+}
+
+// flatten lays segments out as one run of bytes, returning the address it
+// starts at.
+func flatten(segments []emulator.Segment) (uint16, []byte) {
+	if len(segments) == 0 {
+		return 0, nil
+	}
+	lo, hi := int(segments[0].Address), 0
+	for _, seg := range segments {
+		lo = min(lo, int(seg.Address))
+		hi = max(hi, int(seg.Address)+len(seg.Data))
+	}
+	data := make([]byte, hi-lo)
+	for _, seg := range segments {
+		copy(data[int(seg.Address)-lo:], seg.Data)
+	}
+	return uint16(lo), data
+}
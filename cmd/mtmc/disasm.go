@@ -0,0 +1,58 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"github.com/catdevman/go-mtmc/internal/emulator"
+	"io"
+	"os"
+)
+
+// disasmMain implements "mtmc disasm [-start addr] [-count n] file", which
+// prints a listing of a program without starting the machine. It returns the
+// process exit status.
+func disasmMain(args []string, stdout, stderr io.Writer) int {
+	fs := flag.NewFlagSet("disasm", flag.ContinueOnError)
+	fs.SetOutput(stderr)
+	fs.Usage = func() {
+		fmt.Fprintln(stderr, "usage: mtmc disasm [-start addr] [-count n] file")
+		fs.PrintDefaults()
+	}
+	start := fs.Uint("start", 0, "first address to list")
+	count := fs.Int("count", -1, "maximum number of lines to list (-1 for all)")
+	if err := fs.Parse(args); err != nil {
+		return 2
+	}
+	if fs.NArg() != 1 {
+		fs.Usage()
+		return 2
+	}
+
+	data, err := os.ReadFile(fs.Arg(0))
+	if err != nil {
+		fmt.Fprintln(stderr, err)
+		return 1
+	}
+	img := emulator.Image{Segments: []emulator.Segment{{Data: data}}}
+	if emulator.IsELF(data) {
+		if img, err = emulator.ParseELF(data); err != nil {
+			fmt.Fprintln(stderr, err)
+			return 1
+		}
+	}
+
+	listed := 0
+	for _, seg := range img.Segments {
+		for _, line := range emulator.Disassemble(seg.Data, seg.Address) {
+			if uint(line.Address) < *start {
+				continue
+			}
+			if *count >= 0 && listed >= *count {
+				return 0
+			}
+			fmt.Fprintf(stdout, "0x%04X  %-8X  %s\n", line.Address, line.Bytes, line.Text)
+			listed++
+		}
+	}
+	return 0
+}
@@ -0,0 +1,47 @@
+package main
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestDisasmMain(t *testing.T) {
+	fixture := filepath.Join(t.TempDir(), "fixture.bin")
+	// LIW R1, 0xBEEF; NOP; ADD R0, R1, R2; HALT
+	if err := os.WriteFile(fixture, []byte{0xB1, 0x0B, 0xBE, 0xEF, 0x00, 0x00, 0x10, 0x12, 0xF0, 0x00}, 0o644); err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		name   string
+		args   []string
+		status int
+		want   string
+	}{
+		{"whole file", []string{fixture}, 0, "" +
+			"0x0000  B10BBEEF  LIW R1, 0xBEEF\n" +
+			"0x0004  0000      NOP\n" +
+			"0x0006  1012      ADD R0, R1, R2\n" +
+			"0x0008  F000      HALT\n"},
+		{"start and count", []string{"-start", "4", "-count", "2", fixture}, 0, "" +
+			"0x0004  0000      NOP\n" +
+			"0x0006  1012      ADD R0, R1, R2\n"},
+		{"start inside an instruction", []string{"-start", "2", "-count", "1", fixture}, 0, "" +
+			"0x0004  0000      NOP\n"},
+		{"start past the end", []string{"-start", "0x100", fixture}, 0, ""},
+		{"missing file", []string{filepath.Join(t.TempDir(), "none.bin")}, 1, ""},
+		{"no file", nil, 2, ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var stdout, stderr bytes.Buffer
+			if status := disasmMain(tt.args, &stdout, &stderr); status != tt.status {
+				t.Fatalf("status %d, want %d: %s", status, tt.status, stderr.String())
+			}
+			if stdout.String() != tt.want {
+				t.Errorf("listing:\n%s\nwant:\n%s", stdout.String(), tt.want)
+			}
+		})
+	}
+}
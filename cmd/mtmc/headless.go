@@ -0,0 +1,91 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"github.com/catdevman/go-mtmc/internal/assembler"
+	"github.com/catdevman/go-mtmc/internal/emulator"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// runHeadless loads the program at path, runs it to completion without the
+// web server, and returns the halted machine and how the run ended. The
+// program may be an ELF executable, assembly source named *.asm, or a flat
+// binary loaded and started at address 0. If
+// inputPath is set, the file's contents are queued as the program's input,
+// which IN reads as empty once it is used up. maxSteps bounds the run so a
+// program that never halts is stopped by the watchdog. opts further
+// configure the machine.
+func runHeadless(logger *slog.Logger, path, inputPath string, maxSteps uint64, opts ...emulator.Option) (*emulator.MonTanaMiniComputer, emulator.RunResult, error) {
+	program, err := os.ReadFile(path)
+	if err != nil {
+		return nil, emulator.RunResult{}, err
+	}
+	var input []byte
+	if inputPath != "" {
+		if input, err = os.ReadFile(inputPath); err != nil {
+			return nil, emulator.RunResult{}, fmt.Errorf("could not read input: %w", err)
+		}
+	}
+	opts = append([]emulator.Option{emulator.WithLogger(logger), emulator.WithWatchdog(maxSteps, 0)}, opts...)
+	computer := emulator.New(opts...)
+	name := filepath.Base(path)
+	switch {
+	case emulator.IsELF(program):
+		img, err := emulator.ParseELF(program)
+		if err != nil {
+			return nil, emulator.RunResult{}, err
+		}
+		if err := computer.LoadImage(name, img); err != nil {
+			return nil, emulator.RunResult{}, err
+		}
+	case strings.HasSuffix(name, ".asm"):
+		assembled, err := assembler.Assemble(string(program))
+		if err != nil {
+			return nil, emulator.RunResult{}, fmt.Errorf("could not assemble %s: %w", name, err)
+		}
+		if err := computer.LoadImage(name, assembled.Image()); err != nil {
+			return nil, emulator.RunResult{}, err
+		}
+	default:
+		if err := computer.LoadNamedProgram(name, program, 0, 0); err != nil {
+			return nil, emulator.RunResult{}, err
+		}
+	}
+	computer.WriteInput(input)
+	return computer, computer.RunToCompletion(), nil
+}
+
+// headlessExitStatus reports how a headless run ended and returns the
+// process exit status for it: the program's exit code if it halted, which
+// like any exit status only keeps its low byte, or 1 if it faulted or was
+// stopped by the watchdog.
+func headlessExitStatus(logger *slog.Logger, result emulator.RunResult) int {
+	if result.HaltReason != emulator.HaltInstruction {
+		logger.Error("program did not halt", "reason", result.HaltReason, "steps", result.Steps)
+		return 1
+	}
+	logger.Info("program halted", "exitCode", result.ExitCode, "steps", result.Steps)
+	return int(result.ExitCode & 0xFF)
+}
+
+// dumpState writes the machine's final state as indented JSON to path, or
+// to stdout if path is "-".
+func dumpState(computer *emulator.MonTanaMiniComputer, path string) error {
+	data, err := json.MarshalIndent(computer.GetState(), "", "  ")
+	if err != nil {
+		return err
+	}
+	data = append(data, '\n')
+	if path == "-" {
+		_, err = os.Stdout.Write(data)
+		return err
+	}
+	if err := os.WriteFile(path, data, 0o644); err != nil {
+		return fmt.Errorf("could not write state dump: %w", err)
+	}
+	return nil
+}
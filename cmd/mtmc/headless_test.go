@@ -0,0 +1,172 @@
+package main
+
+import (
+	"encoding/json"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/catdevman/go-mtmc/internal/assembler"
+	"github.com/catdevman/go-mtmc/internal/emulator"
+)
+
+// echoSource copies its input to its output and exits with 0 once the
+// input is used up.
+const echoSource = `
+	LIW  R2, 1
+loop:	IN   R1
+	ADD  R0, R1, R2  ; 0 once IN reports no more input
+	BZ   R0, done
+	OUT  R1
+	BZ   SR, loop    ; SR is clear, so always taken
+done:	HALT
+`
+
+func TestRunHeadlessEcho(t *testing.T) {
+	dir := t.TempDir()
+	write := func(name string, data []byte) string {
+		t.Helper()
+		path := filepath.Join(dir, name)
+		if err := os.WriteFile(path, data, 0o644); err != nil {
+			t.Fatal(err)
+		}
+		return path
+	}
+	asm := write("echo.asm", []byte(echoSource))
+	assembled, err := assembler.Assemble(echoSource)
+	if err != nil {
+		t.Fatal(err)
+	}
+	bin := write("echo.bin", assembled.Segments[0].Data)
+
+	tests := []struct {
+		name    string
+		program string
+		input   string // a file's name in dir, or "" for no input
+		data    string
+		output  string
+	}{
+		{"assembly", asm, "hello.txt", "hello\n", "hello\n"},
+		{"binary", bin, "hello.txt", "hello\n", "hello\n"},
+		{"empty input file", asm, "empty.txt", "", ""},
+		{"no input", asm, "", "", ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			inputPath := ""
+			if tt.input != "" {
+				inputPath = write(tt.input, []byte(tt.data))
+			}
+			_, result, err := runHeadless(slog.New(slog.DiscardHandler), tt.program, inputPath, 1000)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if result.HaltReason != emulator.HaltInstruction || result.ExitCode != 0 {
+				t.Fatalf("halted with %q, exit code %d; want %q, 0", result.HaltReason, result.ExitCode, emulator.HaltInstruction)
+			}
+			if string(result.Output) != tt.output {
+				t.Errorf("output = %q, want %q", result.Output, tt.output)
+			}
+		})
+	}
+}
+
+func TestRunHeadlessErrors(t *testing.T) {
+	dir := t.TempDir()
+	bad := filepath.Join(dir, "bad.asm")
+	if err := os.WriteFile(bad, []byte("FROB R1\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		name, program, input string
+	}{
+		{"missing program", filepath.Join(dir, "none.bin"), ""},
+		{"missing input", bad, filepath.Join(dir, "none.txt")},
+		{"bad assembly", bad, ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, _, err := runHeadless(slog.New(slog.DiscardHandler), tt.program, tt.input, 1000); err == nil {
+				t.Error("runHeadless succeeded")
+			}
+		})
+	}
+}
+
+// TestRunHeadlessExit runs fixtures that end each way a headless run can
+// and checks the exit status reported for them.
+func TestRunHeadlessExit(t *testing.T) {
+	tests := []struct {
+		name   string
+		source string
+		reason emulator.HaltReason
+		status int
+	}{
+		{"exit code", "LIW R0, 7\nHALT", emulator.HaltInstruction, 7},
+		{"exit code low byte", "LIW R0, 0x1234\nHALT", emulator.HaltInstruction, 0x34},
+		{"zero", "HALT", emulator.HaltInstruction, 0},
+		{"fault", "LIW R1, 0xFFFF\nLW R2, R1, 0x10", emulator.HaltMemoryFault, 1},
+		{"never halts", "loop: NOP\nBZ SR, loop", emulator.HaltWatchdog, 1},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			program := filepath.Join(t.TempDir(), "fixture.asm")
+			if err := os.WriteFile(program, []byte(tt.source), 0o644); err != nil {
+				t.Fatal(err)
+			}
+			logger := slog.New(slog.DiscardHandler)
+			_, result, err := runHeadless(logger, program, "", 1000)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if result.HaltReason != tt.reason {
+				t.Errorf("halt reason %q, want %q", result.HaltReason, tt.reason)
+			}
+			if status := headlessExitStatus(logger, result); status != tt.status {
+				t.Errorf("exit status %d, want %d", status, tt.status)
+			}
+		})
+	}
+}
+
+func TestDumpState(t *testing.T) {
+	dir := t.TempDir()
+	program := filepath.Join(dir, "seven.asm")
+	if err := os.WriteFile(program, []byte("LIW R0, 7\nLIW R1, 'A'\nOUT R1\nHALT\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	logger := slog.New(slog.DiscardHandler)
+	computer, result, err := runHeadless(logger, program, "", 1000)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if status := headlessExitStatus(logger, result); status != 7 {
+		t.Errorf("exit status %d, want 7", status)
+	}
+
+	dump := filepath.Join(dir, "state.json")
+	if err := dumpState(computer, dump); err != nil {
+		t.Fatal(err)
+	}
+	data, err := os.ReadFile(dump)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var state struct {
+		ExitCode  uint16     `json:"exitCode"`
+		Output    string     `json:"output"`
+		Registers [16]uint16 `json:"registers"`
+	}
+	if err := json.Unmarshal(data, &state); err != nil {
+		t.Fatalf("dump is not valid JSON: %v\n%s", err, data)
+	}
+	if state.ExitCode != 7 || state.Output != "A" || state.Registers[0] != 7 || state.Registers[1] != 'A' {
+		t.Errorf("dumped exit code %d, output %q, R0 %d, R1 %d; want 7, %q, 7, %d",
+			state.ExitCode, state.Output, state.Registers[0], state.Registers[1], "A", 'A')
+	}
+
+	if err := dumpState(computer, filepath.Join(dir, "missing", "state.json")); err == nil {
+		t.Error("dumping into a missing directory succeeded")
+	}
+}
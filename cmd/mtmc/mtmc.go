@@ -1,20 +1,134 @@
 package main
 
 import (
+	"flag"
+	"github.com/catdevman/go-mtmc/internal/disk"
 	"github.com/catdevman/go-mtmc/internal/emulator"
 	"github.com/catdevman/go-mtmc/internal/web"
-	"log"
+	"log/slog"
 	"os"
 	"os/signal"
+	"strconv"
 	"syscall"
 )
 
 func main() {
+	if len(os.Args) > 1 {
+		switch os.Args[1] {
+		case "asm":
+			os.Exit(asmMain(os.Args[2:], os.Stdin, os.Stdout, os.Stderr))
+		case "disasm":
+			os.Exit(disasmMain(os.Args[2:], os.Stdout, os.Stderr))
+		case "carray":
+			os.Exit(carrayMain(os.Args[2:], os.Stdout, os.Stderr))
+		}
+	}
+
+	addr := flag.String("addr", web.DefaultAddr, "HTTP listen address")
+	diskDir := flag.String("disk", "", "directory for persisting files written to the disk (default: keep in memory)")
+	logLevel := flag.String("log-level", "info", "minimum log level: debug, info, warn or error")
+	autostart := flag.Bool("autostart", false, "start running programs as soon as they are loaded instead of waiting for Run")
+	bpSnapshots := flag.Int("breakpoint-snapshots", 0, "capture the machine state each time a breakpoint is hit, keeping this many (0 disables)")
+	headless := flag.Bool("headless", false, "run -program to completion without the web server, printing its output and exiting with its exit code")
+	program := flag.String("program", "", "program file to run headlessly, without the web server (requires -headless or -dump): an ELF executable, assembly source (*.asm) or a flat binary loaded at address 0")
+	input := flag.String("input", "", "file whose contents a headless run's program reads as its input (default: no input)")
+	maxSteps := flag.Uint64("max-steps", 10_000_000, "instructions a headless run may execute before it is stopped")
+	consoleAddr := flag.String("console", "", "map the console device's three ports at this even address (default: no console)")
+	blockFile := flag.String("block-file", "", "disk file backing the block device, such as disk/data/blocks.img (default: no block device)")
+	blockCount := flag.Int("blocks", 256, "number of blocks in the block device")
+	traceSize := flag.Int("trace", 0, "keep a trace of this many executed instructions, streamed on /trace/ws (0 disables)")
+	fill := flag.String("fill", "", "word pattern, such as 0xDEAD, to fill memory with on reset so reads of uninitialized memory stand out (default: zero)")
+	dump := flag.String("dump", "", "after a headless run, write the final machine state as JSON to this file (- for stdout)")
+	flag.Parse()
+
+	var level slog.Level
+	if err := level.UnmarshalText([]byte(*logLevel)); err != nil {
+		slog.Error("invalid log level", "level", *logLevel, "err", err)
+		os.Exit(2)
+	}
+	logger := slog.New(slog.NewTextHandler(os.Stderr, &slog.HandlerOptions{Level: level}))
+
+	if *diskDir != "" {
+		if err := disk.SetOverlayDir(*diskDir); err != nil {
+			logger.Error("could not use disk directory", "dir", *diskDir, "err", err)
+			os.Exit(1)
+		}
+	}
+
+	var fillOpts []emulator.Option
+	if *fill != "" {
+		pattern, err := strconv.ParseUint(*fill, 0, 16)
+		if err != nil {
+			logger.Error("invalid fill pattern; it must be a 16-bit word", "fill", *fill)
+			os.Exit(2)
+		}
+		fillOpts = append(fillOpts, emulator.WithMemoryFill(uint16(pattern)))
+	}
+
+	if *headless || *dump != "" {
+		if *program == "" {
+			logger.Error("a headless run needs a program; pass it with -program")
+			os.Exit(2)
+		}
+		computer, result, err := runHeadless(logger, *program, *input, *maxSteps, fillOpts...)
+		if err == nil && *headless {
+			_, err = os.Stdout.Write(result.Output)
+		}
+		if err == nil && *dump != "" {
+			err = dumpState(computer, *dump)
+		}
+		if err != nil {
+			logger.Error("headless run failed", "program", *program, "err", err)
+			os.Exit(1)
+		}
+		if *headless {
+			os.Exit(headlessExitStatus(logger, result))
+		}
+		return
+	}
+
 	// Create a new instance of the MTMC computer.
-	computer := emulator.New()
+	computerOpts := []emulator.Option{emulator.WithLogger(logger), emulator.WithAsyncObservers()}
+	computerOpts = append(computerOpts, fillOpts...)
+	if *bpSnapshots > 0 {
+		computerOpts = append(computerOpts, emulator.WithBreakpointSnapshots(*bpSnapshots))
+	}
+	if *traceSize > 0 {
+		computerOpts = append(computerOpts, emulator.WithTrace(*traceSize))
+	}
+	if *autostart {
+		computerOpts = append(computerOpts, emulator.WithAutoStart())
+	}
+	if *consoleAddr != "" {
+		base, err := strconv.ParseUint(*consoleAddr, 0, 16)
+		if err != nil || base%emulator.WordSize != 0 || base+2*emulator.WordSize >= emulator.MemorySize {
+			logger.Error("invalid console address; it must be even and leave room for three ports", "addr", *consoleAddr)
+			os.Exit(2)
+		}
+		computerOpts = append(computerOpts, emulator.WithConsole(uint16(base)))
+	}
+	if *blockFile != "" {
+		if emulator.BlockSize > emulator.MemorySize {
+			logger.Error("the block device needs more memory than the machine has", "blockSize", emulator.BlockSize, "memory", emulator.MemorySize)
+			os.Exit(2)
+		}
+		dev := disk.NewBlockFile(*blockFile, emulator.BlockSize, *blockCount)
+		computerOpts = append(computerOpts, emulator.WithBlockDevice(dev))
+	}
+	computer := emulator.New(computerOpts...)
 
 	// Start the web server, which provides the user interface.
-	server := web.NewServer(computer)
+	// Setting MTMC_PASSWORD requires basic auth, with the username from
+	// MTMC_USERNAME (default "mtmc"), so the machine can be exposed on a LAN.
+	serverOpts := []web.Option{web.WithLogger(logger), web.WithAddr(*addr)}
+	if password := os.Getenv("MTMC_PASSWORD"); password != "" {
+		username := os.Getenv("MTMC_USERNAME")
+		if username == "" {
+			username = "mtmc"
+		}
+		serverOpts = append(serverOpts, web.WithBasicAuth(username, password))
+	}
+	server := web.NewServer(computer, serverOpts...)
 	go server.Start()
 
 	// Start the computer's execution cycle in a separate goroutine.
@@ -24,5 +138,5 @@ func main() {
 	quit := make(chan os.Signal, 1)
 	signal.Notify(quit, syscall.SIGINT, syscall.SIGTERM)
 	<-quit
-	log.Println("Shutting down...")
+	logger.Info("shutting down")
 }
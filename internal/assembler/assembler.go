@@ -0,0 +1,650 @@
+// Package assembler translates MTMC assembly source into machine code.
+//
+// Each line holds an optional label ("name:"), then an instruction or
+// directive, then an optional comment starting with ';' or '#'. Operands are
+// separated by commas:
+//
+//	ADD  rd, rs, rt   ; likewise SUB, AND, OR, XOR, SLL, SRL and CAS
+//	ADDI rd, rs, imm  ; likewise SUBI, LW and SW
+//	BZ   rs, label    ; branch target, encoded PC-relative
+//	RDCNT rd          ; extended operations take the registers they use
+//	BSET rd, n        ; bit operations (BTST, BSET, BCLR) take a bit number
+//	LIW  rd, value    ; a full 16-bit value, in a second word
+//	NOP
+//	HALT
+//
+// The immediate instructions have no register field of their own for rs:
+// the machine reads the register numbered by the high four bits of the
+// 8-bit immediate, then adds the whole immediate. So rs must be the register
+// the immediate selects, and the assembler reports any other as an error.
+// "ADDI R1, R0, 5" adds 5 to R0, "ADDI R1, R2, 0x25" adds 0x25 to R2, and
+// "BZ R0, label" tests R0 to branch up to 15 words ahead, while branches up
+// to 16 words back test SR.
+//
+// Registers use the names in emulator.RegisterNames, or aliases given with
+// WithRegisterAliases. Numbers may be decimal, 0x-prefixed hex, or 'c'
+// character literals, and a label may appear wherever a number is expected.
+//
+// Data directives emit raw bytes: ".word v, ..." (16-bit big-endian, word
+// aligned), ".byte v, ..." and ".ascii"/".asciiz" with a quoted string, the
+// latter adding a trailing zero byte. Instructions are always word aligned.
+//
+// ".equ NAME value", or "NAME = value", defines a constant that may be used
+// wherever a number is expected. Its value may refer to labels and other
+// constants, wherever they are defined, and a name may only be defined once.
+//
+// ".org addr" starts a new segment at addr, so code and data can be placed
+// at separate addresses. Segments may not overlap. Assembly starts at
+// address 0 and the program's entry point is the address of its first
+// instruction or data.
+package assembler
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/catdevman/go-mtmc/internal/emulator"
+)
+
+// Program is the output of Assemble.
+type Program struct {
+	// Segments hold the machine code, in source order.
+	Segments []emulator.Segment
+	// Entry is where execution starts.
+	Entry uint16
+	// Labels maps each label to its address.
+	Labels map[string]uint16
+	// Lines maps the address of each instruction and data directive to
+	// the source line, counting from 1, that it was assembled from.
+	Lines map[uint16]int
+	// Code lists the address ranges holding instructions, as opposed to
+	// data, in address order.
+	Code []emulator.CodeRange
+
+	source []string // lines of the source, for Image
+}
+
+// Image returns the program in the form the emulator loads, including the
+// labels and the source text of each line in Lines so debuggers can show
+// them.
+func (p *Program) Image() emulator.Image {
+	source := make(map[uint16]string, len(p.Lines))
+	for addr, line := range p.Lines {
+		source[addr] = strings.TrimSpace(p.source[line-1])
+	}
+	return emulator.Image{Segments: p.Segments, Entry: p.Entry, Source: source, Labels: p.Labels, Code: p.Code}
+}
+
+// Bytes flattens the program into a single binary to be loaded at address 0,
+// zero filling any gaps between segments.
+func (p *Program) Bytes() []byte {
+	var out []byte
+	for _, seg := range p.Segments {
+		if end := int(seg.Address) + len(seg.Data); end > len(out) {
+			out = append(out, make([]byte, end-len(out))...)
+		}
+		copy(out[seg.Address:], seg.Data)
+	}
+	return out
+}
+
+// Error reports a problem with one line of source.
+type Error struct {
+	Line int
+	Err  error
+}
+
+func (e *Error) Error() string {
+	return fmt.Sprintf("line %d: %v", e.Line, e.Err)
+}
+
+func (e *Error) Unwrap() error {
+	return e.Err
+}
+
+// statement is one instruction or directive, placed at addr.
+type statement struct {
+	line     int
+	addr     uint16
+	size     int
+	name     string // upper-cased mnemonic or lower-cased directive
+	operands []string
+	text     string // string operand of .ascii and .asciiz
+}
+
+// segment is a run of statements starting at a .org address (or 0).
+type segment struct {
+	line       int // of the .org, for error reporting
+	start      uint16
+	statements []statement
+}
+
+// Option configures an assembly.
+type Option func(*assembly)
+
+// WithRegisterAliases lets registers also be written by the aliases in
+// aliases, which maps canonical register names to aliases as
+// MonTanaMiniComputer.RegisterAliases returns them.
+func WithRegisterAliases(aliases map[string]string) Option {
+	return func(a *assembly) {
+		for canonical, alias := range aliases {
+			a.aliases[strings.ToUpper(alias)] = strings.ToUpper(canonical)
+		}
+	}
+}
+
+// Assemble translates src into machine code. If any lines are invalid it
+// returns an error reporting every one of them, each as an *Error.
+func Assemble(src string, opts ...Option) (*Program, error) {
+	a := &assembly{
+		labels:    make(map[string]uint16),
+		constants: make(map[string]*constant),
+		aliases:   make(map[string]string),
+	}
+	for _, opt := range opts {
+		opt(a)
+	}
+	a.layout(src)
+	a.checkOverlap()
+	a.checkConstants()
+	segments := a.emit()
+	if len(a.errs) > 0 {
+		return nil, errors.Join(a.errs...)
+	}
+	p := &Program{Segments: segments, Labels: a.labels, Lines: a.lines(), Code: a.code(), source: strings.Split(src, "\n")}
+	if len(segments) > 0 {
+		p.Entry = segments[0].Address
+	}
+	return p, nil
+}
+
+type assembly struct {
+	segments  []segment
+	labels    map[string]uint16
+	constants map[string]*constant
+	order     []string          // constant names in the order defined
+	aliases   map[string]string // canonical register name by alias
+	errs      []error
+}
+
+// constant is a name defined by .equ, whose value is resolved when it is
+// used, so it may refer to labels defined later in the source.
+type constant struct {
+	line      int
+	value     string
+	resolving bool // set while its value is resolved, to catch cycles
+}
+
+func (a *assembly) errorf(line int, format string, args ...interface{}) {
+	a.errs = append(a.errs, &Error{Line: line, Err: fmt.Errorf(format, args...)})
+}
+
+// layout is the first pass: it parses each line, assigns addresses and
+// records label definitions. A label is bound to the address of the
+// statement that follows it, after that statement is aligned, so a label
+// before a .word or instruction never points at the padding byte.
+func (a *assembly) layout(src string) {
+	addr := 0
+	current := segment{line: 1}
+	var pending []string // labels waiting for the next statement's address
+	bind := func() {
+		for _, label := range pending {
+			a.labels[label] = uint16(addr)
+		}
+		pending = nil
+	}
+	for i, raw := range strings.Split(src, "\n") {
+		line := i + 1
+		text := strings.TrimSpace(stripComment(raw))
+
+		if label, rest, ok := cutLabel(text); ok {
+			if a.defined(label) || slices.Contains(pending, label) {
+				a.errorf(line, "label %q already defined", label)
+			}
+			pending = append(pending, label)
+			text = rest
+		}
+		if text == "" {
+			continue
+		}
+		if name, value, ok := cutAssignment(text); ok {
+			a.define(line, name, value)
+			continue
+		}
+
+		name, args := text, ""
+		if i := strings.IndexAny(text, " \t"); i >= 0 {
+			name, args = text[:i], strings.TrimSpace(text[i:])
+		}
+		st := statement{line: line, name: strings.ToUpper(name)}
+
+		var size int
+		switch directive := strings.ToLower(name); directive {
+		case ".org":
+			// Labels before a .org mark the end of the previous segment.
+			bind()
+			org, err := a.value(args, 0, math.MaxUint16)
+			if err != nil {
+				a.errorf(line, ".org: %v", err)
+				continue
+			}
+			if len(current.statements) > 0 {
+				a.segments = append(a.segments, current)
+			}
+			addr = org
+			current = segment{line: line, start: uint16(org)}
+			continue
+		case ".equ":
+			name, value := args, ""
+			if i := strings.IndexAny(args, " \t,"); i >= 0 {
+				name, value = args[:i], strings.TrimSpace(strings.TrimPrefix(strings.TrimSpace(args[i:]), ","))
+			}
+			if name == "" || value == "" {
+				a.errorf(line, ".equ needs a name and a value")
+				continue
+			}
+			a.define(line, name, value)
+			continue
+		case ".word":
+			st.name = directive
+			st.operands = splitOperands(args)
+			addr += addr % 2
+			size = 2 * len(st.operands)
+		case ".byte":
+			st.name = directive
+			st.operands = splitOperands(args)
+			size = len(st.operands)
+		case ".ascii", ".asciiz":
+			st.name = directive
+			s, err := strconv.Unquote(args)
+			if err != nil {
+				a.errorf(line, "%s needs a quoted string", directive)
+				continue
+			}
+			st.text = s
+			size = len(s)
+			if directive == ".asciiz" {
+				size++
+			}
+		default:
+			if strings.HasPrefix(name, ".") {
+				a.errorf(line, "unknown directive %s", name)
+				continue
+			}
+			st.operands = splitOperands(args)
+			addr += addr % 2
+			size = emulator.WordSize
+			if op, ext, ok := emulator.ParseMnemonic(name); ok {
+				size = emulator.Width(op, ext)
+			}
+		}
+
+		bind()
+		if addr+size > math.MaxUint16+1 {
+			a.errorf(line, "program exceeds the 16-bit address space")
+			return
+		}
+		st.addr, st.size = uint16(addr), size
+		if len(current.statements) == 0 {
+			// Alignment may have moved the first statement past the .org.
+			current.start = st.addr
+		}
+		current.statements = append(current.statements, st)
+		addr += size
+	}
+	bind()
+	if len(current.statements) > 0 {
+		a.segments = append(a.segments, current)
+	}
+}
+
+// defined reports whether name is already a label or constant.
+func (a *assembly) defined(name string) bool {
+	_, label := a.labels[name]
+	_, constant := a.constants[name]
+	return label || constant
+}
+
+// define records the constant name, whose value is resolved later.
+func (a *assembly) define(line int, name, value string) {
+	if a.defined(name) {
+		a.errorf(line, "%q already defined", name)
+		return
+	}
+	a.constants[name] = &constant{line: line, value: value}
+	a.order = append(a.order, name)
+}
+
+// checkConstants reports constants whose values do not resolve, even if
+// they are never used.
+func (a *assembly) checkConstants() {
+	for _, name := range a.order {
+		if _, err := a.resolve(name); err != nil {
+			a.errorf(a.constants[name].line, "%s: %v", name, err)
+		}
+	}
+}
+
+// lines builds the address to source line map.
+func (a *assembly) lines() map[uint16]int {
+	lines := make(map[uint16]int)
+	for _, seg := range a.segments {
+		for _, st := range seg.statements {
+			lines[st.addr] = st.line
+		}
+	}
+	return lines
+}
+
+// code builds the ranges of instructions, merging adjacent ones.
+func (a *assembly) code() []emulator.CodeRange {
+	ranges := []emulator.CodeRange{}
+	for _, seg := range a.segments {
+		for _, st := range seg.statements {
+			if strings.HasPrefix(st.name, ".") {
+				continue
+			}
+			end := st.addr + uint16(st.size-1)
+			if n := len(ranges); n > 0 && int(ranges[n-1].End)+1 == int(st.addr) {
+				ranges[n-1].End = end
+				continue
+			}
+			ranges = append(ranges, emulator.CodeRange{Start: st.addr, End: end})
+		}
+	}
+	sort.Slice(ranges, func(i, j int) bool { return ranges[i].Start < ranges[j].Start })
+	return ranges
+}
+
+// checkOverlap reports segments that occupy the same addresses.
+func (a *assembly) checkOverlap() {
+	sorted := make([]segment, len(a.segments))
+	copy(sorted, a.segments)
+	sort.Slice(sorted, func(i, j int) bool { return sorted[i].start < sorted[j].start })
+	for i := 1; i < len(sorted); i++ {
+		prev, next := sorted[i-1], sorted[i]
+		if end := segmentEnd(prev); end > int(next.start) {
+			later := max(prev.line, next.line)
+			a.errorf(later, "segment at 0x%04X overlaps segment at 0x%04X-0x%04X",
+				next.start, prev.start, end-1)
+		}
+	}
+}
+
+// segmentEnd returns the address just past the end of seg.
+func segmentEnd(seg segment) int {
+	last := seg.statements[len(seg.statements)-1]
+	return int(last.addr) + last.size
+}
+
+// emit is the second pass: it encodes every statement now that all label
+// addresses are known.
+func (a *assembly) emit() []emulator.Segment {
+	segments := make([]emulator.Segment, 0, len(a.segments))
+	for _, seg := range a.segments {
+		segments = append(segments, emulator.Segment{Address: seg.start, Data: a.emitSegment(seg)})
+	}
+	return segments
+}
+
+func (a *assembly) emitSegment(seg segment) []byte {
+	var out []byte
+	for _, st := range seg.statements {
+		for len(out) < int(st.addr-seg.start) {
+			out = append(out, 0)
+		}
+		switch st.name {
+		case ".word":
+			for _, operand := range st.operands {
+				v, err := a.value(operand, math.MinInt16, math.MaxUint16)
+				if err != nil {
+					a.errorf(st.line, "%v", err)
+				}
+				out = append(out, byte(v>>8), byte(v))
+			}
+		case ".byte":
+			for _, operand := range st.operands {
+				v, err := a.value(operand, math.MinInt8, math.MaxUint8)
+				if err != nil {
+					a.errorf(st.line, "%v", err)
+				}
+				out = append(out, byte(v))
+			}
+		case ".ascii", ".asciiz":
+			out = append(out, st.text...)
+			if st.name == ".asciiz" {
+				out = append(out, 0)
+			}
+		default:
+			code, err := a.instruction(st)
+			if err != nil {
+				a.errorf(st.line, "%v", err)
+			}
+			out = append(out, code...)
+		}
+	}
+	return out
+}
+
+// instruction encodes a single instruction statement.
+func (a *assembly) instruction(st statement) ([]byte, error) {
+	op, ext, ok := emulator.ParseMnemonic(st.name)
+	if !ok {
+		return nil, fmt.Errorf("unknown instruction %s", st.name)
+	}
+	ins := emulator.Instruction{Op: op}
+	// Wide instructions take a 16-bit value as their last operand, which
+	// is emitted as a word of its own after the instruction word.
+	wide := emulator.Width(op, ext) > emulator.WordSize
+	operands := st.operands
+
+	var want int
+	switch op.Format() {
+	case emulator.FormatRRR:
+		want = 3
+	case emulator.FormatRI:
+		want = 3
+		if op == emulator.OpBZ {
+			want = 2
+		}
+	case emulator.FormatExt:
+		want = ext.Operands()
+	}
+	if wide {
+		want++
+	}
+	if len(st.operands) != want {
+		return nil, fmt.Errorf("%s takes %d operands, got %d", st.name, want, len(st.operands))
+	}
+	if wide {
+		operands = operands[:want-1]
+	}
+
+	switch op.Format() {
+	case emulator.FormatRRR, emulator.FormatExt:
+		regs := make([]uint8, 3)
+		for i, operand := range operands {
+			if i == 1 && ext.BitIndex() {
+				n, err := a.value(operand, 0, 15)
+				if err != nil {
+					return nil, err
+				}
+				regs[i] = uint8(n)
+				continue
+			}
+			reg, err := a.register(operand)
+			if err != nil {
+				return nil, err
+			}
+			regs[i] = reg
+		}
+		ins.RegD, ins.RegS, ins.RegT = regs[0], regs[1], regs[2]
+		if op == emulator.OpEXT {
+			ins.RegT = uint8(ext)
+		}
+	case emulator.FormatRI:
+		target := operands[len(operands)-1]
+		regs := make([]uint8, len(operands)-1)
+		for i, operand := range operands[:len(regs)] {
+			reg, err := a.register(operand)
+			if err != nil {
+				return nil, err
+			}
+			regs[i] = reg
+		}
+		var imm int
+		if op == emulator.OpBZ {
+			// Branches are relative to the following instruction and
+			// counted in words.
+			dest, err := a.value(target, 0, math.MaxUint16)
+			if err != nil {
+				return nil, err
+			}
+			offset := dest - (int(st.addr) + 2)
+			if offset%2 != 0 {
+				return nil, fmt.Errorf("branch target %s is not word aligned", target)
+			}
+			if offset/2 < math.MinInt8 || offset/2 > math.MaxInt8 {
+				return nil, fmt.Errorf("branch target %s is too far away", target)
+			}
+			imm = offset / 2
+		} else {
+			v, err := a.value(target, math.MinInt8, math.MaxInt8)
+			if err != nil {
+				return nil, err
+			}
+			imm, ins.RegD = v, regs[0]
+		}
+		rs := regs[len(regs)-1]
+		if selected := uint8(imm) >> 4; selected != rs {
+			return nil, fmt.Errorf("%s reads the register selected by the high nibble of its immediate, and %d selects %s, not %s",
+				op, imm, emulator.RegisterNames[selected], emulator.RegisterNames[rs])
+		}
+		ins.Imm = int16(imm)
+	}
+	word, err := emulator.Encode(ins)
+	if err != nil {
+		return nil, err
+	}
+	out := []byte{byte(word >> 8), byte(word)}
+	if wide {
+		v, err := a.value(st.operands[len(st.operands)-1], math.MinInt16, math.MaxUint16)
+		if err != nil {
+			return nil, err
+		}
+		out = append(out, byte(v>>8), byte(v))
+	}
+	return out, nil
+}
+
+// value resolves a numeric literal or label and checks it lies in [lo, hi].
+func (a *assembly) value(operand string, lo, hi int) (int, error) {
+	v, err := a.resolve(operand)
+	if err != nil {
+		return 0, err
+	}
+	if v < lo || v > hi {
+		return 0, fmt.Errorf("value %s (%d) out of range [%d, %d]", operand, v, lo, hi)
+	}
+	return v, nil
+}
+
+func (a *assembly) resolve(operand string) (int, error) {
+	if addr, ok := a.labels[operand]; ok {
+		return int(addr), nil
+	}
+	if c, ok := a.constants[operand]; ok {
+		if c.resolving {
+			return 0, fmt.Errorf("constant %s is defined in terms of itself", operand)
+		}
+		c.resolving = true
+		defer func() { c.resolving = false }()
+		return a.resolve(c.value)
+	}
+	if len(operand) >= 3 && operand[0] == '\'' {
+		s, err := strconv.Unquote(operand)
+		if err != nil || len(s) != 1 {
+			return 0, fmt.Errorf("invalid character literal %s", operand)
+		}
+		return int(s[0]), nil
+	}
+	v, err := strconv.ParseInt(operand, 0, 32)
+	if err != nil {
+		return 0, fmt.Errorf("undefined label or invalid number %q", operand)
+	}
+	return int(v), nil
+}
+
+// register parses a register name or alias.
+func (a *assembly) register(operand string) (uint8, error) {
+	name := strings.ToUpper(operand)
+	if canonical, ok := a.aliases[name]; ok {
+		name = canonical
+	}
+	for i, reg := range emulator.RegisterNames {
+		if reg == name {
+			return uint8(i), nil
+		}
+	}
+	return 0, fmt.Errorf("unknown register %q", operand)
+}
+
+// cutLabel splits a leading "label:" off text.
+func cutLabel(text string) (label, rest string, ok bool) {
+	label, rest, ok = strings.Cut(text, ":")
+	if !ok || label == "" || strings.ContainsAny(label, " \t\"'") {
+		return "", text, false
+	}
+	return label, strings.TrimSpace(rest), true
+}
+
+// cutAssignment splits a "NAME = value" constant definition.
+func cutAssignment(text string) (name, value string, ok bool) {
+	name, value, ok = strings.Cut(text, "=")
+	name, value = strings.TrimSpace(name), strings.TrimSpace(value)
+	if !ok || name == "" || value == "" || strings.ContainsAny(name, " \t\"'.") {
+		return "", text, false
+	}
+	return name, value, true
+}
+
+// stripComment removes a trailing ';' or '#' comment, ignoring those inside
+// string and character literals.
+func stripComment(line string) string {
+	var quote rune
+	escaped := false
+	for i, r := range line {
+		switch {
+		case escaped:
+			escaped = false
+		case quote != 0 && r == '\\':
+			escaped = true
+		case quote != 0:
+			if r == quote {
+				quote = 0
+			}
+		case r == '"' || r == '\'':
+			quote = r
+		case r == ';' || r == '#':
+			return line[:i]
+		}
+	}
+	return line
+}
+
+// splitOperands splits a comma-separated operand list.
+func splitOperands(args string) []string {
+	if args == "" {
+		return nil
+	}
+	var operands []string
+	for _, operand := range strings.Split(args, ",") {
+		operands = append(operands, strings.TrimSpace(operand))
+	}
+	return operands
+}
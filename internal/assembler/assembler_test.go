@@ -0,0 +1,432 @@
+package assembler
+
+import (
+	"bytes"
+	"log/slog"
+	"maps"
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/catdevman/go-mtmc/internal/emulator"
+)
+
+func TestDataDirectives(t *testing.T) {
+	tests := []struct {
+		name   string
+		src    string
+		want   []byte
+		labels map[string]uint16
+	}{
+		{
+			name:   "asciiz string",
+			src:    "HALT\nmsg: .asciiz \"hi\"",
+			want:   []byte{0xF0, 0x00, 'h', 'i', 0},
+			labels: map[string]uint16{"msg": 2},
+		},
+		{
+			name:   "ascii has no terminator",
+			src:    "msg: .ascii \"ok\"",
+			want:   []byte{'o', 'k'},
+			labels: map[string]uint16{"msg": 0},
+		},
+		{
+			name:   "bytes and negative bytes",
+			src:    ".byte 1, 0xFF, -1, 'A'",
+			want:   []byte{1, 0xFF, 0xFF, 'A'},
+			labels: map[string]uint16{},
+		},
+		{
+			name:   "words are big endian",
+			src:    "table: .word 0x1234, -2, table",
+			want:   []byte{0x12, 0x34, 0xFF, 0xFE, 0x00, 0x00},
+			labels: map[string]uint16{"table": 0},
+		},
+		{
+			name:   "label on aligned word",
+			src:    ".byte 1\nval: .word 5",
+			want:   []byte{1, 0, 0, 5},
+			labels: map[string]uint16{"val": 2},
+		},
+		{
+			name:   "label on its own line before aligned word",
+			src:    ".byte 1\nval:\n.word 5",
+			want:   []byte{1, 0, 0, 5},
+			labels: map[string]uint16{"val": 2},
+		},
+		{
+			name:   "branch to label after odd data",
+			src:    ".byte 1\nloop: NOP\nBZ SR, loop",
+			want:   []byte{1, 0, 0x00, 0x00, 0xE0, 0xFE},
+			labels: map[string]uint16{"loop": 2},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			p, err := Assemble(tt.src)
+			if err != nil {
+				t.Fatalf("Assemble: %v", err)
+			}
+			if got := p.Bytes(); !bytes.Equal(got, tt.want) {
+				t.Errorf("bytes = % X, want % X", got, tt.want)
+			}
+			for label, want := range tt.labels {
+				if got, ok := p.Labels[label]; !ok || got != want {
+					t.Errorf("label %s = 0x%04X (defined %v), want 0x%04X", label, got, ok, want)
+				}
+			}
+		})
+	}
+}
+
+func TestDataDirectiveErrors(t *testing.T) {
+	tests := []struct {
+		name string
+		src  string
+		want string
+	}{
+		{"byte too large", ".byte 256", "out of range"},
+		{"byte too small", ".byte -129", "out of range"},
+		{"word too large", ".word 0x10000", "out of range"},
+		{"ascii unquoted", ".ascii hi", "needs a quoted string"},
+		{"duplicate label", "a: .byte 1\na: .byte 2", "already defined"},
+		{"unknown directive", ".bogus 1", "unknown directive"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := Assemble(tt.src)
+			if err == nil || !strings.Contains(err.Error(), tt.want) {
+				t.Errorf("Assemble(%q) error = %v, want one containing %q", tt.src, err, tt.want)
+			}
+		})
+	}
+}
+
+func TestCodeRanges(t *testing.T) {
+	src := `
+	NOP
+	LIW R1, 5
+	count: .word 0
+	HALT
+	msg: .asciiz "x"
+	.org 0x100
+	ADD R1, R1, R1
+	HALT`
+	p, err := Assemble(src)
+	if err != nil {
+		t.Fatalf("Assemble: %v", err)
+	}
+	want := []emulator.CodeRange{{Start: 0, End: 5}, {Start: 8, End: 9}, {Start: 0x100, End: 0x103}}
+	if !slices.Equal(p.Code, want) {
+		t.Errorf("Code = %v, want %v", p.Code, want)
+	}
+	if got := p.Image().Code; !slices.Equal(got, want) {
+		t.Errorf("Image().Code = %v, want %v", got, want)
+	}
+}
+
+func TestOrg(t *testing.T) {
+	src := `
+	.org 0x100
+	start: LIW R1, data
+	NOP
+	HALT
+	.org 0x200
+	data: .word 0xBEEF`
+	p, err := Assemble(src)
+	if err != nil {
+		t.Fatalf("Assemble: %v", err)
+	}
+	if len(p.Segments) != 2 || p.Segments[0].Address != 0x100 || p.Segments[1].Address != 0x200 {
+		t.Fatalf("segments = %+v, want two at 0x100 and 0x200", p.Segments)
+	}
+	if !bytes.Equal(p.Segments[1].Data, []byte{0xBE, 0xEF}) {
+		t.Errorf("data segment = % X, want BE EF", p.Segments[1].Data)
+	}
+	if p.Entry != 0x100 || p.Labels["start"] != 0x100 || p.Labels["data"] != 0x200 {
+		t.Errorf("entry 0x%04X, labels %v; want entry and start at 0x100, data at 0x200", p.Entry, p.Labels)
+	}
+
+	// The loader places each segment at its address, leaving the gap alone.
+	p, err = Assemble("HALT\n.org 8\n.word 0xBEEF")
+	if err != nil {
+		t.Fatalf("Assemble: %v", err)
+	}
+	c := emulator.New(emulator.WithLogger(slog.New(slog.DiscardHandler)), emulator.WithMemoryFill(0x5555))
+	if err := c.LoadImage("org", p.Image()); err != nil {
+		t.Fatalf("LoadImage: %v", err)
+	}
+	want := []byte{0xF0, 0x00, 0x55, 0x55, 0x55, 0x55, 0x55, 0x55, 0xBE, 0xEF}
+	if got, _ := c.ReadMemory(0, len(want)); !bytes.Equal(got, want) {
+		t.Errorf("memory = % X, want % X", got, want)
+	}
+}
+
+func TestOrgOverlap(t *testing.T) {
+	tests := []struct {
+		name string
+		src  string
+	}{
+		{"later segment inside earlier", ".org 0x10\n.word 1, 2, 3\n.org 0x12\n.word 4"},
+		{"earlier address after", ".org 0x20\n.word 1\n.org 0x1E\n.word 2, 3"},
+		{"same address", "NOP\n.org 0\nHALT"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := Assemble(tt.src)
+			if err == nil || !strings.Contains(err.Error(), "overlaps") {
+				t.Errorf("Assemble(%q) error = %v, want an overlap", tt.src, err)
+			}
+		})
+	}
+	if _, err := Assemble(".org 0x10\n.word 1\n.org 0x12\n.word 2"); err != nil {
+		t.Errorf("adjacent segments: %v", err)
+	}
+}
+
+func TestLines(t *testing.T) {
+	tests := []struct {
+		name   string
+		src    string
+		lines  map[uint16]int
+		source map[uint16]string
+	}{
+		{
+			name:   "instructions",
+			src:    "NOP\nLIW R1, 5\nHALT",
+			lines:  map[uint16]int{0: 1, 2: 2, 6: 3},
+			source: map[uint16]string{0: "NOP", 2: "LIW R1, 5", 6: "HALT"},
+		},
+		{
+			name:   "blank lines, comments and labels",
+			src:    "; start\n\nloop: NOP\n\t# again\n\tBZ SR, loop ; back",
+			lines:  map[uint16]int{0: 3, 2: 5},
+			source: map[uint16]string{0: "loop: NOP", 2: "BZ SR, loop ; back"},
+		},
+		{
+			name:   "data and org",
+			src:    "HALT\n.org 0x10\nmsg: .asciiz \"hi\"\n.word 1",
+			lines:  map[uint16]int{0: 1, 0x10: 3, 0x14: 4},
+			source: map[uint16]string{0: "HALT", 0x10: `msg: .asciiz "hi"`, 0x14: ".word 1"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			p, err := Assemble(tt.src)
+			if err != nil {
+				t.Fatalf("Assemble: %v", err)
+			}
+			if !maps.Equal(p.Lines, tt.lines) {
+				t.Errorf("Lines = %v, want %v", p.Lines, tt.lines)
+			}
+			if got := p.Image().Source; !maps.Equal(got, tt.source) {
+				t.Errorf("Image().Source = %q, want %q", got, tt.source)
+			}
+		})
+	}
+}
+
+func TestLIW(t *testing.T) {
+	tests := []struct {
+		name string
+		src  string
+		want []byte
+		err  string
+	}{
+		{"hex", "LIW R1, 0xBEEF", []byte{0xB1, 0x0B, 0xBE, 0xEF}, ""},
+		{"negative", "LIW R2, -1", []byte{0xB2, 0x0B, 0xFF, 0xFF}, ""},
+		{"label", "LIW SP, end\nend: HALT", []byte{0xBA, 0x0B, 0x00, 0x04, 0xF0, 0x00}, ""},
+		{"too large", "LIW R1, 0x10000", nil, "out of range"},
+		{"missing value", "LIW R1", nil, "takes 2 operands"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			p, err := Assemble(tt.src)
+			if tt.err != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.err) {
+					t.Errorf("Assemble(%q) error = %v, want one containing %q", tt.src, err, tt.err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Assemble: %v", err)
+			}
+			if got := p.Bytes(); !bytes.Equal(got, tt.want) {
+				t.Errorf("bytes = % X, want % X", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestRegisterAliases(t *testing.T) {
+	aliases := map[string]string{"R4": "A0", "R5": "t0", "SP": "STACK"}
+	tests := []struct {
+		name string
+		src  string
+		want string // the same program with canonical names; empty if it fails
+	}{
+		{"alias", "ADD A0, T0, R1", "ADD R4, R5, R1"},
+		{"any case", "ADDI a0, r0, 1\nSUB stack, Stack, t0", "ADDI R4, R0, 1\nSUB SP, SP, R5"},
+		{"LIW", "LIW A0, 0x1234", "LIW R4, 0x1234"},
+		{"canonical names still work", "ADD R4, R5, SP", "ADD R4, R5, SP"},
+		{"unknown alias", "ADD A1, R5, R1", ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			p, err := Assemble(tt.src, WithRegisterAliases(aliases))
+			if tt.want == "" {
+				if err == nil {
+					t.Errorf("Assemble(%q) succeeded, want an error", tt.src)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Assemble(%q): %v", tt.src, err)
+			}
+			want, err := Assemble(tt.want)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !bytes.Equal(p.Bytes(), want.Bytes()) {
+				t.Errorf("Assemble(%q) = % X, want % X as for %q", tt.src, p.Bytes(), want.Bytes(), tt.want)
+			}
+		})
+	}
+	if _, err := Assemble("ADD A0, R5, R1"); err == nil {
+		t.Error("an alias assembled without WithRegisterAliases")
+	}
+}
+
+func TestConstants(t *testing.T) {
+	tests := []struct {
+		name string
+		src  string
+		want []byte
+		err  string
+	}{
+		{".equ", ".equ COUNT 5\nADDI R1, R0, COUNT", []byte{0x91, 0x05}, ""},
+		{".equ with a comma", ".equ COUNT, 0x10\nADDI R1, R1, COUNT", []byte{0x91, 0x10}, ""},
+		{"assignment", "COUNT = -1\nADDI R1, SR, COUNT", []byte{0x91, 0xFF}, ""},
+		{"used before defined", "LIW R1, MAX\nMAX = 0xBEEF", []byte{0xB1, 0x0B, 0xBE, 0xEF}, ""},
+		{"in data", "SIZE = 'A'\n.byte SIZE\n.byte 0\n.word SIZE", []byte{'A', 0, 0x00, 'A'}, ""},
+		{"refers to a label", "ADDR = end\nLIW R1, ADDR\nend: HALT", []byte{0xB1, 0x0B, 0x00, 0x04, 0xF0, 0x00}, ""},
+		{"refers to a constant", ".equ A 3\n.equ B A\nADDI R1, R0, B", []byte{0x91, 0x03}, ""},
+		{"emits nothing", "X = 1\n.equ Y 2\nHALT", []byte{0xF0, 0x00}, ""},
+		{"redefined", "X = 1\nX = 2", nil, `"X" already defined`},
+		{"redefines a label", "X: HALT\n.equ X 2", nil, `"X" already defined`},
+		{"label redefines it", "X = 1\nX: HALT", nil, `label "X" already defined`},
+		{"cycle", "A = B\nB = A", nil, "defined in terms of itself"},
+		{"unresolved, even unused", "X = nowhere\nHALT", nil, "X:"},
+		{".equ without a value", ".equ X", nil, ".equ needs a name and a value"},
+		{"out of range", "BIG = 0x100\nADDI R1, R0, BIG", nil, "out of range"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			p, err := Assemble(tt.src)
+			if tt.err != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.err) {
+					t.Errorf("Assemble(%q) error = %v, want one containing %q", tt.src, err, tt.err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Assemble: %v", err)
+			}
+			if got := p.Bytes(); !bytes.Equal(got, tt.want) {
+				t.Errorf("bytes = % X, want % X", got, tt.want)
+			}
+			for _, name := range []string{"COUNT", "MAX", "SIZE", "ADDR", "A", "B", "X", "Y"} {
+				if _, ok := p.Labels[name]; ok {
+					t.Errorf("labels = %v, want constant %s kept out of them", p.Labels, name)
+				}
+			}
+		})
+	}
+}
+
+func TestImmediateInstructionsRun(t *testing.T) {
+	tests := []struct {
+		name string
+		src  string
+		reg  int // the register to check once the program halts
+		want uint16
+	}{
+		{"ADDI from R0", "ADDI R1, R0, 5\nHALT", 1, 5},
+		{"ADDI adds the whole immediate", "LIW R2, 0x100\nADDI R1, R2, 0x25\nHALT", 1, 0x125},
+		{"ADDI of a negative immediate", "ADDI R1, SR, -1\nHALT", 1, 0xFFFF},
+		{"SUBI", "LIW R3, 0x1000\nSUBI R1, R3, 0x31\nHALT", 1, 0x0FCF},
+		{"LW", "LW R1, R0, 4\nHALT\n.word 0xBEEF", 1, 0xBEEF},
+		{"SW then LW", "LIW R1, 0xCAFE\nSW R1, R0, 12\nLW R3, R0, 12\nHALT", 3, 0xCAFE},
+		{"BZ forward taken", "BZ R0, skip\nLIW R1, 1\nskip: HALT", 1, 0},
+		{"BZ forward not taken", "LIW R0, 1\nBZ R0, skip\nLIW R1, 1\nskip: HALT", 1, 1},
+		{"BZ backward", `
+	ADDI R2, R0, 1
+	ADDI R3, R0, 3
+loop:	ADD  R1, R1, R2
+	SUB  R0, R3, R1
+	BZ   R0, done
+	BZ   SR, loop    ; SR is clear, so always taken
+done:	HALT`, 1, 3},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			p, err := Assemble(tt.src)
+			if err != nil {
+				t.Fatalf("Assemble: %v", err)
+			}
+			c := emulator.New(emulator.WithLogger(slog.New(slog.DiscardHandler)), emulator.WithWatchdog(1000, 0))
+			if err := c.LoadImage("test", p.Image()); err != nil {
+				t.Fatal(err)
+			}
+			if r := c.RunToCompletion(); r.HaltReason != emulator.HaltInstruction {
+				t.Fatalf("halt reason %q, want %q", r.HaltReason, emulator.HaltInstruction)
+			}
+			if got := c.Registers[tt.reg]; got != tt.want {
+				t.Errorf("%s = 0x%04X, want 0x%04X", emulator.RegisterNames[tt.reg], got, tt.want)
+			}
+		})
+	}
+}
+
+func TestImmediateRegisterMismatch(t *testing.T) {
+	tests := []struct {
+		name string
+		src  string
+		err  string
+	}{
+		{"ADDI", "ADDI R1, R1, 5", "ADDI reads the register selected by the high nibble of its immediate, and 5 selects R0, not R1"},
+		{"negative", "SUBI R1, R0, -1", "-1 selects SR, not R0"},
+		{"LW", "LW R1, R2, 0x30", "48 selects R3, not R2"},
+		{"SW", "SW R1, SP, 4", "4 selects R0, not SP"},
+		{"BZ forward", "BZ R1, end\nend: HALT", "0 selects R0, not R1"},
+		{"BZ backward", "loop: BZ R0, loop", "-1 selects SR, not R0"},
+		{"rs missing", "ADDI R1, 5", "ADDI takes 3 operands, got 2"},
+		{"BZ rs missing", "loop: BZ loop", "BZ takes 2 operands, got 1"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := Assemble(tt.src); err == nil || !strings.Contains(err.Error(), tt.err) {
+				t.Errorf("Assemble(%q) error = %v, want one containing %q", tt.src, err, tt.err)
+			}
+		})
+	}
+}
+
+func TestDisassemblyReassembles(t *testing.T) {
+	src := "ADDI R1, SR, -1\nSUBI R2, R7, 0x7F\nLW R3, R0, 2\nSW R4, R1, 0x10\nloop: BZ SR, loop\nBZ R0, end\nend: HALT"
+	p, err := Assemble(src)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var lines []string
+	for _, line := range emulator.Disassemble(p.Bytes(), 0) {
+		lines = append(lines, line.Text)
+	}
+	again, err := Assemble(strings.Join(lines, "\n"))
+	if err != nil {
+		t.Fatalf("reassembling %q: %v", lines, err)
+	}
+	if !bytes.Equal(again.Bytes(), p.Bytes()) {
+		t.Errorf("reassembled % X, want % X", again.Bytes(), p.Bytes())
+	}
+}
@@ -0,0 +1,68 @@
+package disk
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"sync"
+)
+
+// BlockFile is a block device stored as a file on the disk, so that
+// programs can use it as secondary storage. Blocks never written read as
+// zeros, and the file grows only as far as the highest block written.
+type BlockFile struct {
+	mu        sync.Mutex
+	name      string
+	blockSize int
+	blocks    int
+}
+
+// NewBlockFile returns a device of blocks blocks of blockSize bytes each,
+// stored in the named file, such as "disk/data/blocks.img".
+func NewBlockFile(name string, blockSize, blocks int) *BlockFile {
+	return &BlockFile{name: name, blockSize: blockSize, blocks: blocks}
+}
+
+// Blocks returns how many blocks the device holds.
+func (f *BlockFile) Blocks() int {
+	return f.blocks
+}
+
+// ReadBlock fills buf with block n.
+func (f *BlockFile) ReadBlock(n int, buf []byte) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	start, err := f.offset(n, buf)
+	if err != nil {
+		return err
+	}
+	read, err := ReadFileAt(f.name, buf, start)
+	if errors.Is(err, fs.ErrNotExist) {
+		read, err = 0, nil
+	}
+	clear(buf[read:])
+	return err
+}
+
+// WriteBlock stores buf as block n.
+func (f *BlockFile) WriteBlock(n int, buf []byte) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	start, err := f.offset(n, buf)
+	if err != nil {
+		return err
+	}
+	return WriteFileAt(f.name, buf, start)
+}
+
+// offset returns where block n starts in the file, checking that n is a
+// block of the device and buf is one block long.
+func (f *BlockFile) offset(n int, buf []byte) (int, error) {
+	if n < 0 || n >= f.blocks {
+		return 0, fmt.Errorf("block %d is outside the %d-block device", n, f.blocks)
+	}
+	if len(buf) != f.blockSize {
+		return 0, fmt.Errorf("buffer of %d bytes is not one %d-byte block", len(buf), f.blockSize)
+	}
+	return n * f.blockSize, nil
+}
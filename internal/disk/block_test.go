@@ -0,0 +1,55 @@
+package disk
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestBlockFile(t *testing.T) {
+	dir := t.TempDir()
+	if err := SetOverlayDir(dir); err != nil {
+		t.Fatal(err)
+	}
+	const name = "disk/data/test-blocks.img"
+	f := NewBlockFile(name, 4, 8)
+
+	buf := make([]byte, 4)
+	if err := f.ReadBlock(3, buf); err != nil || !bytes.Equal(buf, make([]byte, 4)) {
+		t.Fatalf("unwritten block = % X, %v; want zeros", buf, err)
+	}
+	writes := []struct {
+		block int
+		data  string
+	}{{2, "abcd"}, {0, "wxyz"}, {2, "ABCD"}}
+	for _, w := range writes {
+		if err := f.WriteBlock(w.block, []byte(w.data)); err != nil {
+			t.Fatalf("WriteBlock(%d): %v", w.block, err)
+		}
+	}
+	for block, want := range map[int]string{0: "wxyz", 1: "\x00\x00\x00\x00", 2: "ABCD"} {
+		if err := f.ReadBlock(block, buf); err != nil || string(buf) != want {
+			t.Errorf("block %d = %q, %v; want %q", block, buf, err, want)
+		}
+	}
+	// The file holds only as much as the highest block written, and is
+	// persisted in the overlay directory.
+	got, err := os.ReadFile(filepath.Join(dir, filepath.FromSlash(name)))
+	if want := "wxyz\x00\x00\x00\x00ABCD"; err != nil || string(got) != want {
+		t.Errorf("persisted file = %q, %v; want %q", got, err, want)
+	}
+
+	for _, tt := range []struct {
+		name  string
+		block int
+		size  int
+	}{{"block out of range", 8, 4}, {"negative block", -1, 4}, {"short buffer", 0, 3}} {
+		if err := f.WriteBlock(tt.block, make([]byte, tt.size)); err == nil {
+			t.Errorf("%s: WriteBlock succeeded", tt.name)
+		}
+		if err := f.ReadBlock(tt.block, make([]byte, tt.size)); err == nil {
+			t.Errorf("%s: ReadBlock succeeded", tt.name)
+		}
+	}
+}
@@ -1,6 +1,17 @@
 package disk
 
-import "embed"
+import (
+	"embed"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+)
 
 // FS represents the embedded disk filesystem.
 // The `all:` prefix includes all files in the directory,
@@ -8,3 +19,193 @@ import "embed"
 
 //go:embed disk
 var FS embed.FS
+
+// The overlay holds files written at runtime. Reads check it before falling
+// back to the embedded FS, so a written file shadows an embedded one with the
+// same name. When an overlay directory is configured, writes are persisted
+// there and reloaded on startup so they survive restarts.
+var (
+	overlayMu  sync.RWMutex
+	overlay    = make(map[string][]byte)
+	overlayDir string
+)
+
+// SetOverlayDir persists the writable overlay in dir, creating it if needed
+// and loading any files already stored there.
+func SetOverlayDir(dir string) error {
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return err
+	}
+
+	files := make(map[string][]byte)
+	err := filepath.WalkDir(dir, func(p string, d fs.DirEntry, err error) error {
+		if err != nil || d.IsDir() {
+			return err
+		}
+		rel, err := filepath.Rel(dir, p)
+		if err != nil {
+			return err
+		}
+		data, err := os.ReadFile(p)
+		if err != nil {
+			return err
+		}
+		files[filepath.ToSlash(rel)] = data
+		return nil
+	})
+	if err != nil {
+		return fmt.Errorf("loading overlay: %w", err)
+	}
+
+	overlayMu.Lock()
+	defer overlayMu.Unlock()
+	overlayDir = dir
+	for name, data := range files {
+		overlay[name] = data
+	}
+	return nil
+}
+
+// ReadFile returns the contents of the named file, such as "disk/bin/ls",
+// preferring a version written at runtime over the embedded one.
+func ReadFile(name string) ([]byte, error) {
+	overlayMu.RLock()
+	data, ok := overlay[name]
+	overlayMu.RUnlock()
+	if ok {
+		return append([]byte(nil), data...), nil
+	}
+	return fs.ReadFile(FS, name)
+}
+
+// WriteFile stores data under name in the overlay, persisting it to the
+// overlay directory if one is configured. Names must lie within "disk/".
+func WriteFile(name string, data []byte) error {
+	if !fs.ValidPath(name) || !strings.HasPrefix(name, "disk/") {
+		return &fs.PathError{Op: "write", Path: name, Err: fs.ErrInvalid}
+	}
+
+	overlayMu.Lock()
+	defer overlayMu.Unlock()
+	if overlayDir != "" {
+		p := filepath.Join(overlayDir, filepath.FromSlash(name))
+		if err := os.MkdirAll(filepath.Dir(p), 0o755); err != nil {
+			return err
+		}
+		if err := os.WriteFile(p, data, 0o644); err != nil {
+			return err
+		}
+	}
+	overlay[name] = append([]byte(nil), data...)
+	return nil
+}
+
+// ReadFileAt reads len(buf) bytes of the named file starting at offset off
+// into buf, like ReadFile without copying the rest of the file. It returns
+// how many bytes it read, fewer than len(buf) if the file ends first.
+func ReadFileAt(name string, buf []byte, off int) (int, error) {
+	overlayMu.RLock()
+	data, ok := overlay[name]
+	n := 0
+	if ok && off < len(data) {
+		n = copy(buf, data[off:])
+	}
+	overlayMu.RUnlock()
+	if ok {
+		return n, nil
+	}
+	data, err := fs.ReadFile(FS, name)
+	if err != nil {
+		return 0, err
+	}
+	if off < len(data) {
+		n = copy(buf, data[off:])
+	}
+	return n, nil
+}
+
+// WriteFileAt writes data into the named file at offset off, creating the
+// file or zero filling it up to off as needed, and leaves the rest of the
+// file as it was. Unlike WriteFile, a file already in the overlay directory
+// is updated in place rather than rewritten.
+func WriteFileAt(name string, data []byte, off int) error {
+	if !fs.ValidPath(name) || !strings.HasPrefix(name, "disk/") {
+		return &fs.PathError{Op: "write", Path: name, Err: fs.ErrInvalid}
+	}
+
+	overlayMu.Lock()
+	defer overlayMu.Unlock()
+	contents, inOverlay := overlay[name]
+	if !inOverlay {
+		// Start from the embedded file, if there is one.
+		embedded, err := fs.ReadFile(FS, name)
+		if err != nil && !errors.Is(err, fs.ErrNotExist) {
+			return err
+		}
+		contents = embedded
+	}
+	if end := off + len(data); end > len(contents) {
+		contents = append(contents, make([]byte, end-len(contents))...)
+	}
+	copy(contents[off:], data)
+
+	if overlayDir != "" {
+		p := filepath.Join(overlayDir, filepath.FromSlash(name))
+		if err := os.MkdirAll(filepath.Dir(p), 0o755); err != nil {
+			return err
+		}
+		if !inOverlay {
+			if err := os.WriteFile(p, contents, 0o644); err != nil {
+				return err
+			}
+		} else if err := writeAt(p, data, off); err != nil {
+			return err
+		}
+	}
+	overlay[name] = contents
+	return nil
+}
+
+// writeAt writes data at offset off of the file at path p.
+func writeAt(p string, data []byte, off int) error {
+	f, err := os.OpenFile(p, os.O_WRONLY|os.O_CREATE, 0o644)
+	if err != nil {
+		return err
+	}
+	if _, err := f.WriteAt(data, int64(off)); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
+
+// ReadDir returns the sorted names of the files directly inside dir, merging
+// embedded files with those written at runtime.
+func ReadDir(dir string) ([]string, error) {
+	seen := make(map[string]bool)
+	entries, err := fs.ReadDir(FS, dir)
+	if err != nil && !errors.Is(err, fs.ErrNotExist) {
+		return nil, err
+	}
+	for _, entry := range entries {
+		seen[entry.Name()] = true
+	}
+
+	overlayMu.RLock()
+	for name := range overlay {
+		if path.Dir(name) == dir {
+			seen[path.Base(name)] = true
+		}
+	}
+	overlayMu.RUnlock()
+
+	if err != nil && len(seen) == 0 {
+		return nil, err
+	}
+	names := make([]string, 0, len(seen))
+	for name := range seen {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names, nil
+}
@@ -0,0 +1,116 @@
+package disk
+
+import (
+	"bytes"
+	"errors"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"slices"
+	"testing"
+)
+
+// freshOverlay gives the test an empty overlay persisted in a temporary
+// directory, which it returns, and restores the previous overlay afterwards.
+func freshOverlay(t *testing.T) string {
+	t.Helper()
+	overlayMu.Lock()
+	saved, savedDir := overlay, overlayDir
+	overlay, overlayDir = make(map[string][]byte), ""
+	overlayMu.Unlock()
+	t.Cleanup(func() {
+		overlayMu.Lock()
+		overlay, overlayDir = saved, savedDir
+		overlayMu.Unlock()
+	})
+	dir := t.TempDir()
+	if err := SetOverlayDir(dir); err != nil {
+		t.Fatal(err)
+	}
+	return dir
+}
+
+func TestWriteFile(t *testing.T) {
+	const embedded = "disk/src/hello_world.asm"
+	original, err := fs.ReadFile(FS, embedded)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		name string
+		file string
+		data string
+	}{
+		{"new file", "disk/out/result.txt", "42\n"},
+		{"overrides embedded file", embedded, "; replaced\n"},
+		{"empty file", "disk/out/empty", ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dir := freshOverlay(t)
+			if err := WriteFile(tt.file, []byte(tt.data)); err != nil {
+				t.Fatal(err)
+			}
+			if got, err := ReadFile(tt.file); err != nil || string(got) != tt.data {
+				t.Errorf("ReadFile = %q, %v; want %q", got, err, tt.data)
+			}
+			persisted, err := os.ReadFile(filepath.Join(dir, filepath.FromSlash(tt.file)))
+			if err != nil || string(persisted) != tt.data {
+				t.Errorf("persisted file = %q, %v; want %q", persisted, err, tt.data)
+			}
+
+			// After a restart the overlay is loaded from the directory.
+			overlayMu.Lock()
+			overlay = make(map[string][]byte)
+			overlayMu.Unlock()
+			if err := SetOverlayDir(dir); err != nil {
+				t.Fatal(err)
+			}
+			if got, err := ReadFile(tt.file); err != nil || string(got) != tt.data {
+				t.Errorf("ReadFile after restart = %q, %v; want %q", got, err, tt.data)
+			}
+		})
+	}
+
+	// The embedded file itself is unchanged.
+	if got, _ := fs.ReadFile(FS, embedded); !bytes.Equal(got, original) {
+		t.Error("embedded file changed")
+	}
+}
+
+func TestWriteFileInvalidName(t *testing.T) {
+	freshOverlay(t)
+	for _, name := range []string{"", "bin/ls", "disk/../etc/passwd", "/disk/x", "disk/a//b"} {
+		err := WriteFile(name, []byte("x"))
+		if !errors.Is(err, fs.ErrInvalid) {
+			t.Errorf("WriteFile(%q) error = %v, want fs.ErrInvalid", name, err)
+		}
+	}
+}
+
+func TestReadDirMergesOverlay(t *testing.T) {
+	freshOverlay(t)
+	embedded, err := fs.ReadDir(FS, "disk/src")
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, name := range []string{"disk/src/new.asm", "disk/src/hello_world.asm"} {
+		if err := WriteFile(name, []byte("HALT\n")); err != nil {
+			t.Fatal(err)
+		}
+	}
+	names, err := ReadDir("disk/src")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(names) != len(embedded)+1 {
+		t.Errorf("ReadDir = %v, want the %d embedded entries plus new.asm", names, len(embedded))
+	}
+	if !slices.Contains(names, "new.asm") || !slices.IsSorted(names) {
+		t.Errorf("ReadDir = %v, want sorted names including new.asm", names)
+	}
+
+	if names, err := ReadDir("disk/nothing"); err == nil {
+		t.Errorf("ReadDir of a missing directory = %v, want an error", names)
+	}
+}
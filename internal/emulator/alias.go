@@ -0,0 +1,63 @@
+package emulator
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+)
+
+// SetRegisterAlias makes alias the name registers[canonical] is shown by, in
+// GetState's named registers and the disassembly of memory, so the machine
+// can match the names a course uses, such as "A0" for R4. canonical is a
+// name from RegisterNames; the register numbering itself never changes. An
+// empty alias removes the register's alias. Aliases may not clash with
+// another register's name or alias.
+func (c *MonTanaMiniComputer) SetRegisterAlias(canonical, alias string) error {
+	reg := slices.IndexFunc(RegisterNames[:], func(name string) bool { return strings.EqualFold(name, canonical) })
+	if reg < 0 {
+		return fmt.Errorf("unknown register %q", canonical)
+	}
+	alias = strings.ToUpper(alias)
+	if alias != "" && !cIdentifier.MatchString(alias) {
+		return fmt.Errorf("alias %q is not a valid name", alias)
+	}
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	for i := range RegisterNames {
+		if i != reg && alias != "" && (RegisterNames[i] == alias || c.aliases[i] == alias) {
+			return fmt.Errorf("alias %q is already the name of %s", alias, RegisterNames[i])
+		}
+	}
+	c.aliases[reg] = alias
+	c.notifyObservers()
+	return nil
+}
+
+// RegisterAliases returns the aliased registers' aliases, by canonical name.
+func (c *MonTanaMiniComputer) RegisterAliases() map[string]string {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	return c.aliasMap()
+}
+
+func (c *MonTanaMiniComputer) aliasMap() map[string]string {
+	aliases := make(map[string]string)
+	for i, alias := range c.aliases {
+		if alias != "" {
+			aliases[RegisterNames[i]] = alias
+		}
+	}
+	return aliases
+}
+
+// registerNames returns the name each register is shown by: its alias, or
+// else its canonical name.
+func (c *MonTanaMiniComputer) registerNames() *[16]string {
+	names := RegisterNames
+	for i, alias := range c.aliases {
+		if alias != "" {
+			names[i] = alias
+		}
+	}
+	return &names
+}
@@ -0,0 +1,82 @@
+package emulator
+
+import (
+	"maps"
+	"strings"
+	"testing"
+)
+
+func TestSetRegisterAlias(t *testing.T) {
+	tests := []struct {
+		name             string
+		canonical, alias string
+		err              string
+		want             map[string]string // aliases afterwards, on top of R4's A0
+	}{
+		{"alias", "R5", "T0", "", map[string]string{"R4": "A0", "R5": "T0"}},
+		{"any case", "sp", "stack", "", map[string]string{"R4": "A0", "SP": "STACK"}},
+		{"replace", "R4", "ARG", "", map[string]string{"R4": "ARG"}},
+		{"remove", "R4", "", "", map[string]string{}},
+		{"unknown register", "R9", "X", "unknown register", map[string]string{"R4": "A0"}},
+		{"invalid alias", "R5", "1st", "not a valid name", map[string]string{"R4": "A0"}},
+		{"another register's name", "R5", "sp", "already the name of SP", map[string]string{"R4": "A0"}},
+		{"another register's alias", "R5", "a0", "already the name of R4", map[string]string{"R4": "A0"}},
+		{"its own name", "R4", "R4", "", map[string]string{"R4": "R4"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := newTestComputer(t)
+			if err := c.SetRegisterAlias("R4", "A0"); err != nil {
+				t.Fatal(err)
+			}
+			err := c.SetRegisterAlias(tt.canonical, tt.alias)
+			if tt.err == "" && err != nil || tt.err != "" && (err == nil || !strings.Contains(err.Error(), tt.err)) {
+				t.Fatalf("SetRegisterAlias(%q, %q) error = %v, want %q", tt.canonical, tt.alias, err, tt.err)
+			}
+			if got := c.RegisterAliases(); !maps.Equal(got, tt.want) {
+				t.Errorf("RegisterAliases() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestRegisterAliasesShown(t *testing.T) {
+	c := newTestComputer(t)
+	load(t, c, program(t, liw(R4, 7), rrr(OpADD, R5, R4, SP), halt))
+	for canonical, alias := range map[string]string{"R4": "A0", "R5": "T0"} {
+		if err := c.SetRegisterAlias(canonical, alias); err != nil {
+			t.Fatal(err)
+		}
+	}
+	stepN(c, 1)
+
+	state := c.GetState()
+	named := state["namedRegisters"].(map[string]uint16)
+	tests := []struct {
+		name    string
+		present bool
+		value   uint16
+	}{
+		{"A0", true, 7},
+		{"T0", true, 0},
+		{"SP", true, testMemorySize - WordSize},
+		{"R4", false, 0},
+		{"R5", false, 0},
+	}
+	for _, tt := range tests {
+		if v, ok := named[tt.name]; ok != tt.present || v != tt.value {
+			t.Errorf("named register %s = %d (present %v), want %d (present %v)", tt.name, v, ok, tt.value, tt.present)
+		}
+	}
+	if got, want := state["instruction"], "ADD T0, A0, SP"; got != want {
+		t.Errorf("state instruction %q, want %q", got, want)
+	}
+	if lines := c.DisassembleMemory(0, 2); len(lines) != 1 || lines[0].Text != "LIW A0, 0x0007" {
+		t.Errorf("DisassembleMemory(0, 2) = %+v, want LIW A0, 0x0007", lines)
+	}
+	// Disassembling bytes, apart from any machine, keeps canonical names.
+	code, _ := c.ReadMemory(4, WordSize)
+	if lines := Disassemble(code, 4); lines[0].Text != "ADD R5, R4, SP" {
+		t.Errorf("Disassemble = %q, want ADD R5, R4, SP", lines[0].Text)
+	}
+}
@@ -0,0 +1,44 @@
+package emulator
+
+import "maps"
+
+// AnnotateAddress attaches note to addr, such as "loop start" or "counter",
+// for debuggers to show alongside memory and disassembly. An empty note
+// removes the annotation. Annotations are the user's, not the program's, so
+// they are kept when the program is reloaded but cleared by Reset.
+func (c *MonTanaMiniComputer) AnnotateAddress(addr uint16, note string) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	if note == "" {
+		delete(c.annotations, addr)
+	} else {
+		if c.annotations == nil {
+			c.annotations = make(map[uint16]string)
+		}
+		c.annotations[addr] = note
+	}
+	c.notifyObservers()
+}
+
+// Annotations returns the annotations of the addresses from start up to,
+// but not including, end.
+func (c *MonTanaMiniComputer) Annotations(start, end int) map[uint16]string {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	notes := make(map[uint16]string)
+	for addr, note := range c.annotations {
+		if int(addr) >= start && int(addr) < end {
+			notes[addr] = note
+		}
+	}
+	return notes
+}
+
+// allAnnotations returns a copy of every annotation, never nil so it
+// encodes as an empty JSON object.
+func (c *MonTanaMiniComputer) allAnnotations() map[uint16]string {
+	if c.annotations == nil {
+		return map[uint16]string{}
+	}
+	return maps.Clone(c.annotations)
+}
@@ -0,0 +1,73 @@
+package emulator
+
+import (
+	"maps"
+	"testing"
+)
+
+func TestAnnotations(t *testing.T) {
+	notes := map[uint16]string{0: "start", 4: "loop", 0x40: "counter"}
+	tests := []struct {
+		name       string
+		change     func(*MonTanaMiniComputer)
+		start, end int
+		want       map[uint16]string
+	}{
+		{"all", nil, 0, 65536, notes},
+		{"range", nil, 2, 0x40, map[uint16]string{4: "loop"}},
+		{"end excluded", nil, 0, 4, map[uint16]string{0: "start"}},
+		{"empty range", nil, 6, 0x40, map[uint16]string{}},
+		{"replaced", func(c *MonTanaMiniComputer) { c.AnnotateAddress(4, "top") }, 0, 65536,
+			map[uint16]string{0: "start", 4: "top", 0x40: "counter"}},
+		{"empty note removes", func(c *MonTanaMiniComputer) { c.AnnotateAddress(4, "") }, 0, 65536,
+			map[uint16]string{0: "start", 0x40: "counter"}},
+		{"kept across steps", func(c *MonTanaMiniComputer) { stepN(c, 3) }, 0, 65536, notes},
+		{"kept on reload", func(c *MonTanaMiniComputer) {
+			if err := c.ReloadProgram(nil); err != nil {
+				t.Fatal(err)
+			}
+		}, 0, 65536, notes},
+		{"cleared by reset", (*MonTanaMiniComputer).Reset, 0, 65536, map[uint16]string{}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := newTestComputer(t)
+			load(t, c, counterLoop(t))
+			for addr, note := range notes {
+				c.AnnotateAddress(addr, note)
+			}
+			if tt.change != nil {
+				tt.change(c)
+			}
+			if got := c.Annotations(tt.start, tt.end); !maps.Equal(got, tt.want) {
+				t.Errorf("Annotations(%d, %d) = %v, want %v", tt.start, tt.end, got, tt.want)
+			}
+			all := c.GetState()["annotations"].(map[uint16]string)
+			if tt.start == 0 && tt.end == 65536 && !maps.Equal(all, tt.want) {
+				t.Errorf("state annotations = %v, want %v", all, tt.want)
+			}
+		})
+	}
+}
+
+func TestDisassembleAnnotations(t *testing.T) {
+	c := newTestComputer(t)
+	load(t, c, counterLoop(t))
+	c.AnnotateAddress(4, "loop")
+	c.AnnotateAddress(5, "mid-instruction")
+	want := map[uint16]string{0: "", 4: "loop", 6: ""}
+	for _, line := range c.DisassembleMemory(0, 4) {
+		if note, ok := want[line.Address]; ok && line.Annotation != note {
+			t.Errorf("line at 0x%04X annotated %q, want %q", line.Address, line.Annotation, note)
+		}
+		if line.Annotation == "mid-instruction" {
+			t.Errorf("line at 0x%04X shows the annotation of 0x0005", line.Address)
+		}
+	}
+	c.Reset()
+	for _, line := range c.DisassembleMemory(0, 4) {
+		if line.Annotation != "" {
+			t.Errorf("after Reset, line at 0x%04X annotated %q", line.Address, line.Annotation)
+		}
+	}
+}
@@ -0,0 +1,84 @@
+package emulator
+
+import (
+	"runtime"
+	"slices"
+	"sync"
+)
+
+// RunResult is the outcome of running one program to completion.
+type RunResult struct {
+	ExitCode   uint16     `json:"exitCode"`
+	Output     []byte     `json:"output"`
+	Registers  [16]uint16 `json:"registers"`
+	HaltReason HaltReason `json:"haltReason"`
+	Fault      *Fault     `json:"fault,omitempty"`
+	Steps      uint64     `json:"steps"`
+	// Error says why the program could not be loaded, if it could not; the
+	// other fields are then zero.
+	Error string `json:"error,omitempty"`
+}
+
+// RunToCompletion executes instructions back to back, without the clock or
+// observer notifications, until the machine stops. It starts the machine if
+// it is not already running. A program that never halts runs forever unless
+// a watchdog is configured. Auto-restart is suspended for the run, so it
+// stops at the first HALT and reports that program's result.
+func (c *MonTanaMiniComputer) RunToCompletion() RunResult {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	restart := c.autoRestart
+	c.autoRestart = false
+	defer func() { c.autoRestart = restart }()
+	c.Running = true
+	for c.Running {
+		c.step()
+	}
+	return c.result()
+}
+
+// result summarizes the machine's state after a run.
+func (c *MonTanaMiniComputer) result() RunResult {
+	return RunResult{
+		ExitCode:   c.exitCode,
+		Output:     append([]byte(nil), c.output...),
+		Registers:  c.Registers,
+		HaltReason: c.haltReason,
+		Fault:      c.lastFault,
+		Steps:      c.instructionCount,
+	}
+}
+
+// BatchRun runs each program on its own fresh machine, loaded at address 0
+// and fed the same input, and returns the results in the same order. A
+// program still running after maxSteps instructions is stopped by the
+// watchdog. Programs run in parallel, one per available CPU.
+func BatchRun(programs [][]byte, input []byte, maxSteps int, opts ...Option) []RunResult {
+	results := make([]RunResult, len(programs))
+	// Combine the options once: appending in each worker could have them
+	// all write the spare capacity of the caller's slice.
+	opts = slices.Concat(opts, []Option{WithWatchdog(uint64(max(maxSteps, 1)), 0)})
+	jobs := make(chan int)
+	var wg sync.WaitGroup
+	for range min(runtime.GOMAXPROCS(0), len(programs)) {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range jobs {
+				c := New(opts...)
+				if err := c.LoadProgram(programs[i], 0); err != nil {
+					results[i] = RunResult{Error: err.Error()}
+					continue
+				}
+				c.WriteInput(input)
+				results[i] = c.RunToCompletion()
+			}
+		}()
+	}
+	for i := range programs {
+		jobs <- i
+	}
+	close(jobs)
+	wg.Wait()
+	return results
+}
@@ -0,0 +1,97 @@
+package emulator
+
+import (
+	"testing"
+	"time"
+)
+
+func TestBatchRun(t *testing.T) {
+	programs := [][]byte{
+		program(t, liw(R0, 7), halt),
+		program(t, ext(ExtIN, R1, 0), ext(ExtOUT, R1, 0), ext(ExtIN, R0, 0), halt),
+		program(t, ri(OpBZ, 0, -1)), // branches to itself forever
+		{},
+	}
+	// Spare capacity in the caller's options must not be shared between
+	// the workers; run with -race to check.
+	opts := make([]Option, 0, 8)
+	results := BatchRun(programs, []byte("ab"), 50, opts...)
+
+	tests := []struct {
+		name     string
+		result   RunResult
+		exitCode uint16
+		output   string
+		halt     HaltReason
+		steps    uint64
+	}{
+		{"exit code", results[0], 7, "", HaltInstruction, 2},
+		{"echoes input", results[1], 'b', "a", HaltInstruction, 4},
+		{"stopped by watchdog", results[2], 0, "", HaltWatchdog, 50},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := tt.result
+			if r.Error != "" {
+				t.Fatalf("Error = %q", r.Error)
+			}
+			if r.ExitCode != tt.exitCode || string(r.Output) != tt.output || r.HaltReason != tt.halt || r.Steps != tt.steps {
+				t.Errorf("got exit %d, output %q, halt %q, steps %d; want %d, %q, %q, %d",
+					r.ExitCode, r.Output, r.HaltReason, r.Steps, tt.exitCode, tt.output, tt.halt, tt.steps)
+			}
+		})
+	}
+	if results[0].Registers[R0] != 7 || results[1].Registers[R1] != 'a' {
+		t.Errorf("registers not independent: R0=%d, R1=%d", results[0].Registers[R0], results[1].Registers[R1])
+	}
+	if results[3].Error != ErrEmptyProgram.Error() {
+		t.Errorf("empty program Error = %q, want %q", results[3].Error, ErrEmptyProgram)
+	}
+}
+
+func TestRunToCompletionWatchdog(t *testing.T) {
+	c := newTestComputer(t, WithWatchdog(10, 0))
+	load(t, c, program(t, nop, ri(OpBZ, 0, -2)))
+	result := c.RunToCompletion()
+	if result.HaltReason != HaltWatchdog || result.Steps != 10 {
+		t.Errorf("halt reason %q after %d steps, want %q after 10", result.HaltReason, result.Steps, HaltWatchdog)
+	}
+	if result.Fault == nil || result.Fault.Reason != HaltWatchdog {
+		t.Errorf("Fault = %+v, want a watchdog fault", result.Fault)
+	}
+}
+
+func TestRunToCompletionAutoRestart(t *testing.T) {
+	tests := []struct {
+		name    string
+		restart bool
+	}{
+		{"auto-restart off", false},
+		{"auto-restart on", true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := newTestComputer(t)
+			c.SetAutoRestart(tt.restart)
+			load(t, c, program(t, liw(R0, 7), halt))
+
+			done := make(chan RunResult)
+			go func() { done <- c.RunToCompletion() }()
+			var result RunResult
+			select {
+			case result = <-done:
+			case <-time.After(5 * time.Second):
+				t.Fatal("RunToCompletion did not return")
+			}
+			if result.HaltReason != HaltInstruction || result.ExitCode != 7 || result.Steps != 2 {
+				t.Errorf("halt reason %q, exit code %d after %d steps; want %q, 7 after 2", result.HaltReason, result.ExitCode, result.Steps, HaltInstruction)
+			}
+			if c.IsRunning() {
+				t.Error("machine still running after RunToCompletion")
+			}
+			if got := c.GetState()["autoRestart"]; got != tt.restart {
+				t.Errorf("autoRestart = %v after the run, want %v", got, tt.restart)
+			}
+		})
+	}
+}
@@ -0,0 +1,96 @@
+package emulator
+
+import "fmt"
+
+// The block device is secondary storage that programs move data to and from
+// a block of BlockSize bytes at a time, for data too large for memory. BSEEK
+// selects a block and BREAD and BWRITE copy it into or out of memory; the
+// selected block stays the same until the next BSEEK, so a program reading a
+// file sequentially seeks before each read.
+
+// BlockSize is the length in bytes of one block of the block device.
+const BlockSize = 256
+
+// Halt reasons for block device faults.
+const (
+	HaltBadBlock    HaltReason = "bad-block"
+	HaltDeviceError HaltReason = "device-error"
+)
+
+// BlockDevice is storage divided into numbered blocks of BlockSize bytes.
+type BlockDevice interface {
+	// Blocks returns how many blocks the device holds.
+	Blocks() int
+	// ReadBlock fills buf, which is BlockSize long, with block n.
+	ReadBlock(n int, buf []byte) error
+	// WriteBlock stores buf, which is BlockSize long, as block n.
+	WriteBlock(n int, buf []byte) error
+}
+
+// blockDevice is the state of the attached block device, if any.
+type blockDevice struct {
+	dev   BlockDevice
+	block int // the block BSEEK last selected
+}
+
+// WithBlockDevice attaches dev for programs to use with BSEEK, BREAD and
+// BWRITE. Without one, those instructions fault with HaltBadBlock. It panics
+// if a block would not fit in the machine's memory, since no BREAD or BWRITE
+// could then succeed.
+func WithBlockDevice(dev BlockDevice) Option {
+	return func(c *MonTanaMiniComputer) {
+		if BlockSize > len(c.Memory) {
+			panic(fmt.Sprintf("emulator: %d-byte blocks do not fit in %d bytes of memory", BlockSize, len(c.Memory)))
+		}
+		c.blocks.dev = dev
+	}
+}
+
+// seekBlock implements BSEEK, selecting block n.
+func (c *MonTanaMiniComputer) seekBlock(n uint16) HaltReason {
+	if c.blocks.dev == nil || int(n) >= c.blocks.dev.Blocks() {
+		return HaltBadBlock
+	}
+	c.blocks.block = int(n)
+	return HaltNone
+}
+
+// transferBlock implements BREAD (store false) and BWRITE (store true),
+// copying the selected block into or out of the BlockSize bytes of memory at
+// addr. It copies nothing and returns the reason if the memory range or the
+// block cannot be accessed.
+func (c *MonTanaMiniComputer) transferBlock(store bool, addr uint16) HaltReason {
+	if c.blocks.dev == nil {
+		return HaltBadBlock
+	}
+	end := int(addr) + BlockSize
+	if end > len(c.Memory) {
+		return HaltMemoryFault
+	}
+	buf := make([]byte, BlockSize)
+	if store {
+		for i := 0; i < BlockSize; i += WordSize {
+			v, _ := c.readWord(addr + uint16(i))
+			buf[i], buf[i+1] = byte(v>>8), byte(v)
+		}
+		if err := c.blocks.dev.WriteBlock(c.blocks.block, buf); err != nil {
+			c.logger.Warn("block device write failed", "block", c.blocks.block, "err", err)
+			return HaltDeviceError
+		}
+		return HaltNone
+	}
+	if c.isReadOnly(addr, uint16(end-1)) {
+		return HaltROMWrite
+	}
+	if c.selfModifyingFault(int(addr), end) {
+		return HaltSelfModifying
+	}
+	if err := c.blocks.dev.ReadBlock(c.blocks.block, buf); err != nil {
+		c.logger.Warn("block device read failed", "block", c.blocks.block, "err", err)
+		return HaltDeviceError
+	}
+	for i := 0; i < BlockSize; i += WordSize {
+		c.writeWord(addr+uint16(i), uint16(buf[i])<<8|uint16(buf[i+1]))
+	}
+	return HaltNone
+}
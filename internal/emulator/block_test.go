@@ -0,0 +1,111 @@
+package emulator
+
+import (
+	"bytes"
+	"errors"
+	"testing"
+)
+
+// memBlocks is a BlockDevice held in memory.
+type memBlocks struct {
+	blocks [][]byte
+	err    error // returned by every transfer if set
+}
+
+func newMemBlocks(n int) *memBlocks {
+	d := &memBlocks{}
+	for range n {
+		d.blocks = append(d.blocks, make([]byte, BlockSize))
+	}
+	return d
+}
+
+func (d *memBlocks) Blocks() int { return len(d.blocks) }
+
+func (d *memBlocks) ReadBlock(n int, buf []byte) error {
+	copy(buf, d.blocks[n])
+	return d.err
+}
+
+func (d *memBlocks) WriteBlock(n int, buf []byte) error {
+	copy(d.blocks[n], buf)
+	return d.err
+}
+
+func TestBlockDeviceRoundTrip(t *testing.T) {
+	const src, dst = 0x200, 0x400
+	dev := newMemBlocks(4)
+	c := newTestComputer(t, WithBlockDevice(dev))
+	// Write the block at src to block 2, then read it back into dst.
+	load(t, c, program(t,
+		liw(R1, 2), ext(ExtBSEEK, R1, 0),
+		liw(R2, src), ext(ExtBWRITE, R2, 0),
+		liw(R3, dst), ext(ExtBREAD, R3, 0),
+		halt,
+	))
+	want := make([]byte, BlockSize)
+	for i := range want {
+		want[i] = byte(i * 7)
+	}
+	if err := c.LoadData(want, src); err != nil {
+		t.Fatal(err)
+	}
+
+	if r := c.RunToCompletion(); r.HaltReason != HaltInstruction {
+		t.Fatalf("halt reason = %q, fault %+v", r.HaltReason, r.Fault)
+	}
+	if !bytes.Equal(dev.blocks[2], want) {
+		t.Errorf("block 2 = % X..., want % X...", dev.blocks[2][:8], want[:8])
+	}
+	if got, _ := c.ReadMemory(dst, BlockSize); !bytes.Equal(got, want) {
+		t.Errorf("memory read back = % X..., want % X...", got[:8], want[:8])
+	}
+}
+
+func TestBlockDeviceFaults(t *testing.T) {
+	tests := []struct {
+		name string
+		dev  *memBlocks
+		code func(t *testing.T) []byte
+		want HaltReason
+	}{
+		{"seek past last block", newMemBlocks(4), func(t *testing.T) []byte {
+			return program(t, liw(R1, 4), ext(ExtBSEEK, R1, 0), halt)
+		}, HaltBadBlock},
+		{"read past end of memory", newMemBlocks(4), func(t *testing.T) []byte {
+			return program(t, liw(R1, testMemorySize-BlockSize+2), ext(ExtBREAD, R1, 0), halt)
+		}, HaltMemoryFault},
+		{"write from past end of memory", newMemBlocks(4), func(t *testing.T) []byte {
+			return program(t, liw(R1, testMemorySize-2), ext(ExtBWRITE, R1, 0), halt)
+		}, HaltMemoryFault},
+		{"no device", nil, func(t *testing.T) []byte {
+			return program(t, ext(ExtBSEEK, R1, 0), halt)
+		}, HaltBadBlock},
+		{"device error", &memBlocks{blocks: newMemBlocks(1).blocks, err: errors.New("disk on fire")}, func(t *testing.T) []byte {
+			return program(t, liw(R1, 0x200), ext(ExtBREAD, R1, 0), halt)
+		}, HaltDeviceError},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var opts []Option
+			if tt.dev != nil {
+				opts = append(opts, WithBlockDevice(tt.dev))
+			}
+			c := newTestComputer(t, opts...)
+			load(t, c, tt.code(t))
+			if r := c.RunToCompletion(); r.HaltReason != tt.want {
+				t.Errorf("halt reason = %q, want %q", r.HaltReason, tt.want)
+			}
+		})
+	}
+}
+
+func TestWithBlockDeviceRejectsSmallMemory(t *testing.T) {
+	defer func() {
+		if recover() == nil {
+			t.Error("WithBlockDevice did not panic with blocks larger than memory")
+		}
+	}()
+	c := &MonTanaMiniComputer{Memory: make([]byte, BlockSize-WordSize)}
+	WithBlockDevice(newMemBlocks(1))(c)
+}
@@ -0,0 +1,220 @@
+package emulator
+
+import (
+	"fmt"
+	"slices"
+	"strconv"
+	"strings"
+)
+
+// Halt reasons for breakpoints.
+const (
+	// HaltBreakpoint is the halt reason when Run pauses at a breakpoint.
+	HaltBreakpoint HaltReason = "breakpoint"
+	// HaltSoftwareBreakpoint is the halt reason when the program executes
+	// BRK.
+	HaltSoftwareBreakpoint HaltReason = "software-breakpoint"
+)
+
+// breakpoints is the set of addresses at which Run pauses. Unlike most
+// machine state it survives Reset and reloads, since it belongs to the
+// debugging session rather than the program.
+type breakpoints struct {
+	// addrs maps each breakpoint address to its condition, or to nil if it
+	// is unconditional.
+	addrs map[uint16]*Condition
+	// resume is set once Run has paused at PC resumeAt, so that running
+	// again executes that instruction instead of pausing on it forever.
+	resume   bool
+	resumeAt uint16
+
+	snapshotLimit int // how many snapshots to keep; 0 disables them
+	snapshots     []BreakpointSnapshot
+}
+
+// Condition restricts a breakpoint to times when a register compares a
+// certain way against a value, such as "R1 == 5".
+type Condition struct {
+	Register int    `json:"register"`
+	Op       string `json:"op"` // one of ==, !=, < and >
+	Value    uint16 `json:"value"`
+}
+
+// conditionOps are the comparisons a Condition may make. < and > compare
+// as signed 16-bit values, so "R1 < 0" finds negative values.
+var conditionOps = map[string]func(a, b int16) bool{
+	"==": func(a, b int16) bool { return a == b },
+	"!=": func(a, b int16) bool { return a != b },
+	"<":  func(a, b int16) bool { return a < b },
+	">":  func(a, b int16) bool { return a > b },
+}
+
+// ParseCondition parses a condition written "register op value", such as
+// "R1 == 5" or "SP<0x100". The value may be negative.
+func ParseCondition(text string) (Condition, error) {
+	fields := strings.Fields(text)
+	if len(fields) != 3 {
+		// Allow the spaces around the operator to be left out. Two-character
+		// operators are tried first so "!=" is not taken for "=".
+		for _, op := range []string{"==", "!=", "<", ">"} {
+			if reg, value, ok := strings.Cut(text, op); ok {
+				fields = []string{strings.TrimSpace(reg), op, strings.TrimSpace(value)}
+				break
+			}
+		}
+	}
+	if len(fields) != 3 {
+		return Condition{}, fmt.Errorf("condition %q is not \"register op value\"", text)
+	}
+	cond := Condition{Register: -1, Op: fields[1]}
+	for i, name := range RegisterNames {
+		if strings.EqualFold(name, fields[0]) {
+			cond.Register = i
+		}
+	}
+	if cond.Register < 0 {
+		return Condition{}, fmt.Errorf("unknown register %q", fields[0])
+	}
+	if _, ok := conditionOps[cond.Op]; !ok {
+		return Condition{}, fmt.Errorf("unknown comparison %q", cond.Op)
+	}
+	value, err := strconv.ParseInt(fields[2], 0, 32)
+	if err != nil || value < -0x8000 || value > 0xFFFF {
+		return Condition{}, fmt.Errorf("invalid value %q", fields[2])
+	}
+	cond.Value = uint16(value)
+	return cond, nil
+}
+
+// String returns the condition in the form ParseCondition accepts.
+func (cond Condition) String() string {
+	return fmt.Sprintf("%s %s %d", RegisterNames[cond.Register], cond.Op, int16(cond.Value))
+}
+
+// holds reports whether the condition is true of regs.
+func (cond *Condition) holds(regs *[16]uint16) bool {
+	return conditionOps[cond.Op](int16(regs[cond.Register]), int16(cond.Value))
+}
+
+// AddBreakpoint makes Run pause before executing the instruction at addr.
+// It replaces any condition the breakpoint had.
+func (c *MonTanaMiniComputer) AddBreakpoint(addr uint16) error {
+	return c.addBreakpoint(addr, nil)
+}
+
+// AddConditionalBreakpoint makes Run pause before executing the instruction
+// at addr, but only when cond holds at that moment.
+func (c *MonTanaMiniComputer) AddConditionalBreakpoint(addr uint16, cond Condition) error {
+	if cond.Register < 0 || cond.Register >= len(c.Registers) {
+		return fmt.Errorf("condition register %d is not a register", cond.Register)
+	}
+	if _, ok := conditionOps[cond.Op]; !ok {
+		return fmt.Errorf("unknown comparison %q", cond.Op)
+	}
+	return c.addBreakpoint(addr, &cond)
+}
+
+func (c *MonTanaMiniComputer) addBreakpoint(addr uint16, cond *Condition) error {
+	if !c.wordInBounds(addr) {
+		return fmt.Errorf("breakpoint address 0x%04X is outside memory", addr)
+	}
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	if c.breakpoints.addrs == nil {
+		c.breakpoints.addrs = make(map[uint16]*Condition)
+	}
+	c.breakpoints.addrs[addr] = cond
+	return nil
+}
+
+// RemoveBreakpoint removes the breakpoint at addr, if there is one.
+func (c *MonTanaMiniComputer) RemoveBreakpoint(addr uint16) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	delete(c.breakpoints.addrs, addr)
+}
+
+// ClearBreakpoints removes every breakpoint.
+func (c *MonTanaMiniComputer) ClearBreakpoints() {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	clear(c.breakpoints.addrs)
+}
+
+// Breakpoints returns the breakpoint addresses in ascending order.
+func (c *MonTanaMiniComputer) Breakpoints() []uint16 {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	return c.breakpoints.list()
+}
+
+// Continue starts the execution cycle like SetRunning(true), except that a
+// breakpoint at the current PC does not fire, so a machine stopped on a
+// breakpoint, whether by running into it or by stepping onto it, always
+// makes progress.
+func (c *MonTanaMiniComputer) Continue() {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	c.breakpoints.resumeFrom(c.Registers[PC])
+	if c.Running {
+		return
+	}
+	c.Running = true
+	c.notifyObservers()
+}
+
+// BreakpointConditions returns the condition of each conditional
+// breakpoint, by address.
+func (c *MonTanaMiniComputer) BreakpointConditions() map[uint16]Condition {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	return c.breakpoints.conditions()
+}
+
+// conditions returns the conditions of the conditional breakpoints, never
+// nil so it encodes as an empty JSON object.
+func (b *breakpoints) conditions() map[uint16]Condition {
+	conds := make(map[uint16]Condition)
+	for addr, cond := range b.addrs {
+		if cond != nil {
+			conds[addr] = *cond
+		}
+	}
+	return conds
+}
+
+// list returns the breakpoint addresses in ascending order, never nil so it
+// encodes as an empty JSON array.
+func (b *breakpoints) list() []uint16 {
+	addrs := make([]uint16, 0, len(b.addrs))
+	for addr := range b.addrs {
+		addrs = append(addrs, addr)
+	}
+	slices.Sort(addrs)
+	return addrs
+}
+
+// resumeFrom makes the next atBreakpoint call for pc report false, so a
+// machine positioned at a breakpoint by other means than running into it,
+// such as stepping back, runs on from it rather than pausing again.
+func (b *breakpoints) resumeFrom(pc uint16) {
+	b.resume, b.resumeAt = true, pc
+}
+
+// atBreakpoint reports whether Run should pause before executing the
+// instruction at pc, given the registers regs. It reports true only once
+// per arrival, so resuming steps past the breakpoint, and only if the
+// breakpoint's condition, if any, holds.
+func (b *breakpoints) atBreakpoint(pc uint16, regs *[16]uint16) bool {
+	if b.resume && b.resumeAt == pc {
+		b.resume = false
+		return false
+	}
+	b.resume = false
+	cond, ok := b.addrs[pc]
+	if !ok || (cond != nil && !cond.holds(regs)) {
+		return false
+	}
+	b.resumeFrom(pc)
+	return true
+}
@@ -0,0 +1,197 @@
+package emulator
+
+import (
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestContinue(t *testing.T) {
+	// counterLoop's ADD is at 4 and its SW at 6.
+	tests := []struct {
+		name        string
+		breakpoints []uint16
+		runInto     bool // arrive at 4 by running, not stepping
+		resume      func(*MonTanaMiniComputer)
+		pc, r1      uint16
+	}{
+		{"continue after stepping onto it", []uint16{4, 6}, false, (*MonTanaMiniComputer).Continue, 6, 1},
+		{"run after stepping onto it", []uint16{4, 6}, false, func(c *MonTanaMiniComputer) { c.SetRunning(true) }, 4, 0},
+		{"continue after running into it", []uint16{4, 6}, true, (*MonTanaMiniComputer).Continue, 6, 1},
+		{"continue around the loop", []uint16{4}, true, (*MonTanaMiniComputer).Continue, 4, 1},
+		{"continue off a breakpoint", []uint16{6}, false, (*MonTanaMiniComputer).Continue, 6, 1},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ticks := make(chan time.Time)
+			c := newTestComputer(t, WithClock(ticks))
+			load(t, c, counterLoop(t))
+			for _, addr := range tt.breakpoints {
+				if err := c.AddBreakpoint(addr); err != nil {
+					t.Fatal(err)
+				}
+			}
+			stop := runClocked(c, ticks)
+			defer stop()
+
+			if tt.runInto {
+				c.SetRunning(true)
+			} else {
+				c.StepState()
+			}
+			waitPaused(t, c)
+			if c.Registers[PC] != 4 {
+				t.Fatalf("arrived at 0x%04X, want 0x0004", c.Registers[PC])
+			}
+			tt.resume(c)
+			waitPaused(t, c)
+			regs := c.GetState()["registers"].([16]uint16)
+			if regs[PC] != tt.pc || regs[R1] != tt.r1 {
+				t.Errorf("paused at 0x%04X with R1 %d, want 0x%04X with R1 %d", regs[PC], regs[R1], tt.pc, tt.r1)
+			}
+		})
+	}
+}
+
+func TestParseCondition(t *testing.T) {
+	tests := []struct {
+		text string
+		want Condition
+		err  bool
+	}{
+		{"R1 == 5", Condition{R1, "==", 5}, false},
+		{"sp<0x100", Condition{SP, "<", 0x100}, false},
+		{"R2!=-1", Condition{R2, "!=", 0xFFFF}, false},
+		{"PC > 0xFFFF", Condition{PC, ">", 0xFFFF}, false},
+		{"R1 = 5", Condition{}, true},
+		{"R1 >= 5", Condition{}, true},
+		{"R9 == 5", Condition{}, true},
+		{"R1 == five", Condition{}, true},
+		{"R1 == 0x10000", Condition{}, true},
+		{"R1 ==", Condition{}, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.text, func(t *testing.T) {
+			got, err := ParseCondition(tt.text)
+			if (err != nil) != tt.err {
+				t.Fatalf("ParseCondition(%q) error = %v, want error %v", tt.text, err, tt.err)
+			}
+			if got != tt.want {
+				t.Errorf("ParseCondition(%q) = %+v, want %+v", tt.text, got, tt.want)
+			}
+			if !tt.err {
+				if again, err := ParseCondition(got.String()); err != nil || again != got {
+					t.Errorf("ParseCondition(%q) = %+v, %v; want it to round-trip", got.String(), again, err)
+				}
+			}
+		})
+	}
+}
+
+func TestConditionalBreakpoint(t *testing.T) {
+	tests := []struct {
+		cond  string
+		r1    uint16
+		fires bool
+	}{
+		{"R1 == 5", 5, true},
+		{"R1 == 5", 4, false},
+		{"R1 != 5", 4, true},
+		{"R1 != 5", 5, false},
+		{"R1 < 0", 0xFFFF, true}, // compared as signed
+		{"R1 < 0", 1, false},
+		{"R1 > 4", 5, true},
+		{"R1 > 4", 4, false},
+		{"R1 > 4", 0x8000, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.cond, func(t *testing.T) {
+			cond, err := ParseCondition(tt.cond)
+			if err != nil {
+				t.Fatal(err)
+			}
+			c := newTestComputer(t)
+			if err := c.AddConditionalBreakpoint(8, cond); err != nil {
+				t.Fatal(err)
+			}
+			c.Registers[R1] = tt.r1
+			if got := c.breakpoints.atBreakpoint(8, &c.Registers); got != tt.fires {
+				t.Errorf("with R1 = 0x%04X, fires %v, want %v", tt.r1, got, tt.fires)
+			}
+			if c.breakpoints.atBreakpoint(6, &c.Registers) {
+				t.Error("fired at another address")
+			}
+		})
+	}
+}
+
+// TestConditionalBreakpointRun checks that Run passes a conditional
+// breakpoint until its condition holds.
+func TestConditionalBreakpointRun(t *testing.T) {
+	ticks := make(chan time.Time)
+	c := newTestComputer(t, WithClock(ticks))
+	load(t, c, counterLoop(t))
+	if err := c.AddConditionalBreakpoint(6, Condition{R1, "==", 3}); err != nil {
+		t.Fatal(err)
+	}
+	if err := c.AddConditionalBreakpoint(6, Condition{R1, ">", 16}); err != nil {
+		t.Fatal(err)
+	}
+	stop := runClocked(c, ticks)
+	defer stop()
+
+	// Re-adding replaced the condition, so R1 == 3 passes by.
+	c.SetRunning(true)
+	waitPaused(t, c)
+	regs := c.GetState()["registers"].([16]uint16)
+	if regs[PC] != 6 || regs[R1] != 17 {
+		t.Errorf("paused at 0x%04X with R1 %d, want 0x0006 with R1 17", regs[PC], regs[R1])
+	}
+	if got := c.BreakpointConditions(); len(got) != 1 || got[6] != (Condition{R1, ">", 16}) {
+		t.Errorf("conditions = %v, want only R1 > 16 at 6", got)
+	}
+}
+
+func TestBRK(t *testing.T) {
+	code := program(t, liw(R1, 1), ext(ExtBRK, 0, 0), liw(R2, 2), halt)
+	tests := []struct {
+		name     string
+		run      func(t *testing.T, c *MonTanaMiniComputer) // until the machine stops
+		notifies bool                                       // RunToCompletion leaves observers be
+	}{
+		{"RunToCompletion", func(t *testing.T, c *MonTanaMiniComputer) { c.RunToCompletion() }, false},
+		{"Run", func(t *testing.T, c *MonTanaMiniComputer) {
+			c.SetRunning(true)
+			waitPaused(t, c)
+		}, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ticks := make(chan time.Time)
+			c := newTestComputer(t, WithClock(ticks))
+			load(t, c, code)
+			stop := runClocked(c, ticks)
+			defer stop()
+			var notified atomic.Value // the last halt reason observed
+			c.AddObserver(stateObserverFunc(func(state map[string]interface{}) { notified.Store(state["haltReason"]) }))
+
+			tt.run(t, c)
+			state := c.GetState()
+			regs := state["registers"].([16]uint16)
+			if state["haltReason"] != HaltSoftwareBreakpoint || state["running"] != false || regs[PC] != 6 || regs[R1] != 1 || regs[R2] != 0 {
+				t.Fatalf("stopped with %q, running %v, PC %d, R1 %d, R2 %d; want %q, false, 6, 1, 0",
+					state["haltReason"], state["running"], regs[PC], regs[R1], regs[R2], HaltSoftwareBreakpoint)
+			}
+			if got := notified.Load(); tt.notifies && got != HaltSoftwareBreakpoint {
+				t.Errorf("observers last saw halt reason %v, want %q", got, HaltSoftwareBreakpoint)
+			}
+
+			// Running again resumes after the BRK.
+			tt.run(t, c)
+			state = c.GetState()
+			if regs := state["registers"].([16]uint16); state["haltReason"] != HaltInstruction || regs[R2] != 2 {
+				t.Errorf("resumed run stopped with %q and R2 %d, want %q and 2", state["haltReason"], regs[R2], HaltInstruction)
+			}
+		})
+	}
+}
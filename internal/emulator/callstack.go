@@ -0,0 +1,65 @@
+package emulator
+
+import (
+	"fmt"
+	"math"
+)
+
+// Frame is one level of a reconstructed call stack.
+type Frame struct {
+	// PC is where execution is, for the innermost frame, or will return
+	// to, for the others.
+	PC uint16 `json:"pc"`
+	// FP is the frame pointer of the frame.
+	FP uint16 `json:"fp"`
+	// Location names PC as label+offset when the program has labels.
+	Location string `json:"location,omitempty"`
+}
+
+// maxFrames bounds CallStack, in case the stack is corrupt.
+const maxFrames = 64
+
+// CallStack reconstructs the chain of calls that led to the current PC,
+// innermost first. It is best effort and assumes the usual frame pointer
+// convention: on entry a function pushes RA, then pushes the caller's FP
+// and points FP at it, so that the word at FP is the caller's FP and the
+// word above it is the return address. The walk stops at the outermost
+// frame, whose FP is the initial FP, or as soon as the chain stops making
+// sense: a frame outside memory, or one not above the frame before it.
+func (c *MonTanaMiniComputer) CallStack() []Frame {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	frames := []Frame{c.frame(c.Registers[PC], c.Registers[FP])}
+	for fp := c.Registers[FP]; fp != c.initialFP && len(frames) < maxFrames; {
+		if int(fp) >= math.MaxUint16-WordSize || !c.wordInBounds(fp+WordSize) {
+			break
+		}
+		callerFP, ra := c.fetchWord(fp), c.fetchWord(fp+WordSize)
+		if callerFP <= fp {
+			break
+		}
+		frames = append(frames, c.frame(ra, callerFP))
+		fp = callerFP
+	}
+	return frames
+}
+
+// frame describes the frame at pc and fp, naming pc after the nearest label
+// at or before it.
+func (c *MonTanaMiniComputer) frame(pc, fp uint16) Frame {
+	f := Frame{PC: pc, FP: fp}
+	var best string
+	var bestAddr uint16
+	for name, addr := range c.loaded.image.Labels {
+		if addr <= pc && (best == "" || addr > bestAddr || (addr == bestAddr && name < best)) {
+			best, bestAddr = name, addr
+		}
+	}
+	if best != "" {
+		f.Location = best
+		if pc > bestAddr {
+			f.Location = fmt.Sprintf("%s+%d", best, pc-bestAddr)
+		}
+	}
+	return f
+}
@@ -0,0 +1,73 @@
+package emulator
+
+import (
+	"slices"
+	"testing"
+)
+
+// callProgram is main calling f calling g, each function following the
+// frame pointer convention CallStack assumes. R0 through R4 stay zero, so
+// the forward branches are always taken, and R5 and R7 hold constants for
+// pushing: SW addresses R6 plus 0x60, so R6 is set to SP-0x60 first.
+func callProgram(t *testing.T) []byte {
+	prologue := []Instruction{
+		rrr(OpSUB, SP, SP, R7), rrr(OpSUB, R6, SP, R5), ri(OpSW, RA, 0x60), // push RA
+		rrr(OpSUB, SP, SP, R7), rrr(OpSUB, R6, SP, R5), ri(OpSW, FP, 0x60), // push FP
+		rrr(OpADD, FP, SP, R0),
+	}
+	var code []Instruction
+	code = append(code, liw(R7, 2), liw(R5, 0x60), liw(RA, 14), ri(OpBZ, 0, 1)) // main: call f
+	code = append(code, halt)                                                   // 14
+	code = append(code, prologue...)                                            // f: 16
+	code = append(code, liw(RA, 36), ri(OpBZ, 0, 1))                            // call g
+	code = append(code, halt)                                                   // 36
+	code = append(code, prologue...)                                            // g: 38
+	code = append(code, halt)                                                   // 52
+	return program(t, code...)
+}
+
+func TestCallStack(t *testing.T) {
+	labels := map[string]uint16{"main": 0, "f": 16, "g": 38}
+	const top = testMemorySize - WordSize
+	tests := []struct {
+		name   string
+		labels map[string]uint16
+		steps  int
+		setup  func(*MonTanaMiniComputer)
+		want   []Frame
+	}{
+		{"before any call", labels, 0, nil, []Frame{{0, top, "main"}}},
+		{"in f", labels, 11, nil, []Frame{
+			{30, top - 4, "f+14"},
+			{14, top, "main+14"},
+		}},
+		{"in g", labels, 20, nil, []Frame{
+			{52, top - 8, "g+14"},
+			{36, top - 4, "f+20"},
+			{14, top, "main+14"},
+		}},
+		{"no labels", nil, 20, nil, []Frame{{52, top - 8, ""}, {36, top - 4, ""}, {14, top, ""}}},
+		{"chain points down", labels, 20, func(c *MonTanaMiniComputer) {
+			c.Memory[top-4], c.Memory[top-3] = 0, 0 // f's saved FP
+		}, []Frame{{52, top - 8, "g+14"}, {36, top - 4, "f+20"}}},
+		{"FP outside memory", labels, 20, func(c *MonTanaMiniComputer) {
+			c.Registers[FP] = 0xFFFE
+		}, []Frame{{52, 0xFFFE, "g+14"}}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := newTestComputer(t)
+			img := Image{Segments: []Segment{{Address: 0, Data: callProgram(t)}}, Labels: tt.labels}
+			if err := c.LoadImage("calls", img); err != nil {
+				t.Fatal(err)
+			}
+			stepN(c, tt.steps)
+			if tt.setup != nil {
+				tt.setup(c)
+			}
+			if got := c.CallStack(); !slices.Equal(got, tt.want) {
+				t.Errorf("CallStack() = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
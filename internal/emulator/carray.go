@@ -0,0 +1,40 @@
+package emulator
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"regexp"
+)
+
+// cIdentifier matches names usable as a C identifier.
+var cIdentifier = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_]*$`)
+
+// cArrayColumns is how many bytes WriteCArray puts on each line.
+const cArrayColumns = 12
+
+// WriteCArray writes data, which belongs at address base, as C source
+// declaring "const unsigned char name[]" and its length, name_len, so a
+// program can be compiled into firmware. The load and entry addresses are
+// given in a comment.
+func WriteCArray(w io.Writer, name string, data []byte, base, entry uint16) error {
+	if !cIdentifier.MatchString(name) {
+		return fmt.Errorf("%q is not a C identifier", name)
+	}
+	bw := bufio.NewWriter(w)
+	fmt.Fprintf(bw, "/* MTMC program: %d bytes loaded at 0x%04X, entry point 0x%04X */\n", len(data), base, entry)
+	fmt.Fprintf(bw, "const unsigned char %s[] = {\n", name)
+	for i, b := range data {
+		if i%cArrayColumns == 0 {
+			bw.WriteString("    ")
+		} else {
+			bw.WriteString(" ")
+		}
+		fmt.Fprintf(bw, "0x%02X,", b)
+		if i%cArrayColumns == cArrayColumns-1 || i == len(data)-1 {
+			bw.WriteString("\n")
+		}
+	}
+	fmt.Fprintf(bw, "};\nconst unsigned int %s_len = %d;\n", name, len(data))
+	return bw.Flush()
+}
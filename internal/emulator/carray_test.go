@@ -0,0 +1,101 @@
+package emulator
+
+import (
+	"bytes"
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+// parseCArray reads back the array WriteCArray declares as name, checking
+// that its stated length matches, and returns its bytes and the load and
+// entry addresses from the comment.
+func parseCArray(t *testing.T, src, name string) (data []byte, base, entry uint16) {
+	t.Helper()
+	header := regexp.MustCompile(`(?m)^/\* MTMC program: (\d+) bytes loaded at (0x[0-9A-F]{4}), entry point (0x[0-9A-F]{4}) \*/$`)
+	m := header.FindStringSubmatch(src)
+	if m == nil {
+		t.Fatalf("no header comment in:\n%s", src)
+	}
+	_, body, ok := strings.Cut(src, "const unsigned char "+name+"[] = {\n")
+	body, rest, ok2 := strings.Cut(body, "};\n")
+	if !ok || !ok2 {
+		t.Fatalf("no array %s in:\n%s", name, src)
+	}
+	for _, field := range strings.Fields(body) {
+		b, err := strconv.ParseUint(strings.TrimSuffix(field, ","), 0, 8)
+		if err != nil || !strings.HasSuffix(field, ",") {
+			t.Fatalf("bad element %q", field)
+		}
+		data = append(data, byte(b))
+	}
+	if want := fmt.Sprintf("const unsigned int %s_len = %d;\n", name, len(data)); rest != want || m[1] != strconv.Itoa(len(data)) {
+		t.Errorf("length given as %s and %q, want %d", m[1], rest, len(data))
+	}
+	b, _ := strconv.ParseUint(m[2], 0, 16)
+	e, _ := strconv.ParseUint(m[3], 0, 16)
+	return data, uint16(b), uint16(e)
+}
+
+func TestWriteCArrayRoundTrip(t *testing.T) {
+	all := make([]byte, 256)
+	for i := range all {
+		all[i] = byte(i)
+	}
+	tests := []struct {
+		name        string
+		data        []byte
+		base, entry uint16
+	}{
+		{"empty", nil, 0, 0},
+		{"one byte", []byte{0xF0}, 0, 0},
+		{"one line", all[:cArrayColumns], 0x100, 0x100},
+		{"one past a line", all[:cArrayColumns+1], 0x100, 0x104},
+		{"every byte value", all, 0xFF00, 0xFFFE},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var buf bytes.Buffer
+			if err := WriteCArray(&buf, "prog", tt.data, tt.base, tt.entry); err != nil {
+				t.Fatal(err)
+			}
+			data, base, entry := parseCArray(t, buf.String(), "prog")
+			if !bytes.Equal(data, tt.data) || base != tt.base || entry != tt.entry {
+				t.Errorf("read back % X at 0x%04X, entry 0x%04X; want % X at 0x%04X, entry 0x%04X",
+					data, base, entry, tt.data, tt.base, tt.entry)
+			}
+			for _, line := range strings.Split(buf.String(), "\n") {
+				if n := strings.Count(line, ","); n > cArrayColumns {
+					t.Errorf("line %q holds %d bytes, want at most %d", line, n, cArrayColumns)
+				}
+			}
+		})
+	}
+}
+
+func TestWriteCArrayName(t *testing.T) {
+	tests := []struct {
+		name string
+		ok   bool
+	}{
+		{"prog", true},
+		{"_prog2", true},
+		{"Prog_Data", true},
+		{"", false},
+		{"2prog", false},
+		{"my-prog", false},
+		{"prog[]", false},
+	}
+	for _, tt := range tests {
+		var buf bytes.Buffer
+		err := WriteCArray(&buf, tt.name, []byte{1}, 0, 0)
+		if (err == nil) != tt.ok {
+			t.Errorf("WriteCArray name %q: error %v, want ok %v", tt.name, err, tt.ok)
+		}
+		if err != nil && buf.Len() != 0 {
+			t.Errorf("WriteCArray name %q wrote %q despite failing", tt.name, buf.String())
+		}
+	}
+}
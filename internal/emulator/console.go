@@ -0,0 +1,97 @@
+package emulator
+
+import "fmt"
+
+// The console device gives programs a screen, in addition to the OUT byte
+// stream, through three word-sized ports mapped into memory at a base
+// address chosen with WithConsole. Loads and stores of those words reach
+// the device rather than memory:
+//
+//	base+0  data     store: append the low byte to the screen
+//	                 load:  the next input byte, or 0xFFFF if none is waiting
+//	base+2  control  store: ConsoleClear or ConsoleNewline; loads read 0
+//	base+4  status   load:  ConsoleInputReady if input is waiting
+//
+// Input is the same FIFO that IN reads. The screen is reported as "console"
+// in the state, so the web UI can render it as a terminal.
+const (
+	ConsoleData    = 0
+	ConsoleControl = 2
+	ConsoleStatus  = 4
+	consolePorts   = 3
+)
+
+// Commands for the console's control port.
+const (
+	ConsoleClear   = 1 // erase the screen
+	ConsoleNewline = 2 // start a new line
+)
+
+// ConsoleInputReady is the status port bit that is set while input is
+// waiting.
+const ConsoleInputReady = 1 << 0
+
+// console is the state of the console device.
+type console struct {
+	mapped bool
+	base   uint16
+	screen []byte
+}
+
+// WithConsole maps the console device's ports at base. It panics unless base
+// is even and all three ports lie within memory.
+func WithConsole(base uint16) Option {
+	return func(c *MonTanaMiniComputer) {
+		last := int(base) + (consolePorts-1)*WordSize
+		if base%WordSize != 0 || last > 0xFFFF || !c.wordInBounds(uint16(last)) {
+			panic(fmt.Sprintf("emulator: console ports at 0x%04X must be even and within memory", base))
+		}
+		c.console = console{mapped: true, base: base}
+	}
+}
+
+// Console returns a copy of what the program has written to the console
+// screen since it was last cleared.
+func (c *MonTanaMiniComputer) Console() []byte {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	return append([]byte(nil), c.console.screen...)
+}
+
+// port reports which console port, if any, the word at addr is.
+func (d *console) port(addr uint16) (uint16, bool) {
+	if !d.mapped || addr < d.base || addr%WordSize != 0 {
+		return 0, false
+	}
+	offset := addr - d.base
+	return offset, int(offset) < consolePorts*WordSize
+}
+
+// readConsole loads from console port.
+func (c *MonTanaMiniComputer) readConsole(port uint16) uint16 {
+	switch port {
+	case ConsoleData:
+		return c.readInput()
+	case ConsoleStatus:
+		if len(c.input) > 0 {
+			return ConsoleInputReady
+		}
+	}
+	return 0
+}
+
+// writeConsole stores value to console port. Unknown control commands and
+// stores to the status port are ignored.
+func (c *MonTanaMiniComputer) writeConsole(port, value uint16) {
+	switch port {
+	case ConsoleData:
+		c.console.screen = append(c.console.screen, byte(value))
+	case ConsoleControl:
+		switch value {
+		case ConsoleClear:
+			c.console.screen = nil
+		case ConsoleNewline:
+			c.console.screen = append(c.console.screen, '\n')
+		}
+	}
+}
@@ -0,0 +1,108 @@
+package emulator
+
+import (
+	"bytes"
+	"testing"
+)
+
+func TestConsole(t *testing.T) {
+	const base = 0x800
+	// Each program starts by pointing R3 0x30 below the ports, since an
+	// immediate of 0x30 plus the port offset addresses from R3, and by
+	// setting R2 to untouched, which only loads change.
+	const untouched = 0xAAAA
+	var (
+		data    = func(v uint16) []Instruction { return []Instruction{liw(R1, v), ri(OpSW, R1, 0x30+ConsoleData)} }
+		control = func(v uint16) []Instruction { return []Instruction{liw(R1, v), ri(OpSW, R1, 0x30+ConsoleControl)} }
+		status  = []Instruction{ri(OpLW, R2, 0x30+ConsoleStatus)}
+		read    = []Instruction{ri(OpLW, R2, 0x30+ConsoleData)}
+	)
+	join := func(parts ...[]Instruction) []Instruction {
+		var code []Instruction
+		for _, p := range parts {
+			code = append(code, p...)
+		}
+		return code
+	}
+	tests := []struct {
+		name   string
+		input  string
+		code   []Instruction
+		screen string
+		r2     uint16
+	}{
+		{"writes accumulate", "", join(data('h'), data('i')), "hi", untouched},
+		{"newline", "", join(data('a'), control(ConsoleNewline), data('b')), "a\nb", untouched},
+		{"clear", "", join(data('a'), control(ConsoleClear), data('b')), "b", untouched},
+		{"unknown command", "", join(data('a'), control(0x99)), "a", untouched},
+		{"low byte only", "", data(0x1241), "A", untouched},
+		{"status without input", "", status, "", 0},
+		{"status with input", "x", status, "", ConsoleInputReady},
+		{"status after input is read", "x", join(read, status), "", 0},
+		{"data reads input", "xy", read, "", 'x'},
+		{"data without input", "", read, "", inputEmpty},
+		{"control reads zero", "", []Instruction{ri(OpLW, R2, 0x30+ConsoleControl)}, "", 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := newTestComputer(t, WithConsole(base))
+			code := append([]Instruction{liw(R3, base-0x30), liw(R2, untouched)}, tt.code...)
+			load(t, c, program(t, append(code, halt)...))
+			c.WriteInput([]byte(tt.input))
+			if r := c.RunToCompletion(); r.HaltReason != HaltInstruction {
+				t.Fatalf("halt reason %q, want %q", r.HaltReason, HaltInstruction)
+			}
+			if got := c.Console(); string(got) != tt.screen {
+				t.Errorf("screen %q, want %q", got, tt.screen)
+			}
+			if got := c.GetState()["console"]; got != tt.screen {
+				t.Errorf("state console %q, want %q", got, tt.screen)
+			}
+			if c.Registers[R2] != tt.r2 {
+				t.Errorf("R2 = 0x%04X, want 0x%04X", c.Registers[R2], tt.r2)
+			}
+			if mem, _ := c.ReadMemory(base, consolePorts*WordSize); !bytes.Equal(mem, make([]byte, consolePorts*WordSize)) {
+				t.Errorf("memory under the ports = % X, want it untouched", mem)
+			}
+			if len(c.Output()) != 0 {
+				t.Errorf("output %q, want the console kept apart from OUT", c.Output())
+			}
+		})
+	}
+}
+
+func TestConsoleReset(t *testing.T) {
+	c := newTestComputer(t, WithConsole(0x40))
+	load(t, c, program(t, liw(R1, 'a'), ri(OpSW, R1, 0x40), halt)) // R4 is 0, so this stores at 0x40
+	c.RunToCompletion()
+	if got := c.Console(); string(got) != "a" {
+		t.Fatalf("screen %q, want %q", got, "a")
+	}
+	c.Reset()
+	if got := c.Console(); len(got) != 0 {
+		t.Errorf("after Reset, screen %q, want it empty", got)
+	}
+}
+
+func TestWithConsolePanics(t *testing.T) {
+	tests := []struct {
+		name string
+		base uint16
+	}{
+		{"odd", 0x801},
+		{"past memory", testMemorySize},
+		{"last port past memory", testMemorySize - 2*WordSize},
+		{"wraps", 0xFFFE},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			defer func() {
+				if recover() == nil {
+					t.Errorf("WithConsole(0x%04X) did not panic", tt.base)
+				}
+			}()
+			newTestComputer(t, WithConsole(tt.base))
+		})
+	}
+	newTestComputer(t, WithConsole(testMemorySize-consolePorts*WordSize)) // the last fit
+}
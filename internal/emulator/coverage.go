@@ -0,0 +1,39 @@
+package emulator
+
+// coverage marks every address from which an instruction has been fetched
+// since the program was loaded, so dead code can be told apart from code
+// that ran.
+type coverage struct {
+	executed []bool
+}
+
+func (v *coverage) mark(pc uint16, size int) {
+	if v.executed == nil {
+		v.executed = make([]bool, size)
+	}
+	v.executed[pc] = true
+}
+
+func (v *coverage) reset() {
+	clear(v.executed)
+}
+
+// Coverage splits the instruction addresses of the loaded program, every
+// word of every segment, into those that have executed since it was loaded
+// and those that have not. Both are in ascending order within each segment.
+func (c *MonTanaMiniComputer) Coverage() (executed, unexecuted []uint16) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	executed, unexecuted = []uint16{}, []uint16{}
+	for _, seg := range c.loaded.image.Segments {
+		end := min(int(seg.Address)+len(seg.Data), len(c.Memory))
+		for addr := int(seg.Address); addr < end; addr += WordSize {
+			if c.coverage.executed != nil && c.coverage.executed[addr] {
+				executed = append(executed, uint16(addr))
+			} else {
+				unexecuted = append(unexecuted, uint16(addr))
+			}
+		}
+	}
+	return executed, unexecuted
+}
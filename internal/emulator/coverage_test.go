@@ -0,0 +1,42 @@
+package emulator
+
+import (
+	"slices"
+	"testing"
+)
+
+func TestCoverage(t *testing.T) {
+	tests := []struct {
+		name                 string
+		r0                   uint16 // BZ branches when R0 is 0
+		executed, unexecuted []uint16
+	}{
+		{"branch taken", 0, []uint16{0, 4, 8}, []uint16{2, 6}},
+		{"branch not taken", 1, []uint16{0, 2, 4}, []uint16{6, 8}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := newTestComputer(t)
+			load(t, c, program(t,
+				ri(OpBZ, 0, 1), // over the next instruction
+				ri(OpADDI, R1, 1),
+				ri(OpBZ, 0, 1), // R0 unchanged, so the same way again
+				ri(OpADDI, R1, 1),
+				halt,
+			))
+			c.Registers[R0] = tt.r0
+			stepN(c, 3)
+			executed, unexecuted := c.Coverage()
+			if !slices.Equal(executed, tt.executed) || !slices.Equal(unexecuted, tt.unexecuted) {
+				t.Errorf("Coverage() = %v, %v; want %v, %v", executed, unexecuted, tt.executed, tt.unexecuted)
+			}
+
+			if err := c.ReloadProgram(nil); err != nil {
+				t.Fatal(err)
+			}
+			if executed, unexecuted := c.Coverage(); len(executed) != 0 || len(unexecuted) != 5 {
+				t.Errorf("after reloading, Coverage() = %v, %v; want nothing executed", executed, unexecuted)
+			}
+		})
+	}
+}
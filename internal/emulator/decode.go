@@ -0,0 +1,285 @@
+package emulator
+
+import (
+	"encoding/binary"
+	"strings"
+)
+
+// Opcode is the 4-bit operation field in the top of every instruction word.
+type Opcode uint8
+
+const (
+	OpNOP  Opcode = 0b0000
+	OpADD  Opcode = 0b0001
+	OpSUB  Opcode = 0b0010
+	OpAND  Opcode = 0b0011
+	OpOR   Opcode = 0b0100
+	OpXOR  Opcode = 0b0101
+	OpSLL  Opcode = 0b0110
+	OpSRL  Opcode = 0b0111
+	OpCAS  Opcode = 0b1000
+	OpADDI Opcode = 0b1001
+	OpSUBI Opcode = 0b1010
+	OpEXT  Opcode = 0b1011
+	OpLW   Opcode = 0b1100
+	OpSW   Opcode = 0b1101
+	OpBZ   Opcode = 0b1110
+	OpHALT Opcode = 0b1111
+)
+
+// Format describes which instruction fields an opcode uses.
+type Format uint8
+
+const (
+	FormatNone Format = iota // no operands
+	FormatRRR                // RegD, RegS, RegT
+	FormatRI                 // RegD and an 8-bit signed Imm, whose high nibble is RegS
+	FormatExt                // RegD, RegS, with RegT selecting an ExtOp
+)
+
+// ExtOp selects what an OpEXT instruction does. It occupies the RegT field,
+// which lets one opcode carry many two-operand operations.
+type ExtOp uint8
+
+const (
+	// ExtRDCNT loads the low 16 bits of the instruction counter, including
+	// the RDCNT itself, into RegD. The counter wraps, so subtracting two
+	// readings with 16-bit arithmetic gives the right elapsed count for any
+	// interval shorter than 65536 instructions.
+	ExtRDCNT ExtOp = 0x0
+	// ExtIN loads the next input byte into RegD, or 0xFFFF (-1) if no
+	// input is waiting. It never blocks.
+	ExtIN ExtOp = 0x1
+	// ExtOUT appends the low byte of RegD to the output.
+	ExtOUT ExtOp = 0x2
+	// ExtRAND loads a pseudo-random value into RegD. The sequence is fixed by
+	// the machine's seed; see SetSeed.
+	ExtRAND ExtOp = 0x3
+	// ExtBTST tests bit n of RegD, where n is the RegS field itself rather
+	// than a register, setting the zero flag if the bit is 0 and clearing
+	// it if the bit is 1. Like the other bit operations it is written
+	// "BTST rd, n".
+	ExtBTST ExtOp = 0x4
+	// ExtBSET sets bit n of RegD, with n in the RegS field.
+	ExtBSET ExtOp = 0x5
+	// ExtBCLR clears bit n of RegD, with n in the RegS field.
+	ExtBCLR ExtOp = 0x6
+	// ExtADDO adds RegS to RegD like ADD, but if the signed result
+	// overflows it faults with HaltOverflow instead of wrapping, leaving
+	// RegD unchanged.
+	ExtADDO ExtOp = 0x7
+	// ExtSUBO subtracts RegS from RegD, faulting on signed overflow.
+	ExtSUBO ExtOp = 0x8
+	// ExtLW2 loads the 32-bit value at the address in RegS into the
+	// register pair RegD (high word) and RegD+1 (low word), such as HI and
+	// LO. Memory is big-endian, so the high word comes first. Both words
+	// must be in bounds or nothing is loaded, and RegD may not be the last
+	// register.
+	ExtLW2 ExtOp = 0x9
+	// ExtSW2 stores the register pair RegD:RegD+1 as a 32-bit value at the
+	// address in RegS, writing neither word unless both can be written.
+	ExtSW2 ExtOp = 0xA
+	// ExtLIW loads the full 16-bit value in the word following the
+	// instruction word into RegD. It is the one two-word instruction.
+	ExtLIW ExtOp = 0xB
+	// ExtBSEEK selects block RegD of the block device for the next BREAD
+	// or BWRITE, faulting with HaltBadBlock if there is no such block.
+	ExtBSEEK ExtOp = 0xC
+	// ExtBREAD copies the selected block into the BlockSize bytes of memory
+	// at the address in RegD.
+	ExtBREAD ExtOp = 0xD
+	// ExtBWRITE copies the BlockSize bytes of memory at the address in RegD
+	// to the selected block.
+	ExtBWRITE ExtOp = 0xE
+	// ExtBRK is a software breakpoint: it stops the machine with
+	// HaltSoftwareBreakpoint, leaving PC after it so running again resumes
+	// the program. Unlike HALT it does not mean the program has finished.
+	ExtBRK ExtOp = 0xF
+)
+
+// extOpInfo describes one assigned ExtOp.
+type extOpInfo struct {
+	Mnemonic string
+	Operands int    // how many of RegD and RegS it uses, in that order
+	BitIndex bool   // RegS is a bit number, 0-15, not a register
+	Width    int    // length of the instruction in bytes, EXT word included
+	Summary  string // what it does, in one line
+}
+
+// extOps describes each assigned ExtOp. Unassigned ones are left zero.
+var extOps = [16]extOpInfo{
+	ExtRDCNT:  {"RDCNT", 1, false, WordSize, "load the low 16 bits of the instruction count into rd"},
+	ExtIN:     {"IN", 1, false, WordSize, "load the next input byte into rd, or -1 if none is waiting"},
+	ExtOUT:    {"OUT", 1, false, WordSize, "append the low byte of rd to the output"},
+	ExtRAND:   {"RAND", 1, false, WordSize, "load a pseudo-random value into rd"},
+	ExtBTST:   {"BTST", 2, true, WordSize, "set the zero flag if bit n of rd is 0, clear it otherwise"},
+	ExtBSET:   {"BSET", 2, true, WordSize, "set bit n of rd"},
+	ExtBCLR:   {"BCLR", 2, true, WordSize, "clear bit n of rd"},
+	ExtADDO:   {"ADDO", 2, false, WordSize, "rd = rd + rs, faulting on signed overflow"},
+	ExtSUBO:   {"SUBO", 2, false, WordSize, "rd = rd - rs, faulting on signed overflow"},
+	ExtLW2:    {"LW2", 2, false, WordSize, "load the 32-bit value at [rs] into rd (high word) and rd+1 (low word)"},
+	ExtSW2:    {"SW2", 2, false, WordSize, "store rd (high word) and rd+1 (low word) as a 32-bit value at [rs]"},
+	ExtLIW:    {"LIW", 1, false, 2 * WordSize, "load the 16-bit value in the following word into rd"},
+	ExtBSEEK:  {"BSEEK", 1, false, WordSize, "select block rd of the block device"},
+	ExtBREAD:  {"BREAD", 1, false, WordSize, "copy the selected block into memory at [rd]"},
+	ExtBWRITE: {"BWRITE", 1, false, WordSize, "copy memory at [rd] to the selected block"},
+	ExtBRK:    {"BRK", 0, false, WordSize, "stop the machine at a software breakpoint, resumably"},
+}
+
+// String returns the operation's mnemonic, or "???" if it is unassigned.
+func (op ExtOp) String() string {
+	if op.Valid() {
+		return extOps[op].Mnemonic
+	}
+	return "???"
+}
+
+// Valid reports whether op is an assigned extended operation.
+func (op ExtOp) Valid() bool {
+	return int(op) < len(extOps) && extOps[op].Mnemonic != ""
+}
+
+// BitIndex reports whether op's RegS field is a bit number rather than a
+// register.
+func (op ExtOp) BitIndex() bool {
+	return op.Valid() && extOps[op].BitIndex
+}
+
+// Operands returns how many register operands op takes: RegD, then RegS.
+func (op ExtOp) Operands() int {
+	if op.Valid() {
+		return extOps[op].Operands
+	}
+	return 0
+}
+
+// opcodeInfo describes one assigned opcode.
+type opcodeInfo struct {
+	Mnemonic string
+	Format   Format
+	Width    int    // length of the instruction in bytes
+	Summary  string // what it does, in one line
+}
+
+// opcodes describes each assigned opcode. Unassigned opcodes are left zero.
+var opcodes = [16]opcodeInfo{
+	OpNOP:  {"NOP", FormatNone, WordSize, "do nothing"},
+	OpADD:  {"ADD", FormatRRR, WordSize, "rd = rs + rt"},
+	OpSUB:  {"SUB", FormatRRR, WordSize, "rd = rs - rt"},
+	OpAND:  {"AND", FormatRRR, WordSize, "rd = rs & rt"},
+	OpOR:   {"OR", FormatRRR, WordSize, "rd = rs | rt"},
+	OpXOR:  {"XOR", FormatRRR, WordSize, "rd = rs ^ rt"},
+	OpSLL:  {"SLL", FormatRRR, WordSize, "rd = rs << rt"},
+	OpSRL:  {"SRL", FormatRRR, WordSize, "rd = rs >> rt, shifting in zeros"},
+	OpCAS:  {"CAS", FormatRRR, WordSize, "if [rs] == rt, store rd at [rs] and set the zero flag, else clear it"},
+	OpADDI: {"ADDI", FormatRI, WordSize, "rd = rs + imm"},
+	OpSUBI: {"SUBI", FormatRI, WordSize, "rd = rs - imm"},
+	OpEXT:  {"EXT", FormatExt, WordSize, "extended operation selected by the rt field"},
+	OpLW:   {"LW", FormatRI, WordSize, "rd = [rs + imm]"},
+	OpSW:   {"SW", FormatRI, WordSize, "[rs + imm] = rd"},
+	OpBZ:   {"BZ", FormatRI, WordSize, "if rs == 0, branch imm words past the next instruction"},
+	OpHALT: {"HALT", FormatNone, WordSize, "stop the machine, with R0 as the exit code"},
+}
+
+// String returns the opcode's mnemonic, or "???" if it is unassigned.
+func (op Opcode) String() string {
+	if op.Valid() {
+		return opcodes[op].Mnemonic
+	}
+	return "???"
+}
+
+// Valid reports whether op is an assigned opcode.
+func (op Opcode) Valid() bool {
+	return int(op) < len(opcodes) && opcodes[op].Mnemonic != ""
+}
+
+// Format returns which instruction fields op uses.
+func (op Opcode) Format() Format {
+	if int(op) < len(opcodes) {
+		return opcodes[op].Format
+	}
+	return FormatNone
+}
+
+// ParseMnemonic looks up an instruction mnemonic, ignoring case. For
+// extended operations such as RDCNT it returns OpEXT and the ExtOp; for
+// everything else the ExtOp is zero.
+func ParseMnemonic(name string) (Opcode, ExtOp, bool) {
+	name = strings.ToUpper(name)
+	for op, info := range opcodes {
+		if info.Mnemonic == name && Opcode(op) != OpEXT {
+			return Opcode(op), 0, true
+		}
+	}
+	for ext, info := range extOps {
+		if info.Mnemonic != "" && info.Mnemonic == name {
+			return OpEXT, ExtOp(ext), true
+		}
+	}
+	return 0, 0, false
+}
+
+// Width returns the length in bytes of an instruction with opcode op and,
+// for OpEXT, extended operation ext. Most instructions are one word, but
+// some carry further words of operands after the instruction word.
+func Width(op Opcode, ext ExtOp) int {
+	if op == OpEXT && ext.Valid() {
+		return extOps[ext].Width
+	}
+	if op.Valid() {
+		return opcodes[op].Width
+	}
+	return WordSize
+}
+
+// Width returns the instruction's length in bytes, which is how far PC
+// advances past it.
+func (ins Instruction) Width() int {
+	return Width(ins.Op, ExtOp(ins.RegT))
+}
+
+// Instruction is a decoded instruction word. Every field is decoded from
+// every word; which of them are meaningful depends on Op. Note that Imm
+// overlaps RegS and RegT.
+type Instruction struct {
+	Word     uint16
+	Op       Opcode
+	RegD     uint8
+	RegS     uint8
+	RegT     uint8
+	Imm      int16 // low 8 bits, sign-extended
+	Mnemonic string
+	// Operand is the word following the instruction word, for
+	// instructions wider than one word; see DecodeAt.
+	Operand uint16
+}
+
+// DecodeAt decodes the instruction at the start of code, including the
+// Operand of a wide instruction if code holds it.
+func DecodeAt(code []byte) Instruction {
+	ins := Decode(binary.BigEndian.Uint16(code))
+	if ins.Width() > WordSize && len(code) >= WordSize*2 {
+		ins.Operand = binary.BigEndian.Uint16(code[WordSize:])
+	}
+	return ins
+}
+
+// Decode splits an instruction word into its fields. For OpEXT the Mnemonic
+// names the extended operation rather than "EXT".
+func Decode(word uint16) Instruction {
+	op := Opcode(word >> 12)
+	ins := Instruction{
+		Word:     word,
+		Op:       op,
+		RegD:     uint8(word>>8) & 0xF,
+		RegS:     uint8(word>>4) & 0xF,
+		RegT:     uint8(word) & 0xF,
+		Imm:      int16(int8(word)),
+		Mnemonic: op.String(),
+	}
+	if op == OpEXT {
+		ins.Mnemonic = ExtOp(ins.RegT).String()
+	}
+	return ins
+}
@@ -0,0 +1,84 @@
+package emulator
+
+import "testing"
+
+func TestDecode(t *testing.T) {
+	tests := []struct {
+		name   string
+		word   uint16
+		want   Instruction
+		format Format
+		width  int
+	}{
+		{"nop", 0x0000, Instruction{Op: OpNOP, Mnemonic: "NOP"}, FormatNone, 2},
+		{"halt", 0xF000, Instruction{Op: OpHALT, Mnemonic: "HALT"}, FormatNone, 2},
+		{"alu", 0x1123, Instruction{Op: OpADD, RegD: 1, RegS: 2, RegT: 3, Imm: 0x23, Mnemonic: "ADD"}, FormatRRR, 2},
+		{"alu high registers", 0x7FED, Instruction{Op: OpSRL, RegD: 15, RegS: 14, RegT: 13, Imm: -19, Mnemonic: "SRL"}, FormatRRR, 2},
+		{"atomic", 0x8312, Instruction{Op: OpCAS, RegD: 3, RegS: 1, RegT: 2, Imm: 0x12, Mnemonic: "CAS"}, FormatRRR, 2},
+		{"immediate", 0x9205, Instruction{Op: OpADDI, RegD: 2, RegS: 0, RegT: 5, Imm: 5, Mnemonic: "ADDI"}, FormatRI, 2},
+		{"negative immediate", 0xA2FF, Instruction{Op: OpSUBI, RegD: 2, RegS: 15, RegT: 15, Imm: -1, Mnemonic: "SUBI"}, FormatRI, 2},
+		{"load", 0xC180, Instruction{Op: OpLW, RegD: 1, RegS: 8, RegT: 0, Imm: -128, Mnemonic: "LW"}, FormatRI, 2},
+		{"store", 0xD17F, Instruction{Op: OpSW, RegD: 1, RegS: 7, RegT: 15, Imm: 127, Mnemonic: "SW"}, FormatRI, 2},
+		{"branch", 0xE0FE, Instruction{Op: OpBZ, RegD: 0, RegS: 15, RegT: 14, Imm: -2, Mnemonic: "BZ"}, FormatRI, 2},
+		{"extended", 0xB402, Instruction{Op: OpEXT, RegD: 4, RegS: 0, RegT: 2, Imm: 2, Mnemonic: "OUT"}, FormatExt, 2},
+		{"wide extended", 0xB10B, Instruction{Op: OpEXT, RegD: 1, RegS: 0, RegT: 0xB, Imm: 0xB, Mnemonic: "LIW"}, FormatExt, 4},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			want := tt.want
+			want.Word = tt.word
+			got := Decode(tt.word)
+			if got != want {
+				t.Errorf("Decode(0x%04X) = %+v, want %+v", tt.word, got, want)
+			}
+			if f := got.Op.Format(); f != tt.format {
+				t.Errorf("format = %d, want %d", f, tt.format)
+			}
+			if w := got.Width(); w != tt.width {
+				t.Errorf("width = %d, want %d", w, tt.width)
+			}
+		})
+	}
+}
+
+func TestDecodeAt(t *testing.T) {
+	tests := []struct {
+		name    string
+		code    []byte
+		operand uint16
+	}{
+		{"wide instruction", []byte{0xB1, 0x0B, 0xBE, 0xEF}, 0xBEEF},
+		{"wide instruction cut short", []byte{0xB1, 0x0B, 0xBE}, 0},
+		{"operand ignored for one word", []byte{0x11, 0x23, 0xBE, 0xEF}, 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ins := DecodeAt(tt.code)
+			if ins.Word != uint16(tt.code[0])<<8|uint16(tt.code[1]) || ins.Operand != tt.operand {
+				t.Errorf("DecodeAt(% X) = word 0x%04X, operand 0x%04X; want operand 0x%04X", tt.code, ins.Word, ins.Operand, tt.operand)
+			}
+		})
+	}
+}
+
+func TestParseMnemonic(t *testing.T) {
+	tests := []struct {
+		name string
+		op   Opcode
+		ext  ExtOp
+		ok   bool
+	}{
+		{"ADD", OpADD, 0, true},
+		{"halt", OpHALT, 0, true},
+		{"Liw", OpEXT, ExtLIW, true},
+		{"RDCNT", OpEXT, ExtRDCNT, true},
+		{"EXT", 0, 0, false},
+		{"FROB", 0, 0, false},
+	}
+	for _, tt := range tests {
+		op, ext, ok := ParseMnemonic(tt.name)
+		if op != tt.op || ext != tt.ext || ok != tt.ok {
+			t.Errorf("ParseMnemonic(%q) = %v, %v, %v; want %v, %v, %v", tt.name, op, ext, ok, tt.op, tt.ext, tt.ok)
+		}
+	}
+}
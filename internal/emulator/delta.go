@@ -0,0 +1,73 @@
+package emulator
+
+import "encoding/binary"
+
+// StepDelta describes what one single step changed, so a debugger can
+// highlight it.
+type StepDelta struct {
+	PC          uint16           `json:"pc"`          // address of the instruction
+	Instruction string           `json:"instruction"` // its disassembly
+	Registers   []RegisterChange `json:"registers"`
+	Memory      []MemoryChange   `json:"memory"`
+}
+
+// RegisterChange is a register whose value a step changed. PC changes on
+// every step, so it is always included.
+type RegisterChange struct {
+	Register string `json:"register"`
+	Index    int    `json:"index"`
+	Old      uint16 `json:"old"`
+	New      uint16 `json:"new"`
+}
+
+// MemoryChange is a word a step wrote, even if the value stayed the same.
+type MemoryChange struct {
+	Address uint16 `json:"address"`
+	Old     uint16 `json:"old"`
+	New     uint16 `json:"new"`
+}
+
+// deltaTracker collects the memory writes of a step for its StepDelta.
+type deltaTracker struct {
+	tracking bool
+	writes   []memoryWrite
+	last     *StepDelta // the most recent single step's delta, until the next step
+}
+
+// recordWrite notes the current contents of the word at addr if a delta is
+// being collected.
+func (d *deltaTracker) recordWrite(memory []byte, addr uint16) {
+	if d.tracking {
+		w := memoryWrite{addr: addr}
+		copy(w.old[:], memory[addr:])
+		d.writes = append(d.writes, w)
+	}
+}
+
+// stepDelta executes a single instruction, like step, and records what it
+// changed as the last step delta reported in the state.
+func (c *MonTanaMiniComputer) stepDelta() {
+	before := c.Registers
+	delta := &StepDelta{PC: before[PC], Registers: []RegisterChange{}, Memory: []MemoryChange{}}
+	if c.wordInBounds(delta.PC) {
+		delta.Instruction = DecodeAt(c.Memory[delta.PC:]).disassemble(delta.PC, c.registerNames())
+	}
+
+	c.delta.tracking, c.delta.writes = true, c.delta.writes[:0]
+	c.step()
+	c.delta.tracking = false
+
+	for i, old := range before {
+		if c.Registers[i] != old {
+			delta.Registers = append(delta.Registers, RegisterChange{RegisterNames[i], i, old, c.Registers[i]})
+		}
+	}
+	for _, w := range c.delta.writes {
+		delta.Memory = append(delta.Memory, MemoryChange{
+			Address: w.addr,
+			Old:     binary.BigEndian.Uint16(w.old[:]),
+			New:     c.fetchWord(w.addr),
+		})
+	}
+	c.delta.last = delta
+}
@@ -0,0 +1,63 @@
+package emulator
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestStepDelta(t *testing.T) {
+	tests := []struct {
+		name      string
+		setup     []Instruction // executed before the step under test
+		ins       Instruction
+		registers []RegisterChange
+		memory    []MemoryChange
+	}{
+		{
+			name:      "ADD",
+			setup:     []Instruction{liw(R1, 3), liw(R2, 4)},
+			ins:       rrr(OpADD, R3, R1, R2),
+			registers: []RegisterChange{{"R3", R3, 0, 7}, {"PC", PC, 8, 10}},
+			memory:    []MemoryChange{},
+		},
+		{
+			name:      "SW",
+			setup:     []Instruction{liw(R0, 0x100), liw(R1, 0xBEEF)},
+			ins:       ri(OpSW, R1, 0),
+			registers: []RegisterChange{{"PC", PC, 8, 10}},
+			memory:    []MemoryChange{{0x100, 0x1234, 0xBEEF}},
+		},
+		{
+			name:      "unchanged register",
+			setup:     []Instruction{liw(R1, 3), liw(R2, 0)},
+			ins:       rrr(OpADD, R1, R1, R2),
+			registers: []RegisterChange{{"PC", PC, 8, 10}},
+			memory:    []MemoryChange{},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := newTestComputer(t)
+			load(t, c, program(t, append(tt.setup, tt.ins)...))
+			if err := c.WriteMemory(0x100, []byte{0x12, 0x34}); err != nil {
+				t.Fatal(err)
+			}
+			stepN(c, len(tt.setup))
+
+			c.StepState()
+			delta, ok := c.GetState()["delta"].(*StepDelta)
+			if !ok || delta == nil {
+				t.Fatal("no delta in state")
+			}
+			if delta.PC != 8 || delta.Instruction == "" {
+				t.Errorf("delta for 0x%04X %q, want the instruction at 0x0008", delta.PC, delta.Instruction)
+			}
+			if !reflect.DeepEqual(delta.Registers, tt.registers) {
+				t.Errorf("register changes %+v, want %+v", delta.Registers, tt.registers)
+			}
+			if !reflect.DeepEqual(delta.Memory, tt.memory) {
+				t.Errorf("memory changes %+v, want %+v", delta.Memory, tt.memory)
+			}
+		})
+	}
+}
@@ -0,0 +1,126 @@
+package emulator
+
+import (
+	"encoding/hex"
+	"fmt"
+	"strings"
+)
+
+// DisassembledLine is one instruction, or a trailing odd byte, of a
+// disassembly listing.
+type DisassembledLine struct {
+	Address uint16   `json:"address"`
+	Bytes   HexBytes `json:"bytes"`
+	Text    string   `json:"text"`
+	// Source is the source line the word was assembled from, if known.
+	Source string `json:"source,omitempty"`
+	// Annotation is the user's note on the address; see AnnotateAddress.
+	Annotation string `json:"annotation,omitempty"`
+}
+
+// HexBytes is a byte slice that encodes as a hex string, like "9005", in
+// JSON.
+type HexBytes []byte
+
+// MarshalText encodes b as upper-case hex.
+func (b HexBytes) MarshalText() ([]byte, error) {
+	return []byte(strings.ToUpper(hex.EncodeToString(b))), nil
+}
+
+// Disassemble decodes code, which is loaded at base, into one line per
+// instruction. The text uses the assembler's syntax, so a listing can
+// be reassembled; words that are not valid instructions are shown as .word
+// directives and a trailing odd byte as a .byte directive.
+func Disassemble(code []byte, base uint16) []DisassembledLine {
+	return disassemble(code, base, &RegisterNames)
+}
+
+// disassemble is Disassemble with registers called by names.
+func disassemble(code []byte, base uint16, names *[16]string) []DisassembledLine {
+	lines := make([]DisassembledLine, 0, (len(code)+1)/WordSize)
+	for off := 0; off < len(code); {
+		addr := base + uint16(off)
+		if off+WordSize > len(code) {
+			lines = append(lines, DisassembledLine{Address: addr, Bytes: code[off:], Text: fmt.Sprintf(".byte 0x%02X", code[off])})
+			break
+		}
+		ins := DecodeAt(code[off:])
+		width := ins.Width()
+		if off+width > len(code) {
+			// The operand words are missing, so show what is there as data.
+			width = WordSize
+			lines = append(lines, DisassembledLine{Address: addr, Bytes: code[off : off+width], Text: fmt.Sprintf(".word 0x%04X", ins.Word)})
+		} else {
+			lines = append(lines, DisassembledLine{Address: addr, Bytes: code[off : off+width], Text: ins.disassemble(addr, names)})
+		}
+		off += width
+	}
+	return lines
+}
+
+// DisassembleMemory disassembles count words of memory starting at start,
+// clipped to the end of memory, attaching the loaded program's source lines
+// and the user's annotations where there are any. Registers are shown by
+// their aliases; see SetRegisterAlias. Since it reads memory rather than the
+// loaded image, the listing reflects any code the program has rewritten.
+func (c *MonTanaMiniComputer) DisassembleMemory(start uint16, count int) []DisassembledLine {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	begin := min(int(start), len(c.Memory))
+	end := min(begin+max(count, 0)*WordSize, len(c.Memory))
+	lines := disassemble(c.Memory[begin:end], uint16(begin), c.registerNames())
+	for i := range lines {
+		lines[i].Bytes = append([]byte(nil), lines[i].Bytes...)
+		lines[i].Source = c.loaded.image.Source[lines[i].Address]
+		lines[i].Annotation = c.annotations[lines[i].Address]
+	}
+	return lines
+}
+
+// nextInstruction disassembles the instruction at PC, the one the next step
+// executes, or returns "" if it lies outside memory.
+func (c *MonTanaMiniComputer) nextInstruction() string {
+	pc := c.Registers[PC]
+	if !c.wordInBounds(pc) {
+		return ""
+	}
+	lines := disassemble(c.Memory[pc:min(int(pc)+2*WordSize, len(c.Memory))], pc, c.registerNames())
+	return lines[0].Text
+}
+
+// Disassemble returns the instruction in assembler syntax, as if it were
+// located at addr, which is needed to show a branch's absolute target.
+// Immediates are shown as signed decimals, so an ADDI of -1 reads "-1"
+// rather than 255 or 65535.
+func (ins Instruction) Disassemble(addr uint16) string {
+	return ins.disassemble(addr, &RegisterNames)
+}
+
+// disassemble is Disassemble with registers called by names.
+func (ins Instruction) disassemble(addr uint16, names *[16]string) string {
+	reg := func(r uint8) string { return names[r] }
+	switch ins.Op.Format() {
+	case FormatRRR:
+		return fmt.Sprintf("%s %s, %s, %s", ins.Mnemonic, reg(ins.RegD), reg(ins.RegS), reg(ins.RegT))
+	case FormatRI:
+		if ins.Op == OpBZ {
+			return fmt.Sprintf("%s %s, 0x%04X", ins.Mnemonic, reg(ins.RegS), addr+WordSize+uint16(ins.Imm)*WordSize)
+		}
+		return fmt.Sprintf("%s %s, %s, %d", ins.Mnemonic, reg(ins.RegD), reg(ins.RegS), ins.Imm)
+	case FormatExt:
+		ext := ExtOp(ins.RegT)
+		if !ext.Valid() {
+			return fmt.Sprintf(".word 0x%04X", ins.Word)
+		}
+		second := reg(ins.RegS)
+		if ext.BitIndex() {
+			second = fmt.Sprint(ins.RegS)
+		}
+		operands := []string{reg(ins.RegD), second}[:ext.Operands()]
+		if ins.Width() > WordSize {
+			operands = append(operands, fmt.Sprintf("0x%04X", ins.Operand))
+		}
+		return strings.TrimSpace(ins.Mnemonic + " " + strings.Join(operands, ", "))
+	}
+	return ins.Mnemonic
+}
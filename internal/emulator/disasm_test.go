@@ -0,0 +1,50 @@
+package emulator
+
+import "testing"
+
+func TestDisassembleSignedImmediates(t *testing.T) {
+	tests := []struct {
+		word uint16
+		addr uint16
+		want string
+	}{
+		{0x91FF, 0, "ADDI R1, SR, -1"},
+		{0x917F, 0, "ADDI R1, R7, 127"},
+		{0xA280, 0, "SUBI R2, GP, -128"},
+		{0xC1FE, 0, "LW R1, SR, -2"},
+		{0xD305, 0, "SW R3, R0, 5"},
+		{0xE0FF, 8, "BZ SR, 0x0008"},
+		{0xE080, 0x200, "BZ GP, 0x0102"},
+		{0xE001, 0, "BZ R0, 0x0004"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.want, func(t *testing.T) {
+			if got := Decode(tt.word).Disassemble(tt.addr); got != tt.want {
+				t.Errorf("Disassemble(0x%04X at 0x%04X) = %q, want %q", tt.word, tt.addr, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestStateInstruction(t *testing.T) {
+	tests := []struct {
+		name string
+		pc   uint16
+		want string
+	}{
+		{"negative immediate", 0, "ADDI R1, SR, -1"},
+		{"two words", 2, "LIW R2, 0xFFFF"},
+		{"last word", testMemorySize - WordSize, "NOP"},
+		{"outside memory", testMemorySize, ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := newTestComputer(t)
+			load(t, c, program(t, ri(OpADDI, R1, -1), liw(R2, 0xFFFF), halt))
+			c.Registers[PC] = tt.pc
+			if got := c.GetState()["instruction"]; got != tt.want {
+				t.Errorf("instruction = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
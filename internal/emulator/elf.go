@@ -0,0 +1,72 @@
+package emulator
+
+import (
+	"bytes"
+	"debug/elf"
+	"fmt"
+	"io"
+)
+
+// ELFMachine is the e_machine value identifying MTMC executables. It has no
+// official assignment; 0x4D54 spells "MT".
+const ELFMachine elf.Machine = 0x4D54
+
+// IsELF reports whether data starts with the ELF magic number, in which case
+// it should be loaded with ParseELF rather than as a flat binary.
+func IsELF(data []byte) bool {
+	return bytes.HasPrefix(data, []byte(elf.ELFMAG))
+}
+
+// ParseELF extracts a loadable Image from an MTMC ELF executable.
+//
+// The supported subset is deliberately small: a 32-bit, big-endian ET_EXEC
+// file whose e_machine is ELFMachine. Each PT_LOAD program header becomes a
+// segment at p_vaddr, with the bytes between p_filesz and p_memsz zero
+// filled, and e_entry becomes the entry point. Section headers and all other
+// program header types are ignored. Addresses must fit in 16 bits; checking
+// them against a particular machine's memory is left to LoadImage.
+func ParseELF(data []byte) (Image, error) {
+	f, err := elf.NewFile(bytes.NewReader(data))
+	if err != nil {
+		return Image{}, fmt.Errorf("not an ELF file: %w", err)
+	}
+	defer f.Close()
+
+	switch {
+	case f.Class != elf.ELFCLASS32:
+		return Image{}, fmt.Errorf("unsupported ELF class %v, want %v", f.Class, elf.ELFCLASS32)
+	case f.Data != elf.ELFDATA2MSB:
+		return Image{}, fmt.Errorf("unsupported ELF byte order %v, want %v", f.Data, elf.ELFDATA2MSB)
+	case f.Machine != ELFMachine:
+		return Image{}, fmt.Errorf("unsupported ELF machine type 0x%X, want 0x%X (MTMC)", uint16(f.Machine), uint16(ELFMachine))
+	case f.Type != elf.ET_EXEC:
+		return Image{}, fmt.Errorf("unsupported ELF type %v, want %v", f.Type, elf.ET_EXEC)
+	case f.Entry > 0xFFFF:
+		return Image{}, fmt.Errorf("entry point 0x%X does not fit in 16 bits", f.Entry)
+	}
+
+	img := Image{Entry: uint16(f.Entry)}
+	for _, prog := range f.Progs {
+		if prog.Type != elf.PT_LOAD {
+			continue
+		}
+		if prog.Filesz > prog.Memsz {
+			return Image{}, fmt.Errorf("segment at 0x%X has file size larger than memory size", prog.Vaddr)
+		}
+		if prog.Vaddr+prog.Memsz > 0x10000 {
+			return Image{}, fmt.Errorf("segment at 0x%X (%d bytes) does not fit in 16-bit address space", prog.Vaddr, prog.Memsz)
+		}
+		seg := make([]byte, prog.Memsz)
+		if _, err := io.ReadFull(prog.Open(), seg[:prog.Filesz]); err != nil {
+			return Image{}, fmt.Errorf("reading segment at 0x%X: %w", prog.Vaddr, err)
+		}
+		img.Segments = append(img.Segments, Segment{Address: uint16(prog.Vaddr), Data: seg})
+		if prog.Flags&elf.PF_X != 0 && prog.Memsz > 0 {
+			img.Code = append(img.Code, CodeRange{uint16(prog.Vaddr), uint16(prog.Vaddr + prog.Memsz - 1)})
+		}
+	}
+	if len(img.Segments) == 0 {
+		return Image{}, fmt.Errorf("ELF file has no PT_LOAD segments")
+	}
+	return img, nil
+}
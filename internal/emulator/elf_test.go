@@ -0,0 +1,124 @@
+package emulator
+
+import (
+	"bytes"
+	"debug/elf"
+	"encoding/binary"
+	"slices"
+	"testing"
+)
+
+// elfSegment is a PT_LOAD program header for elfFile.
+type elfSegment struct {
+	vaddr uint32
+	data  []byte
+	memsz uint32 // 0 for len(data)
+	flags elf.ProgFlag
+}
+
+// elfFile builds an MTMC ELF executable holding segs.
+func elfFile(entry uint32, segs ...elfSegment) []byte {
+	const ehsize, phentsize = 52, 32
+	be := binary.BigEndian
+	var b []byte
+	b = append(b, elf.ELFMAG...)
+	b = append(b, byte(elf.ELFCLASS32), byte(elf.ELFDATA2MSB), byte(elf.EV_CURRENT))
+	b = append(b, make([]byte, elf.EI_NIDENT-len(b))...)
+	b = be.AppendUint16(b, uint16(elf.ET_EXEC))
+	b = be.AppendUint16(b, uint16(ELFMachine))
+	b = be.AppendUint32(b, uint32(elf.EV_CURRENT))
+	b = be.AppendUint32(b, entry)
+	b = be.AppendUint32(b, ehsize) // e_phoff
+	b = be.AppendUint32(b, 0)      // e_shoff
+	b = be.AppendUint32(b, 0)      // e_flags
+	b = be.AppendUint16(b, ehsize)
+	b = be.AppendUint16(b, phentsize)
+	b = be.AppendUint16(b, uint16(len(segs)))
+	b = be.AppendUint16(b, 40) // e_shentsize
+	b = be.AppendUint16(b, 0)  // e_shnum
+	b = be.AppendUint16(b, 0)  // e_shstrndx
+
+	offset := uint32(ehsize + phentsize*len(segs))
+	for _, seg := range segs {
+		memsz := seg.memsz
+		if memsz == 0 {
+			memsz = uint32(len(seg.data))
+		}
+		for _, v := range []uint32{uint32(elf.PT_LOAD), offset, seg.vaddr, seg.vaddr, uint32(len(seg.data)), memsz, uint32(seg.flags), 2} {
+			b = be.AppendUint32(b, v)
+		}
+		offset += uint32(len(seg.data))
+	}
+	for _, seg := range segs {
+		b = append(b, seg.data...)
+	}
+	return b
+}
+
+func TestParseELF(t *testing.T) {
+	code := program(t, liw(R0, 0x200), ri(OpLW, R2, 0), halt) // LW addresses from R0
+	data := []byte{0xBE, 0xEF}
+	f := elfFile(0x104,
+		elfSegment{vaddr: 0x100, data: append(program(t, nop, nop), code...), flags: elf.PF_R | elf.PF_X},
+		elfSegment{vaddr: 0x200, data: data, memsz: 6, flags: elf.PF_R | elf.PF_W},
+	)
+	if !IsELF(f) {
+		t.Fatal("IsELF = false")
+	}
+	img, err := ParseELF(f)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if img.Entry != 0x104 || len(img.Segments) != 2 {
+		t.Fatalf("entry 0x%04X, %d segments; want 0x0104, 2", img.Entry, len(img.Segments))
+	}
+	if s := img.Segments[1]; s.Address != 0x200 || !bytes.Equal(s.Data, []byte{0xBE, 0xEF, 0, 0, 0, 0}) {
+		t.Errorf("data segment at 0x%04X = % X, want zero filled to 6 bytes at 0x0200", s.Address, s.Data)
+	}
+	if want := []CodeRange{{0x100, 0x10B}}; !slices.Equal(img.Code, want) {
+		t.Errorf("Code = %v, want %v", img.Code, want)
+	}
+
+	c := newTestComputer(t)
+	if err := c.LoadImage("prog", img); err != nil {
+		t.Fatal(err)
+	}
+	if r := c.RunToCompletion(); r.HaltReason != HaltInstruction || r.Registers[R2] != 0xBEEF {
+		t.Errorf("halt reason %q with R2 = 0x%04X, want %q with 0xBEEF", r.HaltReason, r.Registers[R2], HaltInstruction)
+	}
+
+	small := New(WithLogger(c.logger))
+	if err := small.LoadImage("prog", img); err == nil {
+		t.Error("LoadImage accepted segments outside a small machine's memory")
+	}
+}
+
+func TestParseELFErrors(t *testing.T) {
+	valid := func() []byte {
+		return elfFile(0, elfSegment{vaddr: 0, data: []byte{0xF0, 0}, flags: elf.PF_X})
+	}
+	tests := []struct {
+		name string
+		file []byte
+	}{
+		{"not ELF", []byte("hello, world")},
+		{"little endian", func() []byte { f := valid(); f[elf.EI_DATA] = byte(elf.ELFDATA2LSB); return f }()},
+		{"wrong machine", func() []byte { f := valid(); f[18], f[19] = 0, byte(elf.EM_386); return f }()},
+		{"not executable", func() []byte { f := valid(); f[17] = byte(elf.ET_REL); return f }()},
+		{"entry too large", elfFile(0x10000, elfSegment{data: []byte{0xF0, 0}})},
+		{"no segments", elfFile(0)},
+		{"file larger than memory size", func() []byte {
+			f := valid()
+			binary.BigEndian.PutUint32(f[52+20:], 1) // p_memsz
+			return f
+		}()},
+		{"beyond 16 bits", elfFile(0, elfSegment{vaddr: 0xFFFF, data: []byte{0xF0, 0}})},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if img, err := ParseELF(tt.file); err == nil {
+				t.Errorf("ParseELF = %+v, want an error", img)
+			}
+		})
+	}
+}
@@ -0,0 +1,50 @@
+package emulator
+
+import (
+	"fmt"
+	"math"
+)
+
+// Encode assembles ins into an instruction word, using only the fields of
+// the opcode's format. FormatRRR encodes RegD, RegS and RegT. FormatExt
+// encodes RegD and RegS, with RegT holding the ExtOp. FormatRI encodes RegD
+// and Imm; RegS and RegT are ignored, since Imm occupies their bits, and
+// Decode refills them from those bits. FormatNone encodes only the opcode.
+// Word, Mnemonic and Operand are always ignored. It returns an error for an
+// unassigned opcode or ExtOp, a register index above 15, or an immediate
+// that does not fit in 8 signed bits.
+func Encode(ins Instruction) (uint16, error) {
+	if !ins.Op.Valid() {
+		return 0, fmt.Errorf("unassigned opcode %d", ins.Op)
+	}
+
+	word := uint16(ins.Op) << 12
+	switch ins.Op.Format() {
+	case FormatRRR:
+		for _, reg := range []uint8{ins.RegD, ins.RegS, ins.RegT} {
+			if reg > 0xF {
+				return 0, fmt.Errorf("%s: register index %d out of range", ins.Op, reg)
+			}
+		}
+		word |= uint16(ins.RegD)<<8 | uint16(ins.RegS)<<4 | uint16(ins.RegT)
+	case FormatExt:
+		for _, reg := range []uint8{ins.RegD, ins.RegS} {
+			if reg > 0xF {
+				return 0, fmt.Errorf("%s: register index %d out of range", ins.Op, reg)
+			}
+		}
+		if !ExtOp(ins.RegT).Valid() {
+			return 0, fmt.Errorf("%s: unassigned extended operation %d", ins.Op, ins.RegT)
+		}
+		word |= uint16(ins.RegD)<<8 | uint16(ins.RegS)<<4 | uint16(ins.RegT)
+	case FormatRI:
+		if ins.RegD > 0xF {
+			return 0, fmt.Errorf("%s: register index %d out of range", ins.Op, ins.RegD)
+		}
+		if ins.Imm < math.MinInt8 || ins.Imm > math.MaxInt8 {
+			return 0, fmt.Errorf("%s: immediate %d does not fit in 8 bits", ins.Op, ins.Imm)
+		}
+		word |= uint16(ins.RegD)<<8 | uint16(uint8(ins.Imm))
+	}
+	return word, nil
+}
@@ -0,0 +1,125 @@
+package emulator
+
+import (
+	"math"
+	"testing"
+)
+
+// encodable reports whether Encode can produce word: every word is, except
+// unassigned extended operations and NOP or HALT with operand bits set.
+func encodable(word uint16) bool {
+	ins := Decode(word)
+	switch ins.Op.Format() {
+	case FormatExt:
+		return ExtOp(ins.RegT).Valid()
+	case FormatNone:
+		return word&0x0FFF == 0
+	}
+	return ins.Op.Valid()
+}
+
+func TestEncodeDecodeRoundTrip(t *testing.T) {
+	for w := range math.MaxUint16 + 1 {
+		word := uint16(w)
+		if !encodable(word) {
+			continue
+		}
+		got, err := Encode(Decode(word))
+		if err != nil {
+			t.Fatalf("Encode(Decode(0x%04X)): %v", word, err)
+		}
+		if got != word {
+			t.Fatalf("Encode(Decode(0x%04X)) = 0x%04X", word, got)
+		}
+	}
+}
+
+func TestDecodeEncode(t *testing.T) {
+	tests := []struct {
+		name string
+		ins  Instruction
+		want Instruction // the fields Decode gives back
+	}{
+		{
+			"rrr",
+			rrr(OpADD, R1, R2, R3),
+			Instruction{Op: OpADD, RegD: R1, RegS: R2, RegT: R3, Imm: 0x23},
+		},
+		{
+			"ext",
+			ext(ExtOUT, R4, R5),
+			Instruction{Op: OpEXT, RegD: R4, RegS: R5, RegT: uint8(ExtOUT), Imm: 0x52},
+		},
+		{
+			"ri positive",
+			ri(OpADDI, R6, 0x7F),
+			Instruction{Op: OpADDI, RegD: R6, RegS: 7, RegT: 0xF, Imm: 0x7F},
+		},
+		{
+			"ri negative",
+			ri(OpBZ, R0, -2),
+			Instruction{Op: OpBZ, RegD: R0, RegS: 0xF, RegT: 0xE, Imm: -2},
+		},
+		{
+			"ri ignores RegS and RegT",
+			Instruction{Op: OpLW, RegD: R1, RegS: 9, RegT: 9, Imm: 4},
+			Instruction{Op: OpLW, RegD: R1, RegS: 0, RegT: 4, Imm: 4},
+		},
+		{
+			"none ignores registers",
+			Instruction{Op: OpHALT, RegD: 1, RegS: 2, RegT: 3, Imm: 4},
+			Instruction{Op: OpHALT},
+		},
+		{
+			"wide operand not encoded",
+			liw(R1, 0xBEEF),
+			Instruction{Op: OpEXT, RegD: R1, RegT: uint8(ExtLIW), Imm: int16(ExtLIW)},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			word, err := Encode(tt.ins)
+			if err != nil {
+				t.Fatal(err)
+			}
+			got := Decode(word)
+			if got.Op != tt.want.Op || got.RegD != tt.want.RegD || got.RegS != tt.want.RegS || got.RegT != tt.want.RegT || got.Imm != tt.want.Imm {
+				t.Errorf("Decode(Encode(%+v)) = %+v, want fields %+v", tt.ins, got, tt.want)
+			}
+			if got.Word != word || got.Operand != 0 {
+				t.Errorf("Word = 0x%04X, Operand = 0x%04X; want 0x%04X, 0", got.Word, got.Operand, word)
+			}
+		})
+	}
+}
+
+func TestEncodeErrors(t *testing.T) {
+	tests := []struct {
+		name string
+		ins  Instruction
+	}{
+		{"rrr register", rrr(OpADD, 16, 0, 0)},
+		{"rrr third register", rrr(OpSUB, 0, 0, 16)},
+		{"ext register", ext(ExtOUT, 0, 16)},
+		{"ri register", ri(OpADDI, 16, 0)},
+		{"immediate too large", ri(OpADDI, R1, math.MaxInt8+1)},
+		{"immediate too small", ri(OpBZ, R0, math.MinInt8-1)},
+		{"opcode", Instruction{Op: 16}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if word, err := Encode(tt.ins); err == nil {
+				t.Errorf("Encode(%+v) = 0x%04X, want an error", tt.ins, word)
+			}
+		})
+	}
+
+	for e := range ExtOp(16) {
+		if e.Valid() {
+			continue
+		}
+		if _, err := Encode(ext(e, 0, 0)); err == nil {
+			t.Errorf("Encode of unassigned extended operation %d succeeded", e)
+		}
+	}
+}
@@ -0,0 +1,82 @@
+package emulator
+
+import "fmt"
+
+// Fault describes an instruction the machine could not execute.
+type Fault struct {
+	Reason      HaltReason `json:"reason"`
+	PC          uint16     `json:"pc"`
+	Instruction uint16     `json:"instruction"`
+	Opcode      Opcode     `json:"opcode"`
+	// Message explains the fault in a sentence, for showing to the user.
+	Message string `json:"message"`
+}
+
+// faultMessages explains each reason an instruction can fault.
+var faultMessages = map[HaltReason]string{
+	HaltPCOutOfBounds:      "The program counter ran past the end of memory.",
+	HaltUnknownInstruction: "The instruction word is not a valid instruction.",
+	HaltMemoryFault:        "The instruction accessed an address outside memory.",
+	HaltROMWrite:           "The instruction wrote to read-only memory.",
+	HaltWatchdog:           "The program ran too long without halting and was stopped by the watchdog.",
+	HaltOverflow:           "A trapping arithmetic instruction overflowed.",
+	HaltBadBlock:           "The instruction used a block the block device does not have, or there is no block device.",
+	HaltDeviceError:        "The block device failed to read or write a block.",
+	HaltSelfModifying:      "The instruction wrote into the program's own code.",
+}
+
+// FaultMode selects what happens when an instruction faults.
+type FaultMode int
+
+const (
+	// BreakOnFault stops the machine at the faulting instruction. This is
+	// the default.
+	BreakOnFault FaultMode = iota
+	// ContinueOnFault skips the faulting instruction and keeps running.
+	// Faults that leave nothing sensible to continue with, such as the PC
+	// running off the end of memory, still stop the machine.
+	ContinueOnFault
+)
+
+// WithFaultMode sets whether faults stop the machine or are skipped. Either
+// way the most recent fault is recorded and reported in GetState.
+func WithFaultMode(mode FaultMode) Option {
+	return func(c *MonTanaMiniComputer) {
+		c.faultMode = mode
+	}
+}
+
+// LastFault returns the most recent fault since the program was loaded, or
+// nil if there has been none.
+func (c *MonTanaMiniComputer) LastFault() *Fault {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	if c.lastFault == nil {
+		return nil
+	}
+	f := *c.lastFault
+	return &f
+}
+
+// fault records that the instruction word at pc could not be executed and,
+// depending on the fault mode, stops the machine. It reports whether the
+// machine was stopped.
+func (c *MonTanaMiniComputer) fault(reason HaltReason, pc, word uint16, fatal bool) bool {
+	c.lastFault = &Fault{
+		Reason:      reason,
+		PC:          pc,
+		Instruction: word,
+		Opcode:      Decode(word).Op,
+		Message:     faultMessages[reason],
+	}
+	if c.metrics.Faults == nil {
+		c.metrics.Faults = make(map[HaltReason]uint64)
+	}
+	c.metrics.Faults[reason]++
+	c.logger.Warn("instruction fault", "reason", reason, "pc", pc, "instruction", fmt.Sprintf("0x%04X", word))
+	if !fatal && c.faultMode == ContinueOnFault {
+		return false
+	}
+	c.halt(reason)
+	return true
+}
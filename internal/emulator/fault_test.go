@@ -0,0 +1,150 @@
+package emulator
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"log/slog"
+	"testing"
+)
+
+func TestUnknownInstructionLogsWarning(t *testing.T) {
+	var buf bytes.Buffer
+	logger := slog.New(slog.NewJSONHandler(&buf, &slog.HandlerOptions{Level: slog.LevelDebug}))
+	c := New(WithMemorySize(testMemorySize), WithLogger(logger))
+	// Every opcode and extended operation is assigned, so the invalid
+	// instruction is LW2 into a register pair that runs past SR.
+	load(t, c, program(t, ext(ExtLW2, SR, R0)))
+	c.StepState()
+
+	var entry struct {
+		Level  string `json:"level"`
+		Msg    string `json:"msg"`
+		Reason string `json:"reason"`
+	}
+	if err := json.Unmarshal(buf.Bytes(), &entry); err != nil {
+		t.Fatalf("want exactly one log entry, got %q: %v", buf.String(), err)
+	}
+	if entry.Level != slog.LevelWarn.String() || entry.Reason != string(HaltUnknownInstruction) {
+		t.Errorf("logged %+v, want a warning for %q", entry, HaltUnknownInstruction)
+	}
+}
+
+func TestFaultMode(t *testing.T) {
+	bad := ext(ExtLW2, SR, R0) // LW2 into a register pair past SR
+	badWord, err := Encode(bad)
+	if err != nil {
+		t.Fatal(err)
+	}
+	code := program(t, nop, bad, liw(R0, 3), halt)
+
+	tests := []struct {
+		name     string
+		mode     FaultMode
+		halt     HaltReason
+		exitCode uint16
+	}{
+		{"break on fault", BreakOnFault, HaltUnknownInstruction, 0},
+		{"continue on fault", ContinueOnFault, HaltInstruction, 3},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := newTestComputer(t, WithFaultMode(tt.mode))
+			load(t, c, code)
+			result := c.RunToCompletion()
+			if result.HaltReason != tt.halt || result.ExitCode != tt.exitCode {
+				t.Fatalf("halt reason %q, exit code %d; want %q, %d", result.HaltReason, result.ExitCode, tt.halt, tt.exitCode)
+			}
+			want := Fault{
+				Reason:      HaltUnknownInstruction,
+				PC:          2,
+				Instruction: badWord,
+				Opcode:      OpEXT,
+				Message:     faultMessages[HaltUnknownInstruction],
+			}
+			if f := c.LastFault(); f == nil || *f != want {
+				t.Errorf("LastFault = %+v, want %+v", f, want)
+			}
+			if state := c.GetState(); state["fault"] == nil {
+				t.Error("fault missing from state")
+			}
+
+			load(t, c, code)
+			if f := c.LastFault(); f != nil {
+				t.Errorf("LastFault after loading again = %+v, want nil", f)
+			}
+		})
+	}
+}
+
+func TestFatalFaultStopsEvenWhenContinuing(t *testing.T) {
+	c := newTestComputer(t, WithFaultMode(ContinueOnFault))
+	load(t, c, program(t, nop))
+	c.Registers[PC] = testMemorySize - 1
+	if r := c.RunToCompletion(); r.HaltReason != HaltPCOutOfBounds {
+		t.Errorf("halt reason %q, want %q", r.HaltReason, HaltPCOutOfBounds)
+	}
+}
+
+func TestFaultInState(t *testing.T) {
+	loop := ri(OpBZ, 0, -1) // branches to itself forever
+	tests := []struct {
+		name   string
+		opts   []Option
+		setup  []Instruction // executed before bad
+		bad    Instruction
+		reason HaltReason
+	}{
+		{"unknown instruction", nil, nil, ext(ExtLW2, SR, R0), HaltUnknownInstruction},
+		{"memory fault", nil, []Instruction{liw(R0, 0xFFFF)}, ri(OpLW, R1, 0), HaltMemoryFault},
+		{"write to ROM", nil, []Instruction{liw(R0, 0x100)}, ri(OpSW, R1, 0), HaltROMWrite},
+		{"self-modifying code", []Option{WithSelfModifyingFault()}, []Instruction{liw(R0, 0)}, ri(OpSW, R1, 0), HaltSelfModifying},
+		{"overflow", nil, []Instruction{liw(R1, 0x7FFF), liw(R2, 1)}, ext(ExtADDO, R1, R2), HaltOverflow},
+		{"no block device", nil, nil, ext(ExtBSEEK, R0, 0), HaltBadBlock},
+		{"device error", []Option{WithBlockDevice(&memBlocks{blocks: newMemBlocks(1).blocks, err: errors.New("disk on fire")})}, nil, ext(ExtBWRITE, R0, 0), HaltDeviceError},
+		{"watchdog", []Option{WithWatchdog(3, 0)}, []Instruction{nop, nop}, loop, HaltWatchdog},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := newTestComputer(t, tt.opts...)
+			code := program(t, tt.setup...)
+			at := uint16(len(code))
+			code = append(code, program(t, tt.bad, halt)...)
+			load(t, c, code)
+			if err := c.SetReadOnly(0x100, 0x101); err != nil {
+				t.Fatal(err)
+			}
+			if result := c.RunToCompletion(); result.HaltReason != tt.reason {
+				t.Fatalf("halt reason %q, want %q", result.HaltReason, tt.reason)
+			}
+			word, _ := Encode(tt.bad)
+			want := Fault{Reason: tt.reason, PC: at, Instruction: word, Opcode: tt.bad.Op, Message: faultMessages[tt.reason]}
+			got, ok := c.GetState()["fault"].(*Fault)
+			if !ok || got == nil || *got != want {
+				t.Fatalf("state fault = %+v, want %+v", got, want)
+			}
+			if want.Message == "" {
+				t.Errorf("no message for %q", tt.reason)
+			}
+
+			c.Reset()
+			if f := c.GetState()["fault"]; f != (*Fault)(nil) {
+				t.Errorf("state fault after Reset = %+v, want nil", f)
+			}
+		})
+	}
+
+	// The PC running off memory faults without an instruction to blame.
+	c := newTestComputer(t)
+	load(t, c, program(t, nop))
+	c.Registers[PC] = testMemorySize - 1
+	c.RunToCompletion()
+	data, err := json.Marshal(c.GetState()["fault"])
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := `{"reason":"pc-out-of-bounds","pc":4095,"instruction":0,"opcode":0,"message":"` + faultMessages[HaltPCOutOfBounds] + `"}`
+	if string(data) != want {
+		t.Errorf("fault JSON %s, want %s", data, want)
+	}
+}
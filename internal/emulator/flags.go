@@ -0,0 +1,51 @@
+package emulator
+
+// Flags is the status register decoded into its named flags.
+type Flags struct {
+	Zero            bool `json:"zero"`
+	Carry           bool `json:"carry"`
+	Negative        bool `json:"negative"`
+	Overflow        bool `json:"overflow"`
+	InterruptEnable bool `json:"interruptEnable"`
+}
+
+// FlagNames maps the name of each flag, as used in Flags' JSON encoding,
+// to its bit in SR.
+var FlagNames = map[string]uint16{
+	"zero":            FlagZero,
+	"carry":           FlagCarry,
+	"negative":        FlagNegative,
+	"overflow":        FlagOverflow,
+	"interruptEnable": FlagInterruptEnable,
+}
+
+// DecodeFlags returns the flags set in the status register value sr.
+func DecodeFlags(sr uint16) Flags {
+	return Flags{
+		Zero:            sr&FlagZero != 0,
+		Carry:           sr&FlagCarry != 0,
+		Negative:        sr&FlagNegative != 0,
+		Overflow:        sr&FlagOverflow != 0,
+		InterruptEnable: sr&FlagInterruptEnable != 0,
+	}
+}
+
+// Flags returns the status register's flags.
+func (c *MonTanaMiniComputer) Flags() Flags {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	return DecodeFlags(c.Registers[SR])
+}
+
+// SetFlag sets or clears flag, one of the Flag bits, in the status register,
+// leaving the other bits alone.
+func (c *MonTanaMiniComputer) SetFlag(flag uint16, on bool) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	if on {
+		c.Registers[SR] |= flag
+	} else {
+		c.Registers[SR] &^= flag
+	}
+	c.notifyObservers()
+}
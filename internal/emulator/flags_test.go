@@ -0,0 +1,85 @@
+package emulator
+
+import (
+	"encoding/json"
+	"testing"
+)
+
+func TestDecodeFlags(t *testing.T) {
+	tests := []struct {
+		sr   uint16
+		want Flags
+	}{
+		{0, Flags{}},
+		{FlagZero, Flags{Zero: true}},
+		{FlagCarry, Flags{Carry: true}},
+		{FlagNegative, Flags{Negative: true}},
+		{FlagOverflow, Flags{Overflow: true}},
+		{FlagInterruptEnable, Flags{InterruptEnable: true}},
+		{FlagZero | FlagOverflow, Flags{Zero: true, Overflow: true}},
+		{0xFFE0, Flags{}}, // bits with no flag
+		{0xFFFF, Flags{true, true, true, true, true}},
+	}
+	for _, tt := range tests {
+		if got := DecodeFlags(tt.sr); got != tt.want {
+			t.Errorf("DecodeFlags(0x%04X) = %+v, want %+v", tt.sr, got, tt.want)
+		}
+	}
+}
+
+// TestFlagNames checks that each flag is named as in the JSON encoding of
+// Flags, and that the names cover every flag.
+func TestFlagNames(t *testing.T) {
+	var all uint16
+	for name, flag := range FlagNames {
+		data, err := json.Marshal(DecodeFlags(flag))
+		if err != nil {
+			t.Fatal(err)
+		}
+		var decoded map[string]bool
+		if err := json.Unmarshal(data, &decoded); err != nil {
+			t.Fatal(err)
+		}
+		for key, on := range decoded {
+			if on != (key == name) {
+				t.Errorf("flag %s encodes as %s", name, data)
+			}
+		}
+		all |= flag
+	}
+	if want := FlagZero | FlagCarry | FlagNegative | FlagOverflow | FlagInterruptEnable; all != want {
+		t.Errorf("FlagNames cover 0x%04X, want 0x%04X", all, want)
+	}
+}
+
+func TestSetFlag(t *testing.T) {
+	tests := []struct {
+		name string
+		sr   uint16
+		flag uint16
+		on   bool
+		want uint16
+	}{
+		{"set", 0, FlagCarry, true, FlagCarry},
+		{"set again", FlagCarry, FlagCarry, true, FlagCarry},
+		{"clear", FlagCarry | FlagZero, FlagCarry, false, FlagZero},
+		{"clear unset", FlagZero, FlagNegative, false, FlagZero},
+		{"other bits kept", 0xFF00, FlagInterruptEnable, true, 0xFF00 | FlagInterruptEnable},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := newTestComputer(t)
+			c.Registers[SR] = tt.sr
+			c.SetFlag(tt.flag, tt.on)
+			if c.Registers[SR] != tt.want {
+				t.Errorf("SR = 0x%04X, want 0x%04X", c.Registers[SR], tt.want)
+			}
+			if got, want := c.Flags(), DecodeFlags(tt.want); got != want {
+				t.Errorf("Flags() = %+v, want %+v", got, want)
+			}
+			if got := c.GetState()["flags"]; got != DecodeFlags(tt.want) {
+				t.Errorf("state flags = %+v, want %+v", got, DecodeFlags(tt.want))
+			}
+		})
+	}
+}
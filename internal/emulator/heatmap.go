@@ -0,0 +1,70 @@
+package emulator
+
+// accessCounts tracks how often the running program reads and writes each
+// memory address, for rendering a heatmap.
+type accessCounts struct {
+	enabled bool
+	reads   []uint64
+	writes  []uint64
+}
+
+// WithAccessCounting enables per-address read/write counting from startup.
+func WithAccessCounting() Option {
+	return func(c *MonTanaMiniComputer) {
+		c.access.enable(len(c.Memory))
+	}
+}
+
+// SetAccessCounting turns per-address read/write counting on or off. Counting
+// is off by default to keep the execution loop as cheap as possible; turning
+// it off discards any counts collected so far.
+func (c *MonTanaMiniComputer) SetAccessCounting(enabled bool) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	if enabled {
+		c.access.enable(len(c.Memory))
+	} else {
+		c.access = accessCounts{}
+	}
+}
+
+// Heatmap returns copies of the read and write counts for count addresses
+// starting at start. The range is clipped to the end of memory. Both slices
+// are nil when access counting is disabled.
+func (c *MonTanaMiniComputer) Heatmap(start, count int) (reads, writes []uint64) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	if !c.access.enabled || start < 0 || start >= len(c.access.reads) {
+		return nil, nil
+	}
+	end := min(start+count, len(c.access.reads))
+	reads = append([]uint64(nil), c.access.reads[start:end]...)
+	writes = append([]uint64(nil), c.access.writes[start:end]...)
+	return reads, writes
+}
+
+func (a *accessCounts) enable(size int) {
+	if a.enabled {
+		return
+	}
+	a.enabled = true
+	a.reads = make([]uint64, size)
+	a.writes = make([]uint64, size)
+}
+
+func (a *accessCounts) reset() {
+	clear(a.reads)
+	clear(a.writes)
+}
+
+func (a *accessCounts) countRead(addr uint16) {
+	if a.enabled && int(addr) < len(a.reads) {
+		a.reads[addr]++
+	}
+}
+
+func (a *accessCounts) countWrite(addr uint16) {
+	if a.enabled && int(addr) < len(a.writes) {
+		a.writes[addr]++
+	}
+}
@@ -0,0 +1,71 @@
+package emulator
+
+import (
+	"slices"
+	"testing"
+)
+
+func TestAccessCounting(t *testing.T) {
+	// R0 holds the base address; LW and SW with a small immediate use it.
+	code := program(t,
+		liw(R0, 0x100),
+		ri(OpLW, R1, 4), // read 0x104
+		ri(OpLW, R1, 4), // and again
+		ri(OpSW, R1, 8), // write 0x108
+		halt,
+	)
+	tests := []struct {
+		name   string
+		start  int
+		count  int
+		reads  []uint64
+		writes []uint64
+	}{
+		{"loaded word", 0x104, 2, []uint64{2, 0}, []uint64{0, 0}},
+		{"stored word", 0x108, 2, []uint64{0, 0}, []uint64{1, 0}},
+		{"fetches are not counted", 0, 4, []uint64{0, 0, 0, 0}, []uint64{0, 0, 0, 0}},
+		{"clipped to memory", testMemorySize - 1, 4, []uint64{0}, []uint64{0}},
+		{"outside memory", testMemorySize, 4, nil, nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := newTestComputer(t, WithAccessCounting())
+			load(t, c, code)
+			c.RunToCompletion()
+			reads, writes := c.Heatmap(tt.start, tt.count)
+			if !slices.Equal(reads, tt.reads) || !slices.Equal(writes, tt.writes) {
+				t.Errorf("Heatmap(0x%X, %d) = %v, %v; want %v, %v", tt.start, tt.count, reads, writes, tt.reads, tt.writes)
+			}
+		})
+	}
+}
+
+func TestAccessCountingToggle(t *testing.T) {
+	code := program(t, liw(R0, 0x100), ri(OpSW, R1, 0), halt)
+	c := newTestComputer(t)
+	load(t, c, code)
+	c.RunToCompletion()
+	if reads, writes := c.Heatmap(0x100, 2); reads != nil || writes != nil {
+		t.Fatalf("counts collected while disabled: %v, %v", reads, writes)
+	}
+
+	c.SetAccessCounting(true)
+	load(t, c, code)
+	c.RunToCompletion()
+	if _, writes := c.Heatmap(0x100, 1); !slices.Equal(writes, []uint64{1}) {
+		t.Fatalf("writes after enabling = %v, want [1]", writes)
+	}
+
+	c.Reset()
+	if _, writes := c.Heatmap(0x100, 1); !slices.Equal(writes, []uint64{0}) {
+		t.Errorf("writes after Reset = %v, want [0]", writes)
+	}
+
+	load(t, c, code)
+	c.RunToCompletion()
+	c.SetAccessCounting(false)
+	c.SetAccessCounting(true)
+	if _, writes := c.Heatmap(0x100, 1); !slices.Equal(writes, []uint64{0}) {
+		t.Errorf("writes after turning counting off and on = %v, want [0]", writes)
+	}
+}
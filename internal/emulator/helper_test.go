@@ -0,0 +1,81 @@
+package emulator
+
+import (
+	"encoding/binary"
+	"log/slog"
+	"testing"
+)
+
+// testMemorySize is the memory tests give machines, since MemorySize is too
+// small to hold most test programs.
+const testMemorySize = 0x1000
+
+// newTestComputer returns a machine with testMemorySize bytes of memory and
+// the stack at its top.
+func newTestComputer(t *testing.T, opts ...Option) *MonTanaMiniComputer {
+	t.Helper()
+	// Faults are expected in many tests, so keep their warnings quiet.
+	base := []Option{WithLogger(slog.New(slog.DiscardHandler)), WithMemorySize(testMemorySize)}
+	return New(append(base, opts...)...)
+}
+
+// program encodes instructions into machine code, including the operand
+// word of wide instructions.
+func program(t testing.TB, instructions ...Instruction) []byte {
+	t.Helper()
+	var code []byte
+	for _, ins := range instructions {
+		word, err := Encode(ins)
+		if err != nil {
+			t.Fatalf("Encode(%+v): %v", ins, err)
+		}
+		code = binary.BigEndian.AppendUint16(code, word)
+		if ins.Width() > WordSize {
+			code = binary.BigEndian.AppendUint16(code, ins.Operand)
+		}
+	}
+	return code
+}
+
+// rrr, ri and ext build instructions of each format for program.
+func rrr(op Opcode, d, s, t int) Instruction {
+	return Instruction{Op: op, RegD: uint8(d), RegS: uint8(s), RegT: uint8(t)}
+}
+
+func ri(op Opcode, d, imm int) Instruction {
+	return Instruction{Op: op, RegD: uint8(d), Imm: int16(imm)}
+}
+
+func ext(e ExtOp, d, s int) Instruction {
+	return Instruction{Op: OpEXT, RegD: uint8(d), RegS: uint8(s), RegT: uint8(e)}
+}
+
+// liw loads the 16-bit value v into register d.
+func liw(d int, v uint16) Instruction {
+	return Instruction{Op: OpEXT, RegD: uint8(d), RegT: uint8(ExtLIW), Operand: v}
+}
+
+var (
+	nop  = Instruction{Op: OpNOP}
+	halt = Instruction{Op: OpHALT}
+)
+
+// load loads code at address 0 of c, failing the test if it does not fit.
+func load(t *testing.T, c *MonTanaMiniComputer, code []byte) {
+	t.Helper()
+	if err := c.LoadProgram(code, 0); err != nil {
+		t.Fatalf("LoadProgram: %v", err)
+	}
+}
+
+// stepN executes n instructions.
+func stepN(c *MonTanaMiniComputer, n int) {
+	for range n {
+		c.StepState()
+	}
+}
+
+// observerFunc adapts a function to the Observer interface.
+type observerFunc func(*MonTanaMiniComputer)
+
+func (f observerFunc) Update(c *MonTanaMiniComputer) { f(c) }
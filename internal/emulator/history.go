@@ -0,0 +1,152 @@
+package emulator
+
+import "errors"
+
+// history is a bounded undo log of executed instructions, letting the
+// machine step backward. Each entry holds the state from just before one
+// step: the register file, the previous contents of any memory the step
+// overwrote, so self-modifying code is undone too, the input it consumed
+// and everything else a program can observe.
+type history struct {
+	entries []historyEntry // ring buffer; nil when history is disabled
+	head    int            // index of the oldest entry
+	size    int
+}
+
+type historyEntry struct {
+	registers        [16]uint16
+	haltReason       HaltReason
+	lastFault        *Fault
+	writes           []memoryWrite
+	inputRead        []byte // input bytes the step consumed, in order
+	output           []byte // the output and console screen before the step; both
+	screen           []byte // are only ever appended to or replaced, so these stay valid
+	instructionCount uint64
+	exitCode         uint16
+	block            int
+	rng              rngState
+}
+
+// memoryWrite records the bytes a word write replaced.
+type memoryWrite struct {
+	addr uint16
+	old  [WordSize]byte
+}
+
+// WithHistory keeps an undo log of the last size instructions so StepBack
+// can reverse them. History is disabled by default.
+func WithHistory(size int) Option {
+	return func(c *MonTanaMiniComputer) {
+		if size > 0 {
+			c.history = history{entries: make([]historyEntry, size)}
+		}
+	}
+}
+
+// StepBack reverses the most recently executed instruction, restoring the
+// registers, any memory it wrote, the input it read, the output it wrote,
+// the instruction count and the RAND sequence, and pauses the machine.
+// Blocks written to the block device stay written. Running again
+// from a breakpoint reached this way does not stop at it a second time. It
+// returns an error if history is disabled or exhausted.
+func (c *MonTanaMiniComputer) StepBack() error {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	if c.history.entries == nil {
+		return errors.New("history is disabled")
+	}
+	e, ok := c.history.pop()
+	if !ok {
+		return errors.New("no more history")
+	}
+	for i := len(e.writes) - 1; i >= 0; i-- {
+		w := e.writes[i]
+		copy(c.Memory[w.addr:], w.old[:])
+	}
+	c.Registers = e.registers
+	c.haltReason = e.haltReason
+	c.lastFault = e.lastFault
+	c.input = append(append([]byte(nil), e.inputRead...), c.input...)
+	c.output = e.output
+	c.console.screen = e.screen
+	c.instructionCount = e.instructionCount
+	c.exitCode = e.exitCode
+	c.blocks.block = e.block
+	c.rng.restore(e.rng)
+	c.Running = false
+	c.breakpoints.resumeFrom(c.Registers[PC])
+	c.notifyObservers()
+	return nil
+}
+
+// record starts a new history entry capturing c's state before a step.
+func (h *history) record(c *MonTanaMiniComputer) {
+	if h.entries == nil {
+		return
+	}
+	var e *historyEntry
+	if h.size < len(h.entries) {
+		e = &h.entries[(h.head+h.size)%len(h.entries)]
+		h.size++
+	} else {
+		// Full: overwrite the oldest entry.
+		e = &h.entries[h.head]
+		h.head = (h.head + 1) % len(h.entries)
+	}
+	*e = historyEntry{
+		registers:        c.Registers,
+		haltReason:       c.haltReason,
+		lastFault:        c.lastFault,
+		writes:           e.writes[:0],
+		inputRead:        e.inputRead[:0],
+		output:           c.output,
+		screen:           c.console.screen,
+		instructionCount: c.instructionCount,
+		exitCode:         c.exitCode,
+		block:            c.blocks.block,
+		rng:              c.rng.state(),
+	}
+}
+
+// current returns the entry of the step in progress, or nil if history is
+// disabled.
+func (h *history) current() *historyEntry {
+	if h.size == 0 {
+		return nil
+	}
+	return &h.entries[(h.head+h.size-1)%len(h.entries)]
+}
+
+// recordRead notes that the step in progress consumed input byte b.
+func (h *history) recordRead(b byte) {
+	if e := h.current(); e != nil {
+		e.inputRead = append(e.inputRead, b)
+	}
+}
+
+// recordWrite notes the current contents of the word at addr, which the step
+// in progress is about to overwrite.
+func (h *history) recordWrite(memory []byte, addr uint16) {
+	e := h.current()
+	if e == nil {
+		return
+	}
+	w := memoryWrite{addr: addr}
+	copy(w.old[:], memory[addr:])
+	e.writes = append(e.writes, w)
+}
+
+func (h *history) pop() (historyEntry, bool) {
+	if h.size == 0 {
+		return historyEntry{}, false
+	}
+	h.size--
+	return h.entries[(h.head+h.size)%len(h.entries)], true
+}
+
+// clear discards all entries, e.g. when memory is replaced wholesale by a
+// load or reset, which the undo log cannot reverse.
+func (h *history) clear() {
+	h.head = 0
+	h.size = 0
+}
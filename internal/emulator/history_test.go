@@ -0,0 +1,122 @@
+package emulator
+
+import "testing"
+
+// observableState is everything a program can observe, for comparing a
+// machine before a step and after stepping back.
+type observableState struct {
+	registers        [16]uint16
+	memory           string
+	input, output    string
+	screen           string
+	instructionCount uint64
+	exitCode         uint16
+	haltReason       HaltReason
+	nextRand         uint16
+}
+
+func observe(c *MonTanaMiniComputer) observableState {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	s := observableState{
+		registers:        c.Registers,
+		memory:           string(c.Memory),
+		input:            string(c.input),
+		output:           string(c.output),
+		screen:           string(c.console.screen),
+		instructionCount: c.instructionCount,
+		exitCode:         c.exitCode,
+		haltReason:       c.haltReason,
+	}
+	// Peek at the next random number without disturbing the sequence.
+	saved := c.rng.state()
+	s.nextRand = c.rng.next()
+	c.rng.restore(saved)
+	return s
+}
+
+func TestStepBackRestoresState(t *testing.T) {
+	const console = 0x800
+	tests := []struct {
+		name string
+		ins  []Instruction // setup instructions, then the one stepped back
+	}{
+		{"ADD", []Instruction{liw(R1, 3), liw(R2, 4), rrr(OpADD, R3, R1, R2)}},
+		{"SUB wraps", []Instruction{liw(R2, 1), rrr(OpSUB, R3, R1, R2)}},
+		{"ADDI", []Instruction{ri(OpADDI, R1, 5)}},
+		{"SW", []Instruction{liw(R0, 0x100), liw(R1, 0xBEEF), ri(OpSW, R1, 0)}},
+		{"SW over code", []Instruction{liw(R0, 0), liw(R1, 0xFFFF), ri(OpSW, R1, 0)}},
+		{"SW2", []Instruction{liw(R0, 0x100), liw(HI, 1), liw(LO, 2), ext(ExtSW2, HI, R0)}},
+		{"IN", []Instruction{ext(ExtIN, R1, 0)}},
+		{"OUT", []Instruction{liw(R1, 'x'), ext(ExtOUT, R1, 0)}},
+		{"console write", []Instruction{liw(R0, console), liw(R1, 'y'), ri(OpSW, R1, 0)}},
+		{"console clear", []Instruction{liw(R0, console), liw(R1, 'y'), ri(OpSW, R1, 0), liw(R1, ConsoleClear), ri(OpSW, R1, 2)}},
+		{"RAND", []Instruction{ext(ExtRAND, R1, 0), ext(ExtRAND, R1, 0)}},
+		{"RDCNT", []Instruction{nop, ext(ExtRDCNT, R1, 0)}},
+		{"HALT", []Instruction{liw(R0, 9), halt}},
+		{"fault", []Instruction{liw(R0, testMemorySize-1), ri(OpLW, R1, 0)}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := newTestComputer(t, WithHistory(8), WithConsole(console))
+			load(t, c, program(t, tt.ins...))
+			c.WriteInput([]byte("ab"))
+			stepN(c, len(tt.ins)-1)
+			before := observe(c)
+			c.StepState()
+			if after := observe(c); after.registers == before.registers && after.memory == before.memory &&
+				after.output == before.output && after.input == before.input && after.screen == before.screen && after.nextRand == before.nextRand {
+				t.Fatal("the step changed nothing, so the test proves nothing")
+			}
+			if err := c.StepBack(); err != nil {
+				t.Fatal(err)
+			}
+			after := observe(c)
+			if after.registers != before.registers {
+				t.Errorf("registers = %v, want %v", after.registers, before.registers)
+			}
+			if after.memory != before.memory {
+				t.Error("memory not restored")
+			}
+			after.registers, after.memory = before.registers, before.memory
+			if after != before {
+				t.Errorf("state = %+v, want %+v", after, before)
+			}
+		})
+	}
+}
+
+func TestStepBackInputWrittenSince(t *testing.T) {
+	c := newTestComputer(t, WithHistory(4))
+	load(t, c, program(t, ext(ExtIN, R1, 0), ext(ExtIN, R1, 0)))
+	c.WriteInput([]byte("a"))
+	c.StepState()
+	c.WriteInput([]byte("b"))
+	if err := c.StepBack(); err != nil {
+		t.Fatal(err)
+	}
+	if got := observe(c).input; got != "ab" {
+		t.Errorf("input after StepBack = %q, want %q", got, "ab")
+	}
+}
+
+func TestStepBackErrors(t *testing.T) {
+	c := newTestComputer(t)
+	if err := c.StepBack(); err == nil {
+		t.Error("StepBack without history succeeded")
+	}
+	c = newTestComputer(t, WithHistory(2))
+	load(t, c, program(t, nop, nop, nop))
+	stepN(c, 3)
+	for i := range 2 {
+		if err := c.StepBack(); err != nil {
+			t.Fatalf("StepBack %d: %v", i+1, err)
+		}
+	}
+	if err := c.StepBack(); err == nil {
+		t.Error("StepBack beyond the history size succeeded")
+	}
+	if pc := c.GetState()["pc"]; pc != uint16(2) {
+		t.Errorf("PC = %v, want 2", pc)
+	}
+}
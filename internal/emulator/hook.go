@@ -0,0 +1,19 @@
+package emulator
+
+// StepHook is called after each instruction the machine executes, with the
+// instruction, including ones that fault. It runs inside the step with the
+// machine locked, so it may read and change c's exported fields directly
+// but must not call its methods, which would deadlock.
+type StepHook func(c *MonTanaMiniComputer, ins Instruction)
+
+// OnStep registers hook to be called after every instruction, before
+// observers are notified, for tracers, profilers and other tools that need
+// to see each instruction. Hooks run in the order they were registered.
+//
+// Hooks are on the hot path: every instruction waits for all of them, so a
+// slow hook slows the machine down by as much, and batch runs most of all.
+func (c *MonTanaMiniComputer) OnStep(hook StepHook) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	c.stepHooks = append(c.stepHooks, hook)
+}
@@ -0,0 +1,57 @@
+package emulator
+
+import (
+	"slices"
+	"testing"
+)
+
+func TestOnStep(t *testing.T) {
+	tests := []struct {
+		name string
+		code []Instruction
+		want []string // the mnemonic of each instruction hooks see
+	}{
+		{"one per instruction", []Instruction{liw(R1, 5), rrr(OpADD, R2, R1, R1), nop, halt}, []string{"LIW", "ADD", "NOP", "HALT"}},
+		{"faulting instruction", []Instruction{liw(R1, testMemorySize-0x10), ri(OpLW, R2, 0x10), halt}, []string{"LIW", "LW"}},
+		{"unknown instruction", []Instruction{ext(ExtLW2, SR, R0)}, []string{"LW2"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := newTestComputer(t)
+			load(t, c, program(t, tt.code...))
+			var seen []string
+			c.OnStep(func(c *MonTanaMiniComputer, ins Instruction) { seen = append(seen, ins.Mnemonic) })
+			c.RunToCompletion()
+			if !slices.Equal(seen, tt.want) {
+				t.Errorf("hook saw %v, want %v", seen, tt.want)
+			}
+		})
+	}
+}
+
+// TestOnStepSeesEffects checks that hooks run in order after the
+// instruction has taken effect, with the whole decoded instruction.
+func TestOnStepSeesEffects(t *testing.T) {
+	c := newTestComputer(t)
+	load(t, c, program(t, liw(R1, 0xBEEF), halt))
+	var calls []string
+	c.OnStep(func(c *MonTanaMiniComputer, ins Instruction) {
+		if ins.Operand != 0xBEEF || c.Registers[R1] != 0xBEEF || c.Registers[PC] != 4 {
+			t.Errorf("first hook saw operand 0x%04X, R1 0x%04X, PC %d; want 0xBEEF, 0xBEEF, 4", ins.Operand, c.Registers[R1], c.Registers[PC])
+		}
+		calls = append(calls, "first")
+	})
+	c.OnStep(func(*MonTanaMiniComputer, Instruction) { calls = append(calls, "second") })
+	stepN(c, 1)
+	if !slices.Equal(calls, []string{"first", "second"}) {
+		t.Errorf("hooks called %v, want first then second", calls)
+	}
+
+	// Outside memory nothing is decoded, so hooks are not called.
+	calls = nil
+	c.Registers[PC] = testMemorySize
+	stepN(c, 1)
+	if len(calls) != 0 {
+		t.Errorf("hooks called %v for PC outside memory, want none", calls)
+	}
+}
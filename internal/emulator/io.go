@@ -0,0 +1,40 @@
+package emulator
+
+// The machine has a simple byte-stream console: IN takes the next byte from
+// an input FIFO that the host fills with WriteInput, and OUT appends a byte
+// to an output buffer the host reads with Output. No memory is involved.
+
+// inputEmpty is what IN loads when no input is waiting: -1, as an EOF
+// marker no byte value can be confused with.
+const inputEmpty = 0xFFFF
+
+// WriteInput queues data for the program to read with IN.
+func (c *MonTanaMiniComputer) WriteInput(data []byte) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	c.input = append(c.input, data...)
+}
+
+// Output returns a copy of everything the program has written with OUT
+// since the last reset.
+func (c *MonTanaMiniComputer) Output() []byte {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	return append([]byte(nil), c.output...)
+}
+
+// readInput removes and returns the next input byte, or inputEmpty.
+func (c *MonTanaMiniComputer) readInput() uint16 {
+	if len(c.input) == 0 {
+		return inputEmpty
+	}
+	b := c.input[0]
+	c.input = c.input[1:]
+	c.history.recordRead(b)
+	return uint16(b)
+}
+
+// writeOutput appends the low byte of value to the output.
+func (c *MonTanaMiniComputer) writeOutput(value uint16) {
+	c.output = append(c.output, byte(value))
+}
@@ -0,0 +1,48 @@
+package emulator
+
+import (
+	"bytes"
+	"testing"
+)
+
+func TestInputOutput(t *testing.T) {
+	tests := []struct {
+		name   string
+		input  string
+		code   []Instruction
+		output string
+		r1     uint16
+	}{
+		{"echo a byte", "hi", []Instruction{ext(ExtIN, R1, 0), ext(ExtOUT, R1, 0)}, "h", 'h'},
+		{"reads in order", "hi", []Instruction{ext(ExtIN, R1, 0), ext(ExtIN, R1, 0)}, "", 'i'},
+		{"empty input", "", []Instruction{ext(ExtIN, R1, 0)}, "", inputEmpty},
+		{"past the end", "x", []Instruction{ext(ExtIN, R1, 0), ext(ExtIN, R1, 0)}, "", inputEmpty},
+		{"low byte only", "", []Instruction{liw(R1, 0x1241), ext(ExtOUT, R1, 0)}, "A", 0x1241},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := newTestComputer(t)
+			load(t, c, program(t, append(tt.code, halt)...))
+			c.WriteInput([]byte(tt.input))
+			c.RunToCompletion()
+			if got := c.Output(); !bytes.Equal(got, []byte(tt.output)) {
+				t.Errorf("output %q, want %q", got, tt.output)
+			}
+			if c.Registers[R1] != tt.r1 {
+				t.Errorf("R1 = 0x%04X, want 0x%04X", c.Registers[R1], tt.r1)
+			}
+		})
+	}
+
+	// Reset discards both buffers.
+	c := newTestComputer(t)
+	load(t, c, program(t, ext(ExtOUT, R0, 0), halt))
+	c.WriteInput([]byte("left over"))
+	c.RunToCompletion()
+	c.Reset()
+	load(t, c, program(t, ext(ExtIN, R1, 0), halt))
+	c.RunToCompletion()
+	if len(c.Output()) != 0 || c.Registers[R1] != inputEmpty {
+		t.Errorf("after Reset, output %q and IN read 0x%04X; want both empty", c.Output(), c.Registers[R1])
+	}
+}
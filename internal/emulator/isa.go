@@ -0,0 +1,121 @@
+package emulator
+
+// InstructionSpec describes one instruction of the instruction set, for
+// tools and frontends. It is generated from the tables the decoder uses, so
+// it always matches what the machine executes.
+type InstructionSpec struct {
+	Mnemonic string `json:"mnemonic"`
+	Opcode   Opcode `json:"opcode"`
+	// Ext is the ExtOp in the RT field, for extended operations only.
+	Ext *ExtOp `json:"ext,omitempty"`
+	// Fields lists the instruction word fields the instruction uses, of
+	// "rd", "rs", "rt", "imm" and "n" (a bit number in the rs field), then
+	// "value" for an operand word following the instruction word. An
+	// instruction with both "rs" and "imm" has no rs field of its own: rs
+	// is the register numbered by the high nibble of the 8-bit imm, which
+	// is still added whole.
+	Fields  []string `json:"fields"`
+	Width   int      `json:"width"` // length in bytes
+	Summary string   `json:"summary"`
+}
+
+// formatFields lists the fields each format uses.
+var formatFields = map[Format][]string{
+	FormatNone: {},
+	FormatRRR:  {"rd", "rs", "rt"},
+	FormatRI:   {"rd", "rs", "imm"},
+}
+
+// ISA returns a description of every assigned instruction, opcodes first
+// and then extended operations, each in numeric order. OpEXT itself is
+// described by its extended operations rather than listed.
+func ISA() []InstructionSpec {
+	var specs []InstructionSpec
+	for op, info := range opcodes {
+		if info.Mnemonic == "" || Opcode(op) == OpEXT {
+			continue
+		}
+		fields := formatFields[info.Format]
+		if Opcode(op) == OpBZ {
+			fields = fields[1:] // tests rs, with no rd
+		}
+		specs = append(specs, InstructionSpec{
+			Mnemonic: info.Mnemonic,
+			Opcode:   Opcode(op),
+			Fields:   fields,
+			Width:    info.Width,
+			Summary:  info.Summary,
+		})
+	}
+	for ext, info := range extOps {
+		if info.Mnemonic == "" {
+			continue
+		}
+		ext := ExtOp(ext)
+		fields := []string{"rd", "rs"}
+		if info.BitIndex {
+			fields[1] = "n"
+		}
+		fields = fields[:info.Operands]
+		if info.Width > WordSize {
+			fields = append(fields, "value")
+		}
+		specs = append(specs, InstructionSpec{
+			Mnemonic: info.Mnemonic,
+			Opcode:   OpEXT,
+			Ext:      &ext,
+			Fields:   fields,
+			Width:    info.Width,
+			Summary:  info.Summary,
+		})
+	}
+	return specs
+}
+
+// Capabilities describes what this build of the machine supports, so
+// assemblers and frontends can avoid what it cannot run.
+type Capabilities struct {
+	// Instructions lists the mnemonic of every implemented instruction, in
+	// the order ISA describes them.
+	Instructions []string `json:"instructions"`
+	Features     Features `json:"features"`
+}
+
+// Features reports the machine's optional features.
+type Features struct {
+	// Interrupts is always false: the status register has an interrupt
+	// enable flag, but nothing raises interrupts yet.
+	Interrupts bool `json:"interrupts"`
+	// MMIO is whether the console's ports are mapped into memory; see
+	// WithConsole.
+	MMIO bool `json:"mmio"`
+	// BlockDevice is whether BSEEK, BREAD and BWRITE have a device to use.
+	BlockDevice bool `json:"blockDevice"`
+	// Turbo is always true: Run can execute several instructions per
+	// clock tick; see SetStepsPerTick.
+	Turbo bool `json:"turbo"`
+	// StepBack is whether step history is kept; see WithHistory.
+	StepBack bool `json:"stepBack"`
+	// Trace is whether executed instructions are traced; see WithTrace.
+	Trace bool `json:"trace"`
+}
+
+// Capabilities reports the instructions the machine implements, taken from
+// the same tables as ISA, and which optional features it has.
+func (c *MonTanaMiniComputer) Capabilities() Capabilities {
+	specs := ISA()
+	caps := Capabilities{Instructions: make([]string, len(specs))}
+	for i, spec := range specs {
+		caps.Instructions[i] = spec.Mnemonic
+	}
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	caps.Features = Features{
+		MMIO:        c.console.mapped,
+		BlockDevice: c.blocks.dev != nil,
+		Turbo:       true,
+		StepBack:    c.history.entries != nil,
+		Trace:       c.trace.entries != nil,
+	}
+	return caps
+}
@@ -0,0 +1,138 @@
+package emulator
+
+import (
+	"slices"
+	"testing"
+)
+
+// implemented lists the mnemonic of every assigned opcode other than OpEXT,
+// then of every assigned ExtOp, as ISA should.
+func implemented() []string {
+	var names []string
+	for op := range Opcode(16) {
+		if op.Valid() && op != OpEXT {
+			names = append(names, op.String())
+		}
+	}
+	for ext := range ExtOp(16) {
+		if ext.Valid() {
+			names = append(names, ext.String())
+		}
+	}
+	return names
+}
+
+func TestISA(t *testing.T) {
+	specs := ISA()
+	var names []string
+	for _, spec := range specs {
+		names = append(names, spec.Mnemonic)
+	}
+	if want := implemented(); !slices.Equal(names, want) {
+		t.Fatalf("ISA lists %v, want %v", names, want)
+	}
+
+	for _, spec := range specs {
+		t.Run(spec.Mnemonic, func(t *testing.T) {
+			ins := Instruction{Op: spec.Opcode}
+			if spec.Ext != nil {
+				ins.RegT = uint8(*spec.Ext)
+			}
+			word, err := Encode(ins)
+			if err != nil {
+				t.Fatalf("Encode: %v", err)
+			}
+			decoded := Decode(word)
+			if decoded.Mnemonic != spec.Mnemonic || decoded.Width() != spec.Width {
+				t.Errorf("decodes as %s of width %d, want %s of width %d", decoded.Mnemonic, decoded.Width(), spec.Mnemonic, spec.Width)
+			}
+			if spec.Summary == "" || spec.Fields == nil {
+				t.Errorf("summary %q, fields %v; want both given", spec.Summary, spec.Fields)
+			}
+		})
+	}
+
+	tests := []struct {
+		mnemonic string
+		fields   []string
+		width    int
+	}{
+		{"NOP", []string{}, 2},
+		{"ADD", []string{"rd", "rs", "rt"}, 2},
+		{"ADDI", []string{"rd", "rs", "imm"}, 2},
+		{"SW", []string{"rd", "rs", "imm"}, 2},
+		{"BZ", []string{"rs", "imm"}, 2},
+		{"RDCNT", []string{"rd"}, 2},
+		{"ADDO", []string{"rd", "rs"}, 2},
+		{"BSET", []string{"rd", "n"}, 2},
+		{"LIW", []string{"rd", "value"}, 4},
+	}
+	for _, tt := range tests {
+		i := slices.IndexFunc(specs, func(s InstructionSpec) bool { return s.Mnemonic == tt.mnemonic })
+		if i < 0 {
+			t.Errorf("%s not listed", tt.mnemonic)
+			continue
+		}
+		if !slices.Equal(specs[i].Fields, tt.fields) || specs[i].Width != tt.width {
+			t.Errorf("%s: fields %v, width %d; want %v, %d", tt.mnemonic, specs[i].Fields, specs[i].Width, tt.fields, tt.width)
+		}
+	}
+}
+
+func TestCapabilities(t *testing.T) {
+	tests := []struct {
+		name string
+		opts []Option
+		want Features
+	}{
+		{"default", nil, Features{Turbo: true}},
+		{"console", []Option{WithConsole(0x800)}, Features{MMIO: true, Turbo: true}},
+		{"block device", []Option{WithBlockDevice(newMemBlocks(2))}, Features{BlockDevice: true, Turbo: true}},
+		{"history", []Option{WithHistory(8)}, Features{Turbo: true, StepBack: true}},
+		{"everything", []Option{WithConsole(0x800), WithBlockDevice(newMemBlocks(2)), WithHistory(8)},
+			Features{MMIO: true, BlockDevice: true, Turbo: true, StepBack: true}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			caps := newTestComputer(t, tt.opts...).Capabilities()
+			if want := implemented(); !slices.Equal(caps.Instructions, want) {
+				t.Errorf("instructions %v, want %v", caps.Instructions, want)
+			}
+			if caps.Features != tt.want {
+				t.Errorf("features %+v, want %+v", caps.Features, tt.want)
+			}
+		})
+	}
+}
+
+// TestImmediateSelectsRS checks what ISA documents for instructions with
+// both rs and imm: rs is the high nibble of the immediate.
+func TestImmediateSelectsRS(t *testing.T) {
+	tests := []struct {
+		imm int16
+		rs  uint8
+	}{
+		{5, R0},
+		{0x25, R2},
+		{0x7F, R7},
+		{-1, SR},
+		{-128, 8},
+	}
+	for _, spec := range ISA() {
+		if !slices.Contains(spec.Fields, "imm") {
+			continue
+		}
+		if !slices.Contains(spec.Fields, "rs") {
+			t.Errorf("%s has an imm but no rs listed", spec.Mnemonic)
+		}
+		for _, tt := range tests {
+			word, err := Encode(Instruction{Op: spec.Opcode, RegD: R1, Imm: tt.imm})
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got := Decode(word).RegS; got != tt.rs {
+				t.Errorf("%s with imm %d: rs %d, want %d", spec.Mnemonic, tt.imm, got, tt.rs)
+			}
+		}
+	}
+}
@@ -0,0 +1,149 @@
+package emulator
+
+import (
+	"bytes"
+	"sync"
+	"testing"
+)
+
+func TestReadWriteMemory(t *testing.T) {
+	tests := []struct {
+		name    string
+		addr    uint16
+		data    []byte
+		wantErr bool
+	}{
+		{"start", 0, []byte{1, 2}, false},
+		{"middle", 0x100, []byte{0xDE, 0xAD, 0xBE, 0xEF}, false},
+		{"last byte", testMemorySize - 1, []byte{9}, false},
+		{"empty", testMemorySize, nil, false},
+		{"past end", testMemorySize - 1, []byte{1, 2}, true},
+		{"outside", testMemorySize, []byte{1}, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := newTestComputer(t)
+			err := c.WriteMemory(tt.addr, tt.data)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("WriteMemory error = %v, want error %v", err, tt.wantErr)
+			}
+			got, err := c.ReadMemory(tt.addr, len(tt.data))
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("ReadMemory error = %v, want error %v", err, tt.wantErr)
+			}
+			if tt.wantErr {
+				if !bytes.Equal(c.Memory, make([]byte, testMemorySize)) {
+					t.Error("failed write changed memory")
+				}
+				return
+			}
+			if !bytes.Equal(got, tt.data) {
+				t.Errorf("ReadMemory = %x, want %x", got, tt.data)
+			}
+		})
+	}
+
+	c := newTestComputer(t)
+	if _, err := c.ReadMemory(0, -1); err == nil {
+		t.Error("ReadMemory with negative length succeeded")
+	}
+}
+
+// TestMemoryConcurrentAccess reads and writes memory from several goroutines
+// while the machine executes. Run it with -race.
+func TestMemoryConcurrentAccess(t *testing.T) {
+	c := newTestComputer(t)
+	load(t, c, program(t, ri(OpBZ, 0, -1))) // branches to itself forever
+
+	const rounds = 200
+	var wg sync.WaitGroup
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		stepN(c, rounds)
+	}()
+	for i := range 4 {
+		addr := uint16(0x100 + 2*i)
+		wg.Add(2)
+		go func() {
+			defer wg.Done()
+			for n := range rounds {
+				if err := c.WriteMemory(addr, []byte{byte(i), byte(n)}); err != nil {
+					t.Error(err)
+					return
+				}
+			}
+		}()
+		go func() {
+			defer wg.Done()
+			for range rounds {
+				data, err := c.ReadMemory(addr, 2)
+				if err != nil {
+					t.Error(err)
+					return
+				}
+				if data[0] != 0 && data[0] != byte(i) {
+					t.Errorf("ReadMemory(0x%04X) = %x, written by another goroutine", addr, data)
+					return
+				}
+			}
+		}()
+	}
+	wg.Wait()
+
+	got, err := c.ReadMemory(0x100, 8)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []byte{0, rounds - 1, 1, rounds - 1, 2, rounds - 1, 3, rounds - 1}
+	if !bytes.Equal(got, want) {
+		t.Errorf("memory after writes = %x, want %x", got, want)
+	}
+}
+
+func TestMemoryFill(t *testing.T) {
+	tests := []struct {
+		name    string
+		opts    []Option
+		pattern uint16
+	}{
+		{"default", nil, 0},
+		{"poison", []Option{WithMemoryFill(0xDEAD)}, 0xDEAD},
+		{"low byte only", []Option{WithMemoryFill(0x00FF)}, 0x00FF},
+		{"high byte only", []Option{WithMemoryFill(0xA500)}, 0xA500},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := newTestComputer(t, tt.opts...)
+			// filled checks every word but those from skip up to end.
+			filled := func(when string, skip, end int) {
+				t.Helper()
+				for addr := 0; addr < len(c.Memory); addr += WordSize {
+					if addr >= skip && addr < end {
+						continue
+					}
+					if got := uint16(c.Memory[addr])<<8 | uint16(c.Memory[addr+1]); got != tt.pattern {
+						t.Fatalf("%s: word at 0x%04X = 0x%04X, want 0x%04X", when, addr, got, tt.pattern)
+					}
+				}
+			}
+			filled("on creation", 0, 0)
+
+			// The program reads a word it never wrote, then overwrites one.
+			code := program(t, ri(OpLW, R1, 0x40), liw(R2, 0x1234), ri(OpSW, R2, 0x42), halt)
+			load(t, c, code)
+			filled("after loading", 0, len(code))
+			c.RunToCompletion()
+			if c.Registers[R1] != tt.pattern {
+				t.Errorf("uninitialized read = 0x%04X, want 0x%04X", c.Registers[R1], tt.pattern)
+			}
+			if err := c.ReloadProgram(nil); err != nil {
+				t.Fatal(err)
+			}
+			filled("after reloading", 0, len(code))
+			c.RunToCompletion()
+			c.Reset()
+			filled("after reset", 0, 0)
+		})
+	}
+}
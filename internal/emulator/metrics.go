@@ -0,0 +1,23 @@
+package emulator
+
+import "maps"
+
+// Metrics are lifetime counters for monitoring. Unlike the per-program
+// statistics, Reset and loading programs do not clear them.
+type Metrics struct {
+	Instructions   uint64                // instructions executed
+	Faults         map[HaltReason]uint64 // faults, by reason
+	ProgramsLoaded uint64                // programs loaded, including reloads
+}
+
+// Metrics returns a copy of the machine's lifetime counters.
+func (c *MonTanaMiniComputer) Metrics() Metrics {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	m := c.metrics
+	m.Faults = maps.Clone(c.metrics.Faults)
+	if m.Faults == nil {
+		m.Faults = map[HaltReason]uint64{}
+	}
+	return m
+}
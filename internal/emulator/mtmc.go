@@ -2,166 +2,1121 @@ package emulator
 
 import (
 	"encoding/binary"
-	"log"
+	"errors"
+	"fmt"
+	"log/slog"
+	"maps"
+	"math"
+	"slices"
 	"sync"
+	"sync/atomic"
 	"time"
 )
 
 const (
+	// WordSize is the size in bytes of a word: an instruction, a register
+	// value, and the unit LW and SW move. Word access goes through
+	// fetchWord, readWord and writeWord, and addresses advance in steps of
+	// WordSize, so the machine's word size is defined here alone.
 	WordSize   = 2
 	MemorySize = 1 << 4 // 4096 bytes (4K)
 )
 
-// MonTanaMiniComputer represents the state of the virtual computer.
+// MonTanaMiniComputer represents the state of the virtual computer. Every
+// setting, including the clock delay, the run state and the breakpoints,
+// belongs to the instance; the package keeps no machine state of its own, so
+// independent machines, such as one per user, never affect each other.
 type MonTanaMiniComputer struct {
 	Memory    []byte
 	Registers [16]uint16
 	Running   bool
 	mutex     sync.Mutex
 	observers []Observer
+	logger    *slog.Logger
+
+	initialSP uint16
+	initialFP uint16
+	stepDelay time.Duration
+	sleep     func(time.Duration) // time.Sleep, replaceable in tests
+	ticks     <-chan time.Time    // Run's clock; nil means a real 1kHz ticker
+	tickSteps int                 // instructions Run executes per tick
+
+	haltReason       HaltReason
+	faultMode        FaultMode
+	lastFault        *Fault
+	watchdog         watchdog
+	access           accessCounts
+	opCounts         [16]uint64
+	instructionCount uint64
+	loaded           loadedProgram
+	history          history
+	trace            trace
+	readOnly         []addressRange
+	input            []byte
+	output           []byte
+	console          console
+	blocks           blockDevice
+	exitCode         uint16
+	autoRestart      bool
+	autoStart        bool
+	rng              rng
+	breakpoints      breakpoints
+	zeroRegister     int    // index of the hardwired zero register, or -1
+	memoryFill       uint16 // word pattern memory is reset to
+	coverage         coverage
+	delta            deltaTracker
+	lastTick         atomic.Int64 // UnixNano of Run's most recent clock tick
+	metrics          Metrics
+	throughput       throughput
+	stepHooks        []StepHook
+	annotations      map[uint16]string
+	selfModifying    selfModifying
+	executing        uint16            // address of the instruction step is executing
+	aliases          [16]string        // display name of each register, if aliased
+	notifications    chan notification // nil unless observers are notified asynchronously
 }
 
+// loadedProgram remembers the last program loaded, and any data loaded
+// alongside it, so it can be reloaded.
+type loadedProgram struct {
+	name  string
+	image Image
+	data  []Segment
+}
+
+// Segment is a run of bytes to be placed in memory at Address.
+type Segment struct {
+	Address uint16
+	Data    []byte
+}
+
+// Image is a loadable program: one or more segments plus the address at
+// which execution starts.
+type Image struct {
+	Segments []Segment
+	Entry    uint16
+	// Source optionally maps instruction addresses to the source text
+	// they were assembled from, for display alongside the disassembly.
+	Source map[uint16]string
+	// Labels optionally maps symbol names to addresses, for naming
+	// locations in the call stack.
+	Labels map[string]uint16
+	// Code optionally lists the ranges of the segments that hold
+	// instructions rather than data. Without it, all of every segment is
+	// taken to be code.
+	Code []CodeRange
+}
+
+// CodeRange is a run of instructions in an Image, from Start through End,
+// inclusive.
+type CodeRange struct {
+	Start uint16
+	End   uint16
+}
+
+// RegisterNames gives the assembly name of each register, by index.
+var RegisterNames = [16]string{
+	R0: "R0", R1: "R1", R2: "R2", R3: "R3", R4: "R4", R5: "R5", R6: "R6", R7: "R7",
+	GP: "GP", FP: "FP", SP: "SP", RA: "RA", HI: "HI", LO: "LO", PC: "PC", SR: "SR",
+}
+
+// Status register (SR) flag bits. Only the zero flag is set by instructions
+// so far; the others are reserved for them and can be set by debuggers.
+const (
+	FlagZero            uint16 = 1 << 0 // set by CAS on a successful swap and BTST on a 0 bit
+	FlagCarry           uint16 = 1 << 1
+	FlagNegative        uint16 = 1 << 2
+	FlagOverflow        uint16 = 1 << 3
+	FlagInterruptEnable uint16 = 1 << 4
+)
+
+// HaltReason describes why the machine last stopped running.
+type HaltReason string
+
+const (
+	HaltNone               HaltReason = ""
+	HaltInstruction        HaltReason = "halt"
+	HaltPCOutOfBounds      HaltReason = "pc-out-of-bounds"
+	HaltUnknownInstruction HaltReason = "unknown-instruction"
+	HaltMemoryFault        HaltReason = "memory-fault"
+	HaltROMWrite           HaltReason = "rom-write"
+	HaltWatchdog           HaltReason = "watchdog"
+	HaltOverflow           HaltReason = "arithmetic-overflow"
+)
+
+// Option configures a MonTanaMiniComputer at construction time.
+type Option func(*MonTanaMiniComputer)
+
 // Observer is an interface for components that need to be notified of computer state changes.
 type Observer interface {
 	Update(computer *MonTanaMiniComputer)
 }
 
+// WithLogger sets the logger used to report instruction faults, which are
+// logged at warn level. It defaults to slog.Default().
+func WithLogger(logger *slog.Logger) Option {
+	return func(c *MonTanaMiniComputer) {
+		c.logger = logger
+	}
+}
+
 // New creates a new MTMC instance.
-func New() *MonTanaMiniComputer {
+func New(opts ...Option) *MonTanaMiniComputer {
 	m := &MonTanaMiniComputer{
-		Memory: make([]byte, MemorySize),
+		Memory:       make([]byte, MemorySize),
+		logger:       slog.Default(),
+		sleep:        time.Sleep,
+		tickSteps:    1,
+		rng:          rng{seed: DefaultSeed},
+		zeroRegister: -1,
+		// Start the stack at the top of memory
+		initialSP: MemorySize - WordSize,
+		initialFP: MemorySize - WordSize,
 	}
-	// Initialize SP to the top of memory
-	m.Registers[SP] = MemorySize - 2
+	for _, opt := range opts {
+		opt(m)
+	}
+	m.fillMemory()
+	m.initStack()
 	return m
 }
 
-// AddObserver adds an observer to the computer.
+// WithMemorySize gives the machine size bytes of memory instead of
+// MemorySize, with the stack starting at the new top. It must come before
+// options that depend on the memory, such as WithStack and WithConsole. It
+// panics unless size is even and between 2 and 64K, the most a 16-bit
+// address can reach.
+func WithMemorySize(size int) Option {
+	return func(c *MonTanaMiniComputer) {
+		if size < WordSize || size > 1<<16 || size%WordSize != 0 {
+			panic(fmt.Sprintf("emulator: memory size %d must be even and between %d and %d", size, WordSize, 1<<16))
+		}
+		c.Memory = make([]byte, size)
+		c.initialSP, c.initialFP = uint16(size-WordSize), uint16(size-WordSize)
+	}
+}
+
+// WithStack sets the initial stack and frame pointers, which Reset also
+// restores. Both default to the last word of memory. It panics unless both
+// are even addresses within memory.
+func WithStack(sp, fp uint16) Option {
+	return func(c *MonTanaMiniComputer) {
+		for _, p := range []uint16{sp, fp} {
+			if p%WordSize != 0 || !c.wordInBounds(p) {
+				panic(fmt.Sprintf("emulator: stack address 0x%04X must be even and within memory", p))
+			}
+		}
+		c.initialSP, c.initialFP = sp, fp
+	}
+}
+
+// WithMemoryFill fills memory with copies of the word pattern, rather than
+// zeros, when the machine is created and on every reset, so a program that
+// reads memory it never wrote gets an obviously wrong value such as 0xDEAD
+// instead of a harmless-looking zero. Loading a program overwrites only the
+// addresses it occupies.
+func WithMemoryFill(pattern uint16) Option {
+	return func(c *MonTanaMiniComputer) {
+		c.memoryFill = pattern
+	}
+}
+
+// fillMemory sets every word of memory to the fill pattern.
+func (c *MonTanaMiniComputer) fillMemory() {
+	if c.memoryFill == 0 {
+		clear(c.Memory)
+		return
+	}
+	for i := range c.Memory {
+		if i%WordSize == 0 {
+			c.Memory[i] = byte(c.memoryFill >> 8)
+		} else {
+			c.Memory[i] = byte(c.memoryFill)
+		}
+	}
+}
+
+// WithZeroRegister hardwires register index reg to zero, as in many RISC
+// ISAs: instructions always read it as 0 and writes to it are discarded, so
+// MOV is ADD with the zero register and a compare against zero needs no
+// constant. By default no register is hardwired. It panics if reg is PC or
+// not a register index.
+func WithZeroRegister(reg int) Option {
+	return func(c *MonTanaMiniComputer) {
+		if reg < 0 || reg >= len(c.Registers) || reg == PC {
+			panic(fmt.Sprintf("emulator: register %d cannot be the zero register", reg))
+		}
+		c.zeroRegister = reg
+	}
+}
+
+// WithAutoStart makes loading a program start the machine running it
+// straight away. Without it a loaded program waits for SetRunning(true).
+// Either way a new machine starts paused, since there is nothing to run
+// until a program is loaded.
+func WithAutoStart() Option {
+	return func(c *MonTanaMiniComputer) {
+		c.autoStart = true
+	}
+}
+
+// initStack points SP and FP at their initial addresses.
+func (c *MonTanaMiniComputer) initStack() {
+	c.Registers[SP] = c.initialSP
+	c.Registers[FP] = c.initialFP
+}
+
+// AddObserver adds an observer to the computer. An observer that panics is
+// removed, and the panic logged, rather than stopping the machine.
 func (c *MonTanaMiniComputer) AddObserver(o Observer) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
 	c.observers = append(c.observers, o)
 }
 
-// notifyObservers notifies all observers of a state change.
-func (c *MonTanaMiniComputer) notifyObservers() {
-	for _, o := range c.observers {
-		o.Update(c)
+// RemoveObserver stops notifying o, e.g. once its client has gone away.
+func (c *MonTanaMiniComputer) RemoveObserver(o Observer) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	c.observers = slices.DeleteFunc(c.observers, func(x Observer) bool { return x == o })
+}
+
+// ObserverCount returns how many observers are registered.
+func (c *MonTanaMiniComputer) ObserverCount() int {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	return len(c.observers)
+}
+
+// LastTick returns when Run's clock last ticked, or the zero time if Run has
+// not been called. A tick more than a moment old means the execution loop
+// has stopped or is stuck.
+func (c *MonTanaMiniComputer) LastTick() time.Time {
+	if t := c.lastTick.Load(); t != 0 {
+		return time.Unix(0, t)
+	}
+	return time.Time{}
+}
+
+// WithClock drives Run from ticks instead of a real 1kHz ticker: Run does
+// one cycle for each value received, taking it as the current time, and
+// returns once ticks is closed. Tests use it to step the execution cycle
+// deterministically.
+func WithClock(ticks <-chan time.Time) Option {
+	return func(c *MonTanaMiniComputer) {
+		c.ticks = ticks
+	}
+}
+
+// WithStepsPerTick sets how many instructions Run executes on each tick of
+// its clock; see SetStepsPerTick.
+func WithStepsPerTick(n int) Option {
+	return func(c *MonTanaMiniComputer) {
+		c.tickSteps = max(n, 1)
 	}
 }
 
+// SetStepsPerTick sets how many instructions Run executes on each tick of
+// its clock, at most, so the machine can be sped up or, on a server running
+// many machines, given a fixed share of time. The default is 1, which with
+// the 1kHz clock is 1000 instructions a second. Values below 1 are taken as
+// 1. A tick's instructions stop early if the machine stops running, and
+// observers are notified once per tick rather than per instruction.
+func (c *MonTanaMiniComputer) SetStepsPerTick(n int) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	c.tickSteps = max(n, 1)
+}
+
+// StepsPerTick returns how many instructions Run executes per tick.
+func (c *MonTanaMiniComputer) StepsPerTick() int {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	return c.tickSteps
+}
+
 // Run starts the computer's clock and execution cycle.
 func (c *MonTanaMiniComputer) Run() {
-	ticker := time.NewTicker(time.Second / 1000) // 1kHz clock speed
-	defer ticker.Stop()
+	ticks := c.ticks
+	if ticks == nil {
+		ticker := time.NewTicker(time.Second / 1000) // 1kHz clock speed
+		defer ticker.Stop()
+		ticks = ticker.C
+	}
 
-	for range ticker.C {
+	for now := range ticks {
+		c.lastTick.Store(now.UnixNano())
 		c.mutex.Lock()
-		if c.Running {
+		c.throughput.record(now, c.instructionCount)
+		stepped, paused := false, false
+		for range c.tickSteps {
+			if !c.Running {
+				break
+			}
+			if c.breakpoints.atBreakpoint(c.Registers[PC], &c.Registers) {
+				c.halt(HaltBreakpoint)
+				c.captureBreakpoint(c.Registers[PC])
+				paused = true
+				break
+			}
 			c.step()
+			stepped = true
+		}
+		if stepped || paused {
 			c.notifyObservers()
 		}
+		delay := c.stepDelay
 		c.mutex.Unlock()
+
+		if stepped && delay > 0 {
+			c.sleep(delay)
+		}
 	}
 }
 
-// Step executes a single instruction.
+// SetStepDelay makes Run pause for d after every tick that executes
+// instructions, on top of the clock period, so each step is slow enough to
+// watch. Zero disables it.
+func (c *MonTanaMiniComputer) SetStepDelay(d time.Duration) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	c.stepDelay = d
+}
+
+// StepDelay returns the pause Run makes after every tick that executes
+// instructions.
+func (c *MonTanaMiniComputer) StepDelay() time.Duration {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	return c.stepDelay
+}
+
+// InstructionCount returns how many instructions have executed since the
+// current program was loaded.
+func (c *MonTanaMiniComputer) InstructionCount() uint64 {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	return c.instructionCount
+}
+
+// SetRunning starts or pauses the execution cycle, notifying observers if
+// the state changed.
+func (c *MonTanaMiniComputer) SetRunning(running bool) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	if c.Running == running {
+		return
+	}
+	c.Running = running
+	c.notifyObservers()
+}
+
+// IsRunning reports whether the execution cycle is running.
+func (c *MonTanaMiniComputer) IsRunning() bool {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	return c.Running
+}
+
+// Step executes a single instruction. What it changed is reported under
+// "delta" in the state until the next instruction executes.
 func (c *MonTanaMiniComputer) Step() {
 	c.mutex.Lock()
 	defer c.mutex.Unlock()
-	c.step()
+	c.stepDelta()
+	c.notifyObservers()
+}
+
+// SkipInstruction moves PC past the instruction at PC without executing it,
+// to bypass an instruction known to misbehave. The skip is recorded in the
+// step history, so StepBack undoes it.
+func (c *MonTanaMiniComputer) SkipInstruction() {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	c.history.record(c)
+	width := WordSize
+	if pc := c.Registers[PC]; c.wordInBounds(pc) {
+		width = Decode(c.fetchWord(pc)).Width()
+	}
+	c.Registers[PC] += uint16(width)
+	c.notifyObservers()
+}
+
+// StepState executes a single instruction and returns the resulting state,
+// as GetState would, without anything else running in between.
+func (c *MonTanaMiniComputer) StepState() map[string]interface{} {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	c.stepDelta()
 	c.notifyObservers()
+	return c.state()
 }
 
 // step executes a single instruction.
 func (c *MonTanaMiniComputer) step() {
+	c.history.record(c)
+	c.delta.last = nil
+
+	// Deferred first so that hooks run last and see the instruction's
+	// final effects; executed is set once an instruction is decoded.
+	var executed *Instruction
+	defer func() {
+		if executed != nil {
+			c.trace.record(c.executing, *executed)
+			for _, hook := range c.stepHooks {
+				hook(c, *executed)
+			}
+		}
+	}()
+
+	if c.zeroRegister >= 0 {
+		// Zero the register on the way in so the instruction reads 0, and
+		// on the way out to discard whatever it wrote.
+		c.Registers[c.zeroRegister] = 0
+		defer func() { c.Registers[c.zeroRegister] = 0 }()
+	}
+
 	pc := c.Registers[PC]
-	if pc >= MemorySize-1 {
-		log.Println("PC out of bounds, stopping execution.")
-		c.Running = false
+	// The whole instruction, including any operand words after the
+	// instruction word, must lie within memory.
+	if !c.wordInBounds(pc) {
+		c.fault(HaltPCOutOfBounds, pc, 0, true)
+		return
+	}
+	ins := DecodeAt(c.Memory[pc:])
+	width := ins.Width()
+	if int(pc)+width > len(c.Memory) {
+		c.fault(HaltPCOutOfBounds, pc, ins.Word, true)
 		return
 	}
 
-	instruction := binary.BigEndian.Uint16(c.Memory[pc:])
-	c.Registers[PC] += 2
+	executed = &ins
+	c.executing = pc
+	c.coverage.mark(pc, len(c.Memory))
+	c.Registers[PC] += uint16(width)
+	c.instructionCount++
+	c.metrics.Instructions++
+	c.opCounts[ins.Op]++
 
-	// Decode and execute the instruction based on the specification
-	opCode := (instruction & 0b1111000000000000) >> 12
-	regD := (instruction & 0b0000111100000000) >> 8
-	regS := (instruction & 0b0000000011110000) >> 4
-	regT := instruction & 0b0000000000001111
-	imm := int16(instruction & 0b0000000011111111)
+	regD, regS, regT, imm := ins.RegD, ins.RegS, ins.RegT, ins.Imm
+	switch ins.Op {
+	case OpNOP:
+		// The all-zero word does nothing, so running into zeroed
+		// (uninitialized) memory idles forward rather than faulting.
 
-	switch opCode {
 	// ALU Instructions
-	case 0b0001: // ADD
+	case OpADD:
 		c.Registers[regD] = c.Registers[regS] + c.Registers[regT]
-	case 0b0010: // SUB
+	case OpSUB:
 		c.Registers[regD] = c.Registers[regS] - c.Registers[regT]
-	case 0b0011: // AND
+	case OpAND:
 		c.Registers[regD] = c.Registers[regS] & c.Registers[regT]
-	case 0b0100: // OR
+	case OpOR:
 		c.Registers[regD] = c.Registers[regS] | c.Registers[regT]
-	case 0b0101: // XOR
+	case OpXOR:
 		c.Registers[regD] = c.Registers[regS] ^ c.Registers[regT]
-	case 0b0110: // SLL
+	case OpSLL:
 		c.Registers[regD] = c.Registers[regS] << c.Registers[regT]
-	case 0b0111: // SRL
+	case OpSRL:
 		c.Registers[regD] = c.Registers[regS] >> c.Registers[regT]
 
+	// Atomic Instructions
+	case OpCAS:
+		// Compare-and-swap: if the word at [regS] equals regT, store regD
+		// there and set the zero flag; otherwise leave memory alone and
+		// clear it. The read-compare-write happens within one step, so it
+		// is atomic with respect to everything else touching the machine.
+		addr := c.Registers[regS]
+		if !c.wordInBounds(addr) {
+			if c.fault(HaltMemoryFault, pc, ins.Word, false) {
+				return
+			}
+		} else if v, _ := c.readWord(addr); v == c.Registers[regT] {
+			if reason := c.writeWord(addr, c.Registers[regD]); reason != HaltNone {
+				if c.fault(reason, pc, ins.Word, false) {
+					return
+				}
+			} else {
+				c.Registers[SR] |= FlagZero
+			}
+		} else {
+			c.Registers[SR] &^= FlagZero
+		}
+
+	// Extended Instructions
+	case OpEXT:
+		switch ExtOp(regT) {
+		case ExtRDCNT:
+			c.Registers[regD] = uint16(c.instructionCount)
+		case ExtIN:
+			c.Registers[regD] = c.readInput()
+		case ExtOUT:
+			c.writeOutput(c.Registers[regD])
+		case ExtRAND:
+			c.Registers[regD] = c.rng.next()
+		case ExtBTST:
+			if c.Registers[regD]&(1<<regS) == 0 {
+				c.Registers[SR] |= FlagZero
+			} else {
+				c.Registers[SR] &^= FlagZero
+			}
+		case ExtBSET:
+			c.Registers[regD] |= 1 << regS
+		case ExtBCLR:
+			c.Registers[regD] &^= 1 << regS
+		case ExtADDO, ExtSUBO:
+			a, b := int32(int16(c.Registers[regD])), int32(int16(c.Registers[regS]))
+			if ExtOp(regT) == ExtSUBO {
+				b = -b
+			}
+			if sum := a + b; sum < math.MinInt16 || sum > math.MaxInt16 {
+				if c.fault(HaltOverflow, pc, ins.Word, false) {
+					return
+				}
+			} else {
+				c.Registers[regD] = uint16(sum)
+			}
+		case ExtLIW:
+			c.Registers[regD] = ins.Operand
+		case ExtLW2, ExtSW2:
+			if reason := c.moveDoubleWord(ExtOp(regT) == ExtSW2, regD, c.Registers[regS]); reason != HaltNone {
+				if c.fault(reason, pc, ins.Word, false) {
+					return
+				}
+			}
+		case ExtBRK:
+			c.halt(HaltSoftwareBreakpoint)
+		case ExtBSEEK:
+			if reason := c.seekBlock(c.Registers[regD]); reason != HaltNone {
+				if c.fault(reason, pc, ins.Word, false) {
+					return
+				}
+			}
+		case ExtBREAD, ExtBWRITE:
+			if reason := c.transferBlock(ExtOp(regT) == ExtBWRITE, c.Registers[regD]); reason != HaltNone {
+				if c.fault(reason, pc, ins.Word, false) {
+					return
+				}
+			}
+		default:
+			if c.fault(HaltUnknownInstruction, pc, ins.Word, false) {
+				return
+			}
+		}
+
 	// Immediate Instructions
-	case 0b1001: // ADDI
+	case OpADDI:
 		c.Registers[regD] = c.Registers[regS] + uint16(imm)
-	case 0b1010: // SUBI
+	case OpSUBI:
 		c.Registers[regD] = c.Registers[regS] - uint16(imm)
 
 	// Load/Store
-	case 0b1100: // LW
+	case OpLW:
 		addr := c.Registers[regS] + uint16(imm)
-		c.Registers[regD] = binary.BigEndian.Uint16(c.Memory[addr:])
-	case 0b1101: // SW
+		if v, ok := c.readWord(addr); ok {
+			c.Registers[regD] = v
+		} else if c.fault(HaltMemoryFault, pc, ins.Word, false) {
+			return
+		}
+	case OpSW:
 		addr := c.Registers[regS] + uint16(imm)
-		binary.BigEndian.PutUint16(c.Memory[addr:], c.Registers[regD])
+		if reason := c.writeWord(addr, c.Registers[regD]); reason != HaltNone && c.fault(reason, pc, ins.Word, false) {
+			return
+		}
 
 	// Branching
-	case 0b1110: // BZ
+	case OpBZ:
 		if c.Registers[regS] == 0 {
-			c.Registers[PC] += uint16(imm) * 2 // Branch is relative
+			c.Registers[PC] += uint16(imm) * WordSize // Branch is relative, in words
+		}
+	case OpHALT:
+		c.exitCode = c.Registers[R0]
+		c.halt(HaltInstruction)
+		if c.autoRestart && c.loaded.image.Segments != nil {
+			c.reload(c.loaded.image)
+			c.Running = true
 		}
-	case 0b1111: // HALT
-		c.Running = false
+		return
 
 	default:
-		log.Printf("Unknown instruction: 0x%04X\n", instruction)
-		c.Running = false
+		if c.fault(HaltUnknownInstruction, pc, ins.Word, false) {
+			return
+		}
 	}
 
 	// The status register would be updated here based on ALU results
+
+	c.checkWatchdog(pc, ins.Word)
 }
 
+// wordInBounds reports whether every byte of the word at addr lies in memory.
+func (c *MonTanaMiniComputer) wordInBounds(addr uint16) bool {
+	return int(addr)+WordSize <= len(c.Memory)
+}
+
+// fetchWord returns the word at addr, which must be in bounds, without
+// counting it as a data access. It is for instruction fetch and inspection.
+func (c *MonTanaMiniComputer) fetchWord(addr uint16) uint16 {
+	return binary.BigEndian.Uint16(c.Memory[addr:])
+}
+
+// readWord loads the big-endian word at addr on behalf of the running
+// program. It reports false, reading nothing, if the word is out of bounds.
+func (c *MonTanaMiniComputer) readWord(addr uint16) (uint16, bool) {
+	if !c.wordInBounds(addr) {
+		return 0, false
+	}
+	if port, ok := c.console.port(addr); ok {
+		return c.readConsole(port), true
+	}
+	c.access.countRead(addr)
+	return c.fetchWord(addr), true
+}
+
+// writeWord stores value as a big-endian word at addr on behalf of the
+// running program. If the word is out of bounds or read-only it writes
+// nothing and returns the reason; otherwise it returns HaltNone.
+func (c *MonTanaMiniComputer) writeWord(addr uint16, value uint16) HaltReason {
+	if !c.wordInBounds(addr) {
+		return HaltMemoryFault
+	}
+	if port, ok := c.console.port(addr); ok {
+		c.writeConsole(port, value)
+		return HaltNone
+	}
+	if c.isReadOnly(addr, addr+WordSize-1) {
+		return HaltROMWrite
+	}
+	if reason := c.checkSelfModifying(addr); reason != HaltNone {
+		return reason
+	}
+	c.access.countWrite(addr)
+	c.history.recordWrite(c.Memory, addr)
+	c.delta.recordWrite(c.Memory, addr)
+	binary.BigEndian.PutUint16(c.Memory[addr:], value)
+	return HaltNone
+}
+
+// moveDoubleWord implements LW2 (store false) and SW2 (store true), moving
+// the register pair hi:hi+1 from or to the two words at addr. It moves
+// nothing and returns the reason if either word cannot be accessed.
+func (c *MonTanaMiniComputer) moveDoubleWord(store bool, hi uint8, addr uint16) HaltReason {
+	lo := hi + 1
+	if int(lo) >= len(c.Registers) {
+		return HaltUnknownInstruction
+	}
+	next := addr + WordSize
+	if !c.wordInBounds(addr) || next < addr || !c.wordInBounds(next) {
+		return HaltMemoryFault
+	}
+	if store {
+		if c.isReadOnly(addr, next+WordSize-1) {
+			return HaltROMWrite
+		}
+		if c.selfModifyingFault(int(addr), int(next)+WordSize) {
+			return HaltSelfModifying
+		}
+		c.writeWord(addr, c.Registers[hi])
+		c.writeWord(next, c.Registers[lo])
+	} else {
+		c.Registers[hi], _ = c.readWord(addr)
+		c.Registers[lo], _ = c.readWord(next)
+	}
+	return HaltNone
+}
+
+// halt stops execution and records why.
+func (c *MonTanaMiniComputer) halt(reason HaltReason) {
+	c.Running = false
+	c.haltReason = reason
+}
+
+// ErrEmptyProgram is returned when asked to load a program with no bytes,
+// which usually means the wrong file was chosen.
+var ErrEmptyProgram = errors.New("program is empty")
+
 // LoadProgram loads a program into memory at a specific address.
-func (c *MonTanaMiniComputer) LoadProgram(program []byte, address uint16) {
+func (c *MonTanaMiniComputer) LoadProgram(program []byte, address uint16) error {
+	return c.LoadNamedProgram("", program, address, address)
+}
+
+// LoadProgramAt loads a program into memory at loadAddr and starts execution
+// at entryAddr, for programs whose entry point is not their first byte.
+func (c *MonTanaMiniComputer) LoadProgramAt(program []byte, loadAddr, entryAddr uint16) error {
+	return c.LoadNamedProgram("", program, loadAddr, entryAddr)
+}
+
+// LoadNamedProgram loads a program into memory at loadAddr, sets the PC to
+// entryAddr and remembers the program under name so it can later be reloaded.
+// It returns an error, loading nothing, if program is empty or does not fit
+// in memory at loadAddr. Since instructions are whole words, a program of
+// odd length is padded with a zero byte, and a warning logged, rather than
+// leaving its last instruction half made of whatever memory held.
+func (c *MonTanaMiniComputer) LoadNamedProgram(name string, program []byte, loadAddr, entryAddr uint16) error {
+	if len(program) == 0 {
+		return ErrEmptyProgram
+	}
+	if len(program)%WordSize != 0 {
+		c.logger.Warn("program length is not a whole number of words; padding it", "program", name, "bytes", len(program))
+		program = append(program[:len(program):len(program)], 0)
+	}
+	img := Image{
+		Segments: []Segment{{Address: loadAddr, Data: program}},
+		Entry:    entryAddr,
+	}
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	if err := c.checkImage(img); err != nil {
+		return err
+	}
+	c.load(name, img)
+	return nil
+}
+
+// LoadImage places each segment of img in memory, sets the PC to its entry
+// point and remembers it under name so it can later be reloaded. It returns
+// an error, leaving the machine untouched, if any segment or the entry point
+// lies outside memory.
+func (c *MonTanaMiniComputer) LoadImage(name string, img Image) error {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	if err := c.checkImage(img); err != nil {
+		return err
+	}
+	c.load(name, img)
+	return nil
+}
+
+// ReloadProgram clears memory, registers and runtime statistics, then loads
+// the last-loaded program again. If img is non-nil it replaces the remembered
+// image, so callers can supply a freshly re-read copy of the program.
+// Debugger configuration such as breakpoints is left untouched.
+func (c *MonTanaMiniComputer) ReloadProgram(img *Image) error {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	if img == nil {
+		if c.loaded.image.Segments == nil {
+			return errors.New("no program has been loaded")
+		}
+		img = &c.loaded.image
+	}
+	c.reload(*img)
+	c.notifyObservers()
+	return nil
+}
+
+// reload resets the machine and loads img under the current program name.
+// The exit code of the previous run is kept so it can still be reported, and
+// the annotations since they describe the program rather than the run.
+func (c *MonTanaMiniComputer) reload(img Image) {
+	name, data, exitCode, annotations := c.loaded.name, c.loaded.data, c.exitCode, c.annotations
+	c.reset()
+	c.load(name, img)
+	for _, seg := range data {
+		c.loadData(seg)
+	}
+	c.exitCode, c.annotations = exitCode, annotations
+}
+
+// Restart runs the loaded program again from its entry point without
+// reloading it: memory is left as it is, the registers are cleared, SP and
+// FP are reinitialized and PC is set to the entry point. Output from the
+// previous run is discarded, but input waiting to be read is kept. With
+// auto-start on the program starts running at once, as after a load. Unlike
+// ReloadProgram it keeps anything the program wrote to memory, so it suits
+// programs that do not rely on their data being pristine.
+func (c *MonTanaMiniComputer) Restart() error {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	if c.loaded.image.Segments == nil {
+		return errors.New("no program has been loaded")
+	}
+	c.Registers = [16]uint16{}
+	c.initStack()
+	c.Registers[PC] = c.loaded.image.Entry
+	c.Running = c.autoStart
+	c.haltReason = HaltNone
+	c.lastFault = nil
+	c.history.clear()
+	c.trace.clear()
+	c.watchdog.reset()
+	c.instructionCount = 0
+	c.delta.last = nil
+	c.output = nil
+	c.console.screen = nil
+	c.exitCode = 0
+	c.notifyObservers()
+	return nil
+}
+
+// LoadData copies data into memory at addr. Unlike loading a program it
+// leaves the registers, including PC, alone, so input for a program can be
+// placed alongside it. Reloading the program places the data again; Reset
+// forgets it. It returns an error, writing nothing, if data does not fit in
+// memory at addr.
+func (c *MonTanaMiniComputer) LoadData(data []byte, addr uint16) error {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	seg := Segment{Address: addr, Data: append([]byte(nil), data...)}
+	if err := c.checkSegment(seg); err != nil {
+		return err
+	}
+	c.loadData(seg)
+	c.notifyObservers()
+	return nil
+}
+
+// loadData places seg in memory and remembers it for reloads.
+func (c *MonTanaMiniComputer) loadData(seg Segment) {
+	if int(seg.Address) < len(c.Memory) {
+		copy(c.Memory[seg.Address:], seg.Data)
+	}
+	c.loaded.data = append(c.loaded.data, seg)
+}
+
+// SetAutoRestart chooses what happens when the program executes HALT. By
+// default the machine stops and reports the exit code; with auto-restart on
+// it instead reloads the program and keeps running, for unattended demos.
+func (c *MonTanaMiniComputer) SetAutoRestart(enabled bool) {
 	c.mutex.Lock()
 	defer c.mutex.Unlock()
-	copy(c.Memory[address:], program)
-	c.Registers[PC] = address
+	c.autoRestart = enabled
 }
 
-// GetState returns a snapshot of the computer's state.
+// ExitCode returns the value R0 held when the program last executed HALT;
+// by convention programs leave their exit status there.
+func (c *MonTanaMiniComputer) ExitCode() uint16 {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	return c.exitCode
+}
+
+// ProgramName returns the name of the last-loaded program, if it had one.
+func (c *MonTanaMiniComputer) ProgramName() string {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	return c.loaded.name
+}
+
+// EntryPoint returns the entry point of the last-loaded program.
+func (c *MonTanaMiniComputer) EntryPoint() uint16 {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	return c.loaded.image.Entry
+}
+
+// checkImage verifies that every byte of img fits in memory.
+func (c *MonTanaMiniComputer) checkImage(img Image) error {
+	if !slices.ContainsFunc(img.Segments, func(seg Segment) bool { return len(seg.Data) > 0 }) {
+		return ErrEmptyProgram
+	}
+	for _, seg := range img.Segments {
+		if err := c.checkSegment(seg); err != nil {
+			return err
+		}
+	}
+	if int(img.Entry) >= len(c.Memory) {
+		return fmt.Errorf("entry point 0x%04X is outside memory", img.Entry)
+	}
+	return nil
+}
+
+// checkSegment verifies that every byte of seg fits in memory.
+func (c *MonTanaMiniComputer) checkSegment(seg Segment) error {
+	if end := int(seg.Address) + len(seg.Data); end > len(c.Memory) {
+		available := max(len(c.Memory)-int(seg.Address), 0)
+		return fmt.Errorf("segment at 0x%04X (%d bytes) does not fit in memory: only %d bytes are available from there (memory is %d bytes)",
+			seg.Address, len(seg.Data), available, len(c.Memory))
+	}
+	return nil
+}
+
+// load places img in memory, silently dropping any bytes that fall past the
+// end of memory; callers wanting that reported use checkImage first.
+func (c *MonTanaMiniComputer) load(name string, img Image) {
+	segments := make([]Segment, len(img.Segments))
+	for i, seg := range img.Segments {
+		if int(seg.Address) < len(c.Memory) {
+			copy(c.Memory[seg.Address:], seg.Data)
+		}
+		segments[i] = Segment{Address: seg.Address, Data: append([]byte(nil), seg.Data...)}
+	}
+	c.Registers[PC] = img.Entry
+	c.metrics.ProgramsLoaded++
+	c.haltReason = HaltNone
+	c.lastFault = nil
+	c.history.clear()
+	c.trace.clear()
+	c.watchdog.reset()
+	c.instructionCount = 0
+	c.coverage.reset()
+	c.selfModifying.writes = nil
+	if c.autoStart {
+		c.Running = true
+	}
+	c.loaded = loadedProgram{name: name, image: Image{Segments: segments, Entry: img.Entry, Source: maps.Clone(img.Source), Labels: maps.Clone(img.Labels), Code: slices.Clone(img.Code)}, data: c.loaded.data}
+}
+
+// Reset stops the machine and returns it to its power-on state, clearing
+// memory, registers, the loaded program and any collected statistics.
+func (c *MonTanaMiniComputer) Reset() {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	c.reset()
+	c.notifyObservers()
+}
+
+func (c *MonTanaMiniComputer) reset() {
+	c.fillMemory()
+	c.Registers = [16]uint16{}
+	c.initStack()
+	c.Running = false
+	c.haltReason = HaltNone
+	c.lastFault = nil
+	c.history.clear()
+	c.trace.clear()
+	c.watchdog.reset()
+	c.access.reset()
+	c.opCounts = [16]uint64{}
+	c.instructionCount = 0
+	c.coverage.reset()
+	c.delta.last = nil
+	c.loaded = loadedProgram{}
+	c.readOnly = nil
+	c.input = nil
+	c.output = nil
+	c.console.screen = nil
+	c.blocks.block = 0
+	c.annotations = nil
+	c.selfModifying.writes = nil
+	c.exitCode = 0
+	c.rng.reseed(c.rng.seed)
+}
+
+// MemorySize returns how many bytes of memory the machine has, which is
+// MemorySize unless WithMemorySize set it.
+func (c *MonTanaMiniComputer) MemorySize() int {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	return len(c.Memory)
+}
+
+// DumpMemory returns a copy of length bytes of memory starting at start,
+// clipped to the end of memory, along with the PC at the moment of the copy.
+func (c *MonTanaMiniComputer) DumpMemory(start, length int) ([]byte, uint16) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	start = min(max(start, 0), len(c.Memory))
+	end := min(start+max(length, 0), len(c.Memory))
+	return append([]byte(nil), c.Memory[start:end]...), c.Registers[PC]
+}
+
+// ReadMemory returns a copy of length bytes of memory starting at addr. It
+// fails, rather than clipping as DumpMemory does, if the range extends past
+// the end of memory.
+func (c *MonTanaMiniComputer) ReadMemory(addr uint16, length int) ([]byte, error) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	if err := c.checkRange(addr, length); err != nil {
+		return nil, err
+	}
+	return append([]byte(nil), c.Memory[int(addr):int(addr)+length]...), nil
+}
+
+// WriteMemory copies data into memory at addr and notifies observers. It is
+// the way for code outside the machine, such as devices and the server, to
+// change memory while it may be running; read-only regions only restrict the
+// program, so they do not apply. It fails without writing anything if data
+// extends past the end of memory.
+func (c *MonTanaMiniComputer) WriteMemory(addr uint16, data []byte) error {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	if err := c.checkRange(addr, len(data)); err != nil {
+		return err
+	}
+	copy(c.Memory[addr:], data)
+	c.notifyObservers()
+	return nil
+}
+
+// checkRange reports an error unless length bytes from addr lie in memory.
+func (c *MonTanaMiniComputer) checkRange(addr uint16, length int) error {
+	if length < 0 || int(addr)+length > len(c.Memory) {
+		return fmt.Errorf("%d bytes at 0x%04X extend past end of memory (%d bytes)", length, addr, len(c.Memory))
+	}
+	return nil
+}
+
+// StackWord is one word of the stack as reported in the state.
+type StackWord struct {
+	Address uint16 `json:"address"`
+	Value   uint16 `json:"value"`
+}
+
+// stateStackWords caps how many stack words, from SP up, the state includes.
+const stateStackWords = 64
+
+// stack returns the words from SP to the top of memory, SP first, or none if
+// SP does not point into memory.
+func (c *MonTanaMiniComputer) stack() []StackWord {
+	words := []StackWord{}
+	for addr := int(c.Registers[SP]); addr+WordSize <= len(c.Memory) && len(words) < stateStackWords; addr += WordSize {
+		words = append(words, StackWord{Address: uint16(addr), Value: c.fetchWord(uint16(addr))})
+	}
+	return words
+}
+
+// stateMemoryWindow is how many bytes of memory, from address 0, GetState
+// includes for display.
+const stateMemoryWindow = 256
+
+// GetState returns a snapshot of the computer's state. Nothing in the
+// returned map aliases the live machine, so it is safe to use after the
+// machine has moved on.
 func (c *MonTanaMiniComputer) GetState() map[string]interface{} {
 	c.mutex.Lock()
 	defer c.mutex.Unlock()
+	return c.state()
+}
 
+func (c *MonTanaMiniComputer) state() map[string]interface{} {
 	// Create a map for named registers for easier display
-	namedRegisters := map[string]uint16{
-		"R0": c.Registers[R0], "R1": c.Registers[R1], "R2": c.Registers[R2], "R3": c.Registers[R3],
-		"R4": c.Registers[R4], "R5": c.Registers[R5], "R6": c.Registers[R6], "R7": c.Registers[R7],
-		"GP": c.Registers[GP], "FP": c.Registers[FP], "SP": c.Registers[SP], "RA": c.Registers[RA],
-		"HI": c.Registers[HI], "LO": c.Registers[LO], "PC": c.Registers[PC], "SR": c.Registers[SR],
+	namedRegisters := make(map[string]uint16, len(RegisterNames))
+	for i, name := range c.registerNames() {
+		namedRegisters[name] = c.Registers[i]
 	}
 
+	// Copy the memory window so consumers such as the JSON encoder never
+	// read the backing array while step() is writing it. Registers is an
+	// array, so it is copied by value already.
+	memory := make([]byte, min(stateMemoryWindow, len(c.Memory)))
+	copy(memory, c.Memory)
+
 	return map[string]interface{}{
-		"registers":      c.Registers,
-		"namedRegisters": namedRegisters,
-		"running":        c.Running,
-		"memory":         c.Memory[:256], // Send a portion of memory for display
+		"registers":        c.Registers,
+		"namedRegisters":   namedRegisters,
+		"registerFile":     c.registerFile(),
+		"pc":               c.Registers[PC],
+		"aliases":          c.aliasMap(),
+		"flags":            DecodeFlags(c.Registers[SR]),
+		"running":          c.Running,
+		"program":          c.loaded.name,
+		"output":           string(c.output),
+		"console":          string(c.console.screen),
+		"haltReason":       c.haltReason,
+		"exitCode":         c.exitCode,
+		"autoRestart":      c.autoRestart,
+		"autoStart":        c.autoStart,
+		"breakpoints":      c.breakpoints.list(),
+		"annotations":      c.allAnnotations(),
+		"selfModifying":    c.selfModifying.list(),
+		"conditions":       c.breakpoints.conditions(),
+		"instructionCount": c.instructionCount,
+		"ips":              c.throughput.ips,
+		"instruction":      c.nextInstruction(),
+		"fault":            c.lastFault,
+		"delta":            c.delta.last,
+		"stack":            c.stack(),
+		"memory":           memory, // Send a portion of memory for display
 	}
 }
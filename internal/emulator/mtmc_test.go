@@ -0,0 +1,1290 @@
+package emulator
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"slices"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestLoadSizeLimit(t *testing.T) {
+	loaders := []struct {
+		name string
+		load func(c *MonTanaMiniComputer, data []byte, addr uint16) error
+	}{
+		{"LoadProgram", func(c *MonTanaMiniComputer, data []byte, addr uint16) error {
+			return c.LoadProgram(data, addr)
+		}},
+		{"LoadImage", func(c *MonTanaMiniComputer, data []byte, addr uint16) error {
+			return c.LoadImage("", Image{Segments: []Segment{{Address: addr, Data: data}}, Entry: addr})
+		}},
+		{"LoadData", func(c *MonTanaMiniComputer, data []byte, addr uint16) error {
+			return c.LoadData(data, addr)
+		}},
+	}
+	tests := []struct {
+		name string
+		addr uint16
+		size int
+		fits bool
+	}{
+		{"all of memory", 0, testMemorySize, true},
+		{"one byte over", 0, testMemorySize + 2, false},
+		{"up to the end", 0x800, testMemorySize - 0x800, true},
+		{"past the end", 0x800, testMemorySize - 0x800 + 2, false},
+		{"last word", testMemorySize - 2, 2, true},
+	}
+	for _, l := range loaders {
+		for _, tt := range tests {
+			t.Run(l.name+"/"+tt.name, func(t *testing.T) {
+				c := newTestComputer(t)
+				data := make([]byte, tt.size)
+				for i := range data {
+					data[i] = 0xAA
+				}
+				err := l.load(c, data, tt.addr)
+				if tt.fits {
+					if err != nil {
+						t.Fatalf("load failed: %v", err)
+					}
+					if end := int(tt.addr) + tt.size; c.Memory[end-1] != 0xAA {
+						t.Error("last byte not loaded")
+					}
+					return
+				}
+				if err == nil {
+					t.Fatal("oversize load succeeded")
+				}
+				available := fmt.Sprintf("only %d bytes are available", testMemorySize-int(tt.addr))
+				if !strings.Contains(err.Error(), available) {
+					t.Errorf("error %q does not say %q", err, available)
+				}
+				for _, b := range c.Memory {
+					if b == 0xAA {
+						t.Fatal("rejected load wrote to memory")
+					}
+				}
+			})
+		}
+	}
+}
+
+// TestMachinesIndependent checks that the clock, run state and breakpoints
+// of one machine, such as one user's, leave another's alone.
+func TestMachinesIndependent(t *testing.T) {
+	code := program(t, nop, nop, ri(OpBZ, 0, -1)) // loops forever
+	type machine struct {
+		c     *MonTanaMiniComputer
+		ticks chan time.Time
+		done  chan struct{}
+	}
+	machines := make([]machine, 2)
+	for i := range machines {
+		m := machine{ticks: make(chan time.Time), done: make(chan struct{})}
+		m.c = newTestComputer(t, WithClock(m.ticks))
+		load(t, m.c, code)
+		go func() {
+			m.c.Run()
+			close(m.done)
+		}()
+		machines[i] = m
+	}
+	a, b := machines[0].c, machines[1].c
+
+	a.SetRunning(true)
+	a.SetStepsPerTick(3)
+	a.SetStepDelay(time.Nanosecond)
+	if err := a.AddBreakpoint(0x100); err != nil {
+		t.Fatal(err)
+	}
+	for _, m := range machines {
+		for range 4 {
+			m.ticks <- time.Now()
+		}
+		close(m.ticks)
+		<-m.done
+	}
+
+	if got := a.InstructionCount(); got != 12 {
+		t.Errorf("running machine executed %d instructions, want 12", got)
+	}
+	if b.IsRunning() || b.InstructionCount() != 0 {
+		t.Errorf("other machine running %v after %d instructions, want stopped before any", b.IsRunning(), b.InstructionCount())
+	}
+	if b.StepsPerTick() != 1 || b.StepDelay() != 0 || len(b.Breakpoints()) != 0 {
+		t.Errorf("other machine has %d steps per tick, delay %v, breakpoints %v; want the defaults",
+			b.StepsPerTick(), b.StepDelay(), b.Breakpoints())
+	}
+}
+
+func TestReloadProgram(t *testing.T) {
+	code := program(t, liw(R1, 0x1234), ri(OpSW, R1, 0), halt)
+	c := newTestComputer(t)
+	if err := c.LoadProgramAt(code, 0x10, 0x10); err != nil {
+		t.Fatal(err)
+	}
+	if err := c.LoadData([]byte{0xAB, 0xCD}, 0x40); err != nil {
+		t.Fatal(err)
+	}
+	for _, addr := range []uint16{0x14, 0x30} {
+		if err := c.AddBreakpoint(addr); err != nil {
+			t.Fatal(err)
+		}
+	}
+	stepN(c, 2)
+	c.Memory[0x10] = 0xFF // clobber the program
+	c.Memory[0x40] = 0    // and its data
+
+	if err := c.ReloadProgram(nil); err != nil {
+		t.Fatal(err)
+	}
+	if got := c.Breakpoints(); !slices.Equal(got, []uint16{0x14, 0x30}) {
+		t.Errorf("breakpoints after reload = %v, want [0x14 0x30]", got)
+	}
+	if c.Registers[R1] != 0 || c.Registers[PC] != 0x10 || c.Registers[SP] != testMemorySize-WordSize {
+		t.Errorf("registers after reload: R1 = 0x%04X, PC = 0x%04X, SP = 0x%04X", c.Registers[R1], c.Registers[PC], c.Registers[SP])
+	}
+	if !bytes.Equal(c.Memory[0x10:0x10+len(code)], code) || c.Memory[0x0] != 0 {
+		t.Error("program not restored in memory")
+	}
+	if !bytes.Equal(c.Memory[0x40:0x42], []byte{0xAB, 0xCD}) {
+		t.Error("data not placed again")
+	}
+	if c.InstructionCount() != 0 {
+		t.Errorf("instruction count after reload = %d, want 0", c.InstructionCount())
+	}
+
+	// A replacement image is loaded instead.
+	replacement := Image{Segments: []Segment{{Address: 0x20, Data: program(t, halt)}}, Entry: 0x20}
+	if err := c.ReloadProgram(&replacement); err != nil {
+		t.Fatal(err)
+	}
+	if c.Registers[PC] != 0x20 || c.Memory[0x10] != 0 {
+		t.Errorf("after reloading a new image PC = 0x%04X and old program byte = 0x%02X", c.Registers[PC], c.Memory[0x10])
+	}
+
+	if err := newTestComputer(t).ReloadProgram(nil); err == nil {
+		t.Error("reload with no program loaded succeeded")
+	}
+}
+
+func TestCompareAndSwap(t *testing.T) {
+	const addr = 0x100
+	tests := []struct {
+		name     string
+		memory   uint16 // the word at addr beforehand
+		addr     uint16
+		wantMem  uint16
+		wantZero bool
+		wantHalt HaltReason
+	}{
+		{"swaps when equal", 7, addr, 9, true, HaltInstruction},
+		{"leaves memory when different", 8, addr, 8, false, HaltInstruction},
+		{"faults outside memory", 7, testMemorySize - 1, 7, false, HaltMemoryFault},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := newTestComputer(t)
+			load(t, c, program(t,
+				liw(R1, tt.addr),
+				liw(R2, 7), // expected
+				liw(R3, 9), // new value
+				rrr(OpCAS, R3, R1, R2),
+				halt,
+			))
+			c.Memory[addr], c.Memory[addr+1] = byte(tt.memory>>8), byte(tt.memory)
+			// The flag must be cleared on failure, not just left alone.
+			c.Registers[SR] = FlagZero | FlagCarry
+
+			result := c.RunToCompletion()
+			if result.HaltReason != tt.wantHalt {
+				t.Fatalf("halt reason %q, want %q", result.HaltReason, tt.wantHalt)
+			}
+			if got := uint16(c.Memory[addr])<<8 | uint16(c.Memory[addr+1]); got != tt.wantMem {
+				t.Errorf("memory = %d, want %d", got, tt.wantMem)
+			}
+			if tt.wantHalt != HaltInstruction {
+				return
+			}
+			if zero := c.Registers[SR]&FlagZero != 0; zero != tt.wantZero {
+				t.Errorf("zero flag = %v, want %v", zero, tt.wantZero)
+			}
+			if c.Registers[SR]&FlagCarry == 0 {
+				t.Error("CAS cleared another flag")
+			}
+		})
+	}
+}
+
+func TestFetchAtTopOfMemory(t *testing.T) {
+	const top = testMemorySize - WordSize
+	tests := []struct {
+		name string
+		pc   uint16
+		code []byte // placed at top
+		halt HaltReason
+	}{
+		{"last word executes", top, program(t, halt), HaltInstruction},
+		{"last word idles past", top, program(t, nop), HaltPCOutOfBounds},
+		{"half a word", testMemorySize - 1, program(t, halt), HaltPCOutOfBounds},
+		{"past the end", 0xFFFF, program(t, halt), HaltPCOutOfBounds},
+		{"operand past the end", top, program(t, liw(R1, 0))[:WordSize], HaltPCOutOfBounds},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := newTestComputer(t)
+			if err := c.LoadProgramAt(tt.code, top, top); err != nil {
+				t.Fatal(err)
+			}
+			c.Registers[PC] = tt.pc
+			result := c.RunToCompletion()
+			if result.HaltReason != tt.halt {
+				t.Fatalf("halt reason %q, want %q", result.HaltReason, tt.halt)
+			}
+			if tt.halt == HaltPCOutOfBounds && result.Fault == nil {
+				t.Error("no fault recorded")
+			}
+		})
+	}
+}
+
+// TestInstructionWidth checks that PC advances by each instruction's width
+// from the table, including a hypothetical two-word form of RDCNT.
+func TestInstructionWidth(t *testing.T) {
+	tests := []struct {
+		name  string
+		ins   Instruction
+		width int // patched into the table, if not zero
+		pc    uint16
+	}{
+		{"one word", ri(OpADDI, R1, 5), 0, 2},
+		{"one word extended", ext(ExtRDCNT, R1, 0), 0, 2},
+		{"two words", liw(R1, 0xBEEF), 0, 4},
+		{"hypothetical two words", ext(ExtRDCNT, R1, 0), 2 * WordSize, 4},
+		{"hypothetical three words", ext(ExtRDCNT, R1, 0), 3 * WordSize, 6},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if tt.width != 0 {
+				saved := extOps[ExtRDCNT]
+				extOps[ExtRDCNT].Width = tt.width
+				t.Cleanup(func() { extOps[ExtRDCNT] = saved })
+			}
+			c := newTestComputer(t)
+			// Operand words of the hypothetical forms are HALTs, which
+			// would stop the machine if PC landed on them.
+			load(t, c, append(program(t, tt.ins), program(t, halt, halt, halt)...))
+			stepN(c, 1)
+			if c.Registers[PC] != tt.pc || c.haltReason != HaltNone {
+				t.Errorf("after one step, PC %d, halt reason %q; want PC %d, not halted", c.Registers[PC], c.haltReason, tt.pc)
+			}
+		})
+	}
+}
+
+func TestLIW(t *testing.T) {
+	tests := []struct {
+		name  string
+		reg   int
+		value uint16
+	}{
+		{"full word", R1, 0xBEEF},
+		{"zero", R2, 0},
+		{"all ones", R7, 0xFFFF},
+		{"high register", SP, 0x0800},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := newTestComputer(t)
+			load(t, c, program(t, liw(tt.reg, tt.value), halt))
+			want := c.Registers
+			want[tt.reg], want[PC] = tt.value, 4
+			stepN(c, 1)
+			if c.Registers != want || c.InstructionCount() != 1 {
+				t.Errorf("after one LIW, registers %v, count %d; want %v, 1", c.Registers, c.InstructionCount(), want)
+			}
+		})
+	}
+}
+
+func TestLoadEmptyAndOddPrograms(t *testing.T) {
+	tests := []struct {
+		name   string
+		load   func(c *MonTanaMiniComputer) error
+		err    error
+		memory []byte // at 0x100 afterwards, when loading succeeds
+		warns  bool
+	}{
+		{"empty", func(c *MonTanaMiniComputer) error { return c.LoadProgram(nil, 0x100) }, ErrEmptyProgram, nil, false},
+		{"empty image", func(c *MonTanaMiniComputer) error {
+			return c.LoadImage("", Image{})
+		}, ErrEmptyProgram, nil, false},
+		{"image of empty segments", func(c *MonTanaMiniComputer) error {
+			return c.LoadImage("", Image{Segments: []Segment{{Address: 0x100}, {Address: 0x200, Data: []byte{}}}})
+		}, ErrEmptyProgram, nil, false},
+		{"odd length padded", func(c *MonTanaMiniComputer) error {
+			return c.LoadProgram([]byte{0x00, 0x00, 0xF0}, 0x100)
+		}, nil, []byte{0x00, 0x00, 0xF0, 0x00, 0x55}, true},
+		{"even length", func(c *MonTanaMiniComputer) error {
+			return c.LoadProgram([]byte{0x00, 0x00, 0xF0, 0x00}, 0x100)
+		}, nil, []byte{0x00, 0x00, 0xF0, 0x00, 0x55}, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var logs bytes.Buffer
+			c := newTestComputer(t, WithMemoryFill(0x5555), WithLogger(slog.New(slog.NewTextHandler(&logs, nil))))
+			err := tt.load(c)
+			if !errors.Is(err, tt.err) {
+				t.Fatalf("load error = %v, want %v", err, tt.err)
+			}
+			if warned := strings.Contains(logs.String(), "level=WARN"); warned != tt.warns {
+				t.Errorf("warned %v, want %v: %s", warned, tt.warns, logs.String())
+			}
+			if tt.err != nil {
+				if c.GetState()["program"] != "" || !bytes.Equal(c.Memory[:4], []byte{0x55, 0x55, 0x55, 0x55}) {
+					t.Error("rejected program was loaded")
+				}
+				return
+			}
+			if got := c.Memory[0x100 : 0x100+len(tt.memory)]; !bytes.Equal(got, tt.memory) {
+				t.Errorf("memory = % X, want % X", got, tt.memory)
+			}
+			if r := c.RunToCompletion(); r.HaltReason != HaltInstruction || r.Steps != 2 {
+				t.Errorf("ran %d steps to %q, want 2 to %q", r.Steps, r.HaltReason, HaltInstruction)
+			}
+		})
+	}
+
+	// Padding does not write past the caller's slice.
+	code := []byte{0x00, 0x00, 0xF0, 0xAA}
+	c := newTestComputer(t)
+	if err := c.LoadProgram(code[:3], 0); err != nil {
+		t.Fatal(err)
+	}
+	if code[3] != 0xAA {
+		t.Errorf("caller's byte after the program = 0x%02X, want it unchanged", code[3])
+	}
+}
+
+func TestLoadEntryPoint(t *testing.T) {
+	code := program(t, nop, nop, halt)
+	tests := []struct {
+		name        string
+		load, entry uint16
+	}{
+		{"at load address", 0x100, 0x100},
+		{"after load address", 0x100, 0x104},
+		{"before load address", 0x100, 0x20},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := newTestComputer(t)
+			if err := c.LoadProgramAt(code, tt.load, tt.entry); err != nil {
+				t.Fatal(err)
+			}
+			if c.Registers[PC] != tt.entry || c.EntryPoint() != tt.entry {
+				t.Errorf("PC = 0x%04X, EntryPoint = 0x%04X; want 0x%04X", c.Registers[PC], c.EntryPoint(), tt.entry)
+			}
+			if !bytes.Equal(c.Memory[tt.load:int(tt.load)+len(code)], code) {
+				t.Error("program not at its load address")
+			}
+		})
+	}
+
+	c := newTestComputer(t)
+	if err := c.LoadProgramAt(code, 0, testMemorySize); err == nil {
+		t.Error("entry point outside memory accepted")
+	}
+}
+
+// counterLoop is a program that counts in R1 forever, storing each count at
+// 0x40, within the memory GetState reports.
+func counterLoop(t *testing.T) []byte {
+	return program(t,
+		liw(R2, 1),
+		rrr(OpADD, R1, R1, R2),
+		ri(OpSW, R1, 0x40), // R4 is 0, so this stores at 0x40
+		ri(OpBZ, 0, -3),    // back to the ADD; SR is clear, so always taken
+	)
+}
+
+// runClocked runs c with a clock that ticks as fast as Run accepts ticks
+// until the returned function is called, which waits for Run to return.
+func runClocked(c *MonTanaMiniComputer, ticks chan time.Time) (stop func()) {
+	done := make(chan struct{})
+	go func() {
+		c.Run()
+		close(done)
+	}()
+	quit := make(chan struct{})
+	go func() {
+		defer close(ticks)
+		for {
+			select {
+			case <-quit:
+				return
+			case ticks <- time.Now():
+			}
+		}
+	}()
+	return func() {
+		close(quit)
+		<-done
+	}
+}
+
+// TestClock drives Run one tick at a time and checks that each tick
+// executes one instruction while the machine runs, and none otherwise.
+func TestClock(t *testing.T) {
+	loop := program(t, ri(OpBZ, 0, -1)) // branches to itself forever
+	tests := []struct {
+		name    string
+		code    []byte
+		running bool
+		ticks   int
+		want    uint64
+	}{
+		{"no ticks", loop, true, 0, 0},
+		{"one tick", loop, true, 1, 1},
+		{"many ticks", loop, true, 100, 100},
+		{"paused", loop, false, 10, 0},
+		{"halts part way", program(t, nop, nop, nop, halt), true, 10, 4},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ticks := make(chan time.Time)
+			c := newTestComputer(t, WithClock(ticks))
+			load(t, c, tt.code)
+			c.SetRunning(tt.running)
+			done := make(chan struct{})
+			go func() {
+				c.Run()
+				close(done)
+			}()
+			for range tt.ticks {
+				ticks <- time.Now()
+			}
+			// Run returns once the clock stops, having handled every tick.
+			close(ticks)
+			<-done
+			if got := c.InstructionCount(); got != tt.want {
+				t.Errorf("%d ticks executed %d instructions, want %d", tt.ticks, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestStepsPerTick(t *testing.T) {
+	loop := program(t, ri(OpBZ, 0, -1)) // branches to itself forever
+	tests := []struct {
+		name       string
+		code       []byte
+		steps      int
+		breakpoint uint16 // 0 for none
+		ticks      int
+		want       uint64
+		notifies   int // once per tick that did anything
+	}{
+		{"default", loop, 1, 0, 5, 5, 5},
+		{"boosted", loop, 4, 0, 5, 20, 5},
+		{"below one", loop, 0, 0, 5, 5, 5},
+		{"halts part way through a tick", program(t, nop, nop, nop, halt), 3, 0, 10, 4, 2},
+		{"breakpoint part way through a tick", program(t, nop, nop, nop, halt), 10, 4, 3, 2, 1},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ticks := make(chan time.Time)
+			c := newTestComputer(t, WithClock(ticks), WithStepsPerTick(tt.steps))
+			load(t, c, tt.code)
+			if tt.breakpoint != 0 {
+				if err := c.AddBreakpoint(tt.breakpoint); err != nil {
+					t.Fatal(err)
+				}
+			}
+			var notifies int
+			c.AddObserver(stateObserverFunc(func(map[string]interface{}) { notifies++ }))
+			c.SetRunning(true)
+			notifies = 0
+			done := make(chan struct{})
+			go func() {
+				c.Run()
+				close(done)
+			}()
+			for range tt.ticks {
+				ticks <- time.Now()
+			}
+			close(ticks)
+			<-done
+			if got := c.InstructionCount(); got != tt.want {
+				t.Errorf("%d ticks of %d steps executed %d instructions, want %d", tt.ticks, tt.steps, got, tt.want)
+			}
+			if notifies != tt.notifies {
+				t.Errorf("observers notified %d times, want %d", notifies, tt.notifies)
+			}
+		})
+	}
+}
+
+func TestSetStepsPerTick(t *testing.T) {
+	c := newTestComputer(t)
+	if got := c.StepsPerTick(); got != 1 {
+		t.Errorf("default StepsPerTick() = %d, want 1", got)
+	}
+	for _, tt := range []struct{ set, want int }{{8, 8}, {1, 1}, {0, 1}, {-3, 1}} {
+		c.SetStepsPerTick(tt.set)
+		if got := c.StepsPerTick(); got != tt.want {
+			t.Errorf("after SetStepsPerTick(%d), StepsPerTick() = %d, want %d", tt.set, got, tt.want)
+		}
+	}
+}
+
+// TestGetStateConcurrentWithRun reads the state while the machine runs.
+// Run it with -race.
+func TestGetStateConcurrentWithRun(t *testing.T) {
+	ticks := make(chan time.Time)
+	c := newTestComputer(t, WithClock(ticks))
+	load(t, c, counterLoop(t))
+	c.SetRunning(true)
+	stop := runClocked(c, ticks)
+
+	// Keep reading until the machine has made some progress too.
+	deadline := time.Now().Add(5 * time.Second)
+	for i := 0; i < 200 || c.InstructionCount() < 100; i++ {
+		if time.Now().After(deadline) {
+			t.Fatal("machine did not run")
+		}
+		state := c.GetState()
+		if _, err := json.Marshal(state); err != nil {
+			t.Fatal(err)
+		}
+		// The state's memory is a copy, so changing it leaves the machine
+		// alone.
+		state["memory"].([]byte)[0] ^= 0xFF
+	}
+	stop()
+
+	if c.Memory[0] != program(t, liw(R2, 1))[0] {
+		t.Error("changing the state's memory changed the machine's")
+	}
+}
+
+// TestSetRunningConcurrentWithRun starts and pauses the machine while Run
+// executes. Run it with -race.
+func TestSetRunningConcurrentWithRun(t *testing.T) {
+	ticks := make(chan time.Time)
+	c := newTestComputer(t, WithClock(ticks))
+	load(t, c, counterLoop(t))
+	var changes atomic.Int64
+	c.AddObserver(observerFunc(func(*MonTanaMiniComputer) { changes.Add(1) }))
+	stop := runClocked(c, ticks)
+
+	var wg sync.WaitGroup
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		for i := range 100 {
+			c.SetRunning(i%2 == 0)
+		}
+	}()
+	for range 100 {
+		c.IsRunning()
+	}
+	wg.Wait()
+	stop()
+
+	if c.IsRunning() {
+		t.Error("machine running after the last SetRunning(false)")
+	}
+	// Run notifies observers too, so there may be more.
+	if changes.Load() < 100 {
+		t.Errorf("observers notified %d times, want at least once per change", changes.Load())
+	}
+
+	// Setting the state it is already in changes nothing.
+	before := changes.Load()
+	c.SetRunning(false)
+	if changes.Load() != before {
+		t.Error("observers notified without a change")
+	}
+}
+
+func TestZeroWordIsNOP(t *testing.T) {
+	var buf bytes.Buffer
+	c := New(WithMemorySize(testMemorySize), WithLogger(slog.New(slog.NewTextHandler(&buf, nil))))
+	load(t, c, []byte{0, 0})
+	before := c.Registers
+
+	c.StepState()
+	want := before
+	want[PC] = WordSize
+	if c.Registers != want {
+		t.Errorf("registers = %v, want %v", c.Registers, want)
+	}
+	if f := c.LastFault(); f != nil {
+		t.Errorf("zero word faulted: %+v", f)
+	}
+	if buf.Len() != 0 {
+		t.Errorf("zero word logged %q", buf.String())
+	}
+
+	// Zeroed memory idles forward until the PC runs off the end.
+	result := c.RunToCompletion()
+	if result.HaltReason != HaltPCOutOfBounds || result.Fault == nil || result.Fault.PC != testMemorySize {
+		t.Errorf("running zeroed memory: %+v, want to halt at 0x%04X with %q", result, testMemorySize, HaltPCOutOfBounds)
+	}
+}
+
+func TestReadCounter(t *testing.T) {
+	code := program(t, ext(ExtRDCNT, R1, 0), nop, nop, nop, ext(ExtRDCNT, R2, 0), halt)
+	tests := []struct {
+		name  string
+		start uint64 // instructions executed before the program
+	}{
+		{"from zero", 0},
+		{"across the wrap", 0xFFFE},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := newTestComputer(t)
+			load(t, c, code)
+			c.instructionCount = tt.start
+			c.RunToCompletion()
+			if first := c.Registers[R1]; first != uint16(tt.start+1) {
+				t.Errorf("first RDCNT = %d, want %d", first, uint16(tt.start+1))
+			}
+			if diff := c.Registers[R2] - c.Registers[R1]; diff != 4 {
+				t.Errorf("RDCNT difference = %d, want 4", diff)
+			}
+		})
+	}
+}
+
+// FuzzStep executes one instruction from arbitrary memory, PC and register
+// contents. The machine must never panic: the instruction either executes
+// or faults, halting with the fault's reason.
+func FuzzStep(f *testing.F) {
+	f.Add(program(f, ri(OpLW, R1, -1)), uint16(0), uint16(testMemorySize-1))       // load across the top
+	f.Add(program(f, ri(OpSW, R1, 0)), uint16(0), uint16(0xFFFF))                  // store past the end
+	f.Add(program(f, ext(ExtSW2, R1, R2)), uint16(0), uint16(testMemorySize-2))    // second word out of bounds
+	f.Add(program(f, ext(ExtLW2, SR, R0)), uint16(0), uint16(0))                   // register pair past SR
+	f.Add(program(f, liw(R1, 0))[:WordSize], uint16(0), uint16(0))                 // operand past the end
+	f.Add(program(f, rrr(OpCAS, R1, R2, R3)), uint16(0), uint16(testMemorySize-1)) // CAS across the top
+	f.Add([]byte{0xFF}, uint16(0), uint16(0))                                      // half a word
+	f.Add([]byte{}, uint16(0xFFFF), uint16(0))                                     // no memory at all
+
+	f.Fuzz(func(t *testing.T, memory []byte, pc, reg uint16) {
+		c := newTestComputer(t)
+		c.Memory = bytes.Clone(memory[:min(len(memory), testMemorySize)])
+		for r := R0; r <= R7; r++ {
+			c.Registers[r] = reg
+		}
+		c.Registers[PC] = pc
+		c.Running = true
+
+		c.StepState()
+		if fault := c.LastFault(); fault != nil {
+			if c.Running || c.haltReason != fault.Reason {
+				t.Errorf("fault %q left running %v with halt reason %q", fault.Reason, c.Running, c.haltReason)
+			}
+			return
+		}
+		if c.instructionCount != 1 {
+			t.Errorf("neither executed nor faulted: instruction count %d", c.instructionCount)
+		}
+	})
+}
+
+func TestStepState(t *testing.T) {
+	c := newTestComputer(t)
+	load(t, c, program(t, liw(R1, 5), ri(OpADDI, R2, 3), halt))
+
+	state := c.StepState()
+	if n := state["instructionCount"]; n != uint64(1) {
+		t.Errorf("instructionCount = %v, want 1", n)
+	}
+	if pc := state["pc"]; pc != uint16(4) {
+		t.Errorf("pc = %v, want 4", pc)
+	}
+	registers := state["registers"].([16]uint16)
+	if registers[R1] != 5 || registers[R2] != 0 {
+		t.Errorf("R1, R2 = %d, %d; want 5, 0", registers[R1], registers[R2])
+	}
+	// The state is a copy, not a view of the machine.
+	c.StepState()
+	if registers[R2] != 0 {
+		t.Error("returned registers changed with the machine")
+	}
+}
+
+func TestWithStack(t *testing.T) {
+	tests := []struct {
+		name      string
+		opts      []Option
+		sp, fp    uint16
+		wantPanic bool
+	}{
+		{"defaults", nil, testMemorySize - WordSize, testMemorySize - WordSize, false},
+		{"configured", []Option{WithStack(0x800, 0x7F0)}, 0x800, 0x7F0, false},
+		{"bottom of memory", []Option{WithStack(0, 0)}, 0, 0, false},
+		{"odd SP", []Option{WithStack(0x801, 0x800)}, 0, 0, true},
+		{"odd FP", []Option{WithStack(0x800, 0x7FF)}, 0, 0, true},
+		{"outside memory", []Option{WithStack(testMemorySize, 0x800)}, 0, 0, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			defer func() {
+				if r := recover(); (r != nil) != tt.wantPanic {
+					t.Errorf("panic = %v, want panic %v", r, tt.wantPanic)
+				}
+			}()
+			c := newTestComputer(t, tt.opts...)
+			if c.Registers[SP] != tt.sp || c.Registers[FP] != tt.fp {
+				t.Errorf("SP, FP = 0x%04X, 0x%04X; want 0x%04X, 0x%04X", c.Registers[SP], c.Registers[FP], tt.sp, tt.fp)
+			}
+			c.Registers[SP], c.Registers[FP] = 2, 2
+			c.Reset()
+			if c.Registers[SP] != tt.sp || c.Registers[FP] != tt.fp {
+				t.Errorf("after Reset, SP, FP = 0x%04X, 0x%04X; want 0x%04X, 0x%04X", c.Registers[SP], c.Registers[FP], tt.sp, tt.fp)
+			}
+		})
+	}
+
+	// Without test memory, the defaults are the last word of MemorySize.
+	c := New(WithLogger(slog.New(slog.DiscardHandler)))
+	if c.Registers[SP] != MemorySize-WordSize || c.Registers[FP] != MemorySize-WordSize {
+		t.Errorf("default SP, FP = 0x%04X, 0x%04X; want 0x%04X", c.Registers[SP], c.Registers[FP], MemorySize-WordSize)
+	}
+}
+
+func TestWithMemorySize(t *testing.T) {
+	tests := []struct {
+		name      string
+		size      int
+		wantPanic bool
+	}{
+		{"small", 0x100, false},
+		{"one word", WordSize, false},
+		{"full address space", 1 << 16, false},
+		{"zero", 0, true},
+		{"odd", 0x101, true},
+		{"beyond 16-bit addresses", 1<<16 + WordSize, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			defer func() {
+				if r := recover(); (r != nil) != tt.wantPanic {
+					t.Errorf("panic = %v, want panic %v", r, tt.wantPanic)
+				}
+			}()
+			c := New(WithLogger(slog.New(slog.DiscardHandler)), WithMemorySize(tt.size))
+			if got := c.MemorySize(); got != tt.size || len(c.Memory) != tt.size {
+				t.Errorf("MemorySize() = %d, len(Memory) = %d; want %d", got, len(c.Memory), tt.size)
+			}
+			top := uint16(tt.size - WordSize)
+			if c.Registers[SP] != top || c.Registers[FP] != top {
+				t.Errorf("SP, FP = 0x%04X, 0x%04X; want 0x%04X", c.Registers[SP], c.Registers[FP], top)
+			}
+		})
+	}
+
+	if got := New(WithLogger(slog.New(slog.DiscardHandler))).MemorySize(); got != MemorySize {
+		t.Errorf("default MemorySize() = %d, want %d", got, MemorySize)
+	}
+}
+
+func TestStepDelay(t *testing.T) {
+	tests := []struct {
+		name    string
+		delay   time.Duration
+		running bool
+		want    []time.Duration
+	}{
+		{"after every tick", 50 * time.Millisecond, true, []time.Duration{50 * time.Millisecond, 50 * time.Millisecond, 50 * time.Millisecond}},
+		{"zero disables", 0, true, nil},
+		{"not while paused", 50 * time.Millisecond, false, nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ticks := make(chan time.Time)
+			c := newTestComputer(t, WithClock(ticks))
+			load(t, c, program(t, ri(OpBZ, 0, -1))) // branches to itself forever
+			var slept []time.Duration
+			c.sleep = func(d time.Duration) { slept = append(slept, d) }
+			c.SetStepDelay(tt.delay)
+			c.SetRunning(tt.running)
+
+			done := make(chan struct{})
+			go func() {
+				c.Run()
+				close(done)
+			}()
+			for range 3 {
+				ticks <- time.Now()
+			}
+			close(ticks)
+			<-done
+
+			if !slices.Equal(slept, tt.want) {
+				t.Errorf("slept %v, want %v", slept, tt.want)
+			}
+		})
+	}
+}
+
+func TestAutoRestart(t *testing.T) {
+	code := program(t, ri(OpADDI, R0, 3), halt) // exit code 3, if R0 starts at 0
+	tests := []struct {
+		name    string
+		enabled bool
+		running bool
+	}{
+		{"stops at HALT", false, false},
+		{"re-arms at HALT", true, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := newTestComputer(t)
+			load(t, c, code)
+			c.SetAutoRestart(tt.enabled)
+			c.SetRunning(true)
+			for cycle := range 3 {
+				stepN(c, 2)
+				if c.IsRunning() != tt.running {
+					t.Fatalf("cycle %d: running %v, want %v", cycle, c.IsRunning(), tt.running)
+				}
+				if c.ExitCode() != 3 {
+					t.Errorf("cycle %d: exit code %d, want 3", cycle, c.ExitCode())
+				}
+				if !tt.running {
+					return
+				}
+				if state := c.GetState(); state["pc"] != uint16(0) || state["autoRestart"] != true {
+					t.Errorf("cycle %d: pc %v, autoRestart %v; want the entry point and true", cycle, state["pc"], state["autoRestart"])
+				}
+			}
+		})
+	}
+}
+
+func TestSkipInstruction(t *testing.T) {
+	tests := []struct {
+		name  string
+		ins   Instruction
+		width uint16
+	}{
+		{"one word", ri(OpADDI, R1, 5), WordSize},
+		{"two words", liw(R1, 0xBEEF), 2 * WordSize},
+		{"store", ri(OpSW, R1, 0), WordSize},
+		{"HALT", halt, WordSize},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := newTestComputer(t, WithHistory(4))
+			load(t, c, program(t, tt.ins, halt))
+			c.Registers[R1] = 7
+			c.Running = true
+			var notified atomic.Int64
+			c.AddObserver(observerFunc(func(*MonTanaMiniComputer) { notified.Add(1) }))
+			before, memory := c.Registers, bytes.Clone(c.Memory)
+
+			c.SkipInstruction()
+			want := before
+			want[PC] += tt.width
+			if c.Registers != want {
+				t.Errorf("registers = %v, want %v", c.Registers, want)
+			}
+			if !bytes.Equal(c.Memory, memory) || c.InstructionCount() != 0 || !c.IsRunning() {
+				t.Error("skipped instruction had an effect")
+			}
+			if notified.Load() != 1 {
+				t.Errorf("observers notified %d times, want once", notified.Load())
+			}
+
+			if err := c.StepBack(); err != nil {
+				t.Fatal(err)
+			}
+			if c.Registers != before {
+				t.Errorf("after StepBack, registers = %v, want %v", c.Registers, before)
+			}
+		})
+	}
+}
+
+func TestZeroRegister(t *testing.T) {
+	tests := []struct {
+		name   string
+		opts   []Option
+		poke   uint16 // R7 before running, as the host might set it
+		code   []Instruction
+		r1, r7 uint16
+	}{
+		{"writes discarded", []Option{WithZeroRegister(R7)}, 0, []Instruction{liw(R7, 5), rrr(OpADD, R1, R7, R7)}, 0, 0},
+		{"reads zero", []Option{WithZeroRegister(R7)}, 9, []Instruction{liw(R2, 4), rrr(OpADD, R1, R7, R2)}, 4, 0},
+		{"MOV", []Option{WithZeroRegister(R7)}, 0, []Instruction{liw(R2, 6), rrr(OpADD, R1, R2, R7)}, 6, 0},
+		{"disabled", nil, 0, []Instruction{liw(R7, 5), rrr(OpADD, R1, R7, R7)}, 10, 5},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := newTestComputer(t, tt.opts...)
+			load(t, c, program(t, append(tt.code, halt)...))
+			c.Registers[R7] = tt.poke
+			c.RunToCompletion()
+			if c.Registers[R1] != tt.r1 || c.Registers[R7] != tt.r7 {
+				t.Errorf("R1, R7 = %d, %d; want %d, %d", c.Registers[R1], c.Registers[R7], tt.r1, tt.r7)
+			}
+		})
+	}
+
+	for _, reg := range []int{-1, PC, 16} {
+		func() {
+			defer func() {
+				if recover() == nil {
+					t.Errorf("WithZeroRegister(%d) did not panic", reg)
+				}
+			}()
+			newTestComputer(t, WithZeroRegister(reg))
+		}()
+	}
+}
+
+func TestRestart(t *testing.T) {
+	// Stores 7 at 0x40 and writes "!" before halting with exit code 7.
+	code := program(t, nop, liw(R1, 7), ri(OpSW, R1, 0x40), liw(R0, '!'), ext(ExtOUT, R0, 0), rrr(OpADD, R0, R1, R2), halt)
+	tests := []struct {
+		name  string
+		entry uint16 // relative to the load address 0x100
+		steps int    // 0 runs to completion
+	}{
+		{"after halting", 0, 0},
+		{"part way", 0, 3},
+		{"entry after load address", 2, 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := newTestComputer(t)
+			if err := c.LoadProgramAt(code, 0x100, 0x100+tt.entry); err != nil {
+				t.Fatal(err)
+			}
+			if tt.steps == 0 {
+				c.RunToCompletion()
+			} else {
+				stepN(c, tt.steps)
+			}
+			memory := bytes.Clone(c.Memory)
+
+			if err := c.Restart(); err != nil {
+				t.Fatal(err)
+			}
+			var want [16]uint16
+			want[SP], want[FP], want[PC] = testMemorySize-WordSize, testMemorySize-WordSize, 0x100+tt.entry
+			if c.Registers != want {
+				t.Errorf("registers = %v, want %v", c.Registers, want)
+			}
+			if !bytes.Equal(c.Memory, memory) {
+				t.Error("restart changed memory")
+			}
+			if got, _ := c.ReadMemory(0x100, len(code)); !bytes.Equal(got, code) {
+				t.Errorf("program = % X, want % X", got, code)
+			}
+			state := c.GetState()
+			if state["haltReason"] != HaltNone || state["exitCode"] != uint16(0) || state["output"] != "" || c.InstructionCount() != 0 || c.IsRunning() {
+				t.Errorf("after restart, halt reason %q, exit code %v, output %q, count %d, running %v; want all cleared",
+					state["haltReason"], state["exitCode"], state["output"], c.InstructionCount(), c.IsRunning())
+			}
+
+			// It runs again just as the first time.
+			if r := c.RunToCompletion(); r.HaltReason != HaltInstruction || c.Registers[R0] != 7 {
+				t.Errorf("second run halted with %q and exit code %d, want %q and 7", r.HaltReason, c.Registers[R0], HaltInstruction)
+			}
+		})
+	}
+
+	c := newTestComputer(t)
+	if err := c.Restart(); err == nil {
+		t.Error("Restart with nothing loaded succeeded")
+	}
+}
+
+func TestAutoStart(t *testing.T) {
+	tests := []struct {
+		name string
+		opts []Option
+		want bool // running once a program is loaded
+	}{
+		{"paused by default", nil, false},
+		{"autostart", []Option{WithAutoStart()}, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := newTestComputer(t, tt.opts...)
+			if c.IsRunning() {
+				t.Error("new machine is running with nothing loaded")
+			}
+			if got := c.GetState()["autoStart"]; got != tt.want {
+				t.Errorf("state autoStart = %v, want %v", got, tt.want)
+			}
+			load(t, c, program(t, ri(OpBZ, 0, -1)))
+			if c.IsRunning() != tt.want {
+				t.Errorf("after loading, running %v, want %v", c.IsRunning(), tt.want)
+			}
+			c.SetRunning(false)
+			if err := c.Restart(); err != nil {
+				t.Fatal(err)
+			}
+			if c.IsRunning() != tt.want {
+				t.Errorf("after restarting, running %v, want %v", c.IsRunning(), tt.want)
+			}
+			c.Reset()
+			if c.IsRunning() {
+				t.Error("running after Reset")
+			}
+		})
+	}
+}
+
+func TestWordSize(t *testing.T) {
+	tests := []struct {
+		name string
+		ins  Instruction
+	}{
+		{"NOP", nop},
+		{"ADD", rrr(OpADD, R1, R2, R3)},
+		{"ADDI", ri(OpADDI, R1, 1)},
+		{"LW", ri(OpLW, R1, 0)},
+		{"SW", ri(OpSW, R1, 0x0A)},
+		{"branch not taken", ri(OpBZ, 0, 4)},
+		{"extended", ext(ExtOUT, R1, 0)},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := newTestComputer(t)
+			load(t, c, program(t, nop, tt.ins))
+			c.Registers[R0] = 1 // so the branch falls through
+			c.Registers[PC] = WordSize
+			c.StepState()
+			if pc := c.Registers[PC]; pc != 2*WordSize {
+				t.Errorf("PC = %d after one instruction at %d, want %d", pc, WordSize, 2*WordSize)
+			}
+		})
+	}
+
+	c := New(WithLogger(slog.New(slog.DiscardHandler)))
+	if sp := c.Registers[SP]; sp != MemorySize-WordSize {
+		t.Errorf("SP = 0x%04X, want MemorySize - WordSize = 0x%04X", sp, MemorySize-WordSize)
+	}
+}
+
+func TestBitInstructions(t *testing.T) {
+	const value = 0b1010_0000_0000_0101
+	tests := []struct {
+		name  string
+		ins   Instruction
+		sr    uint16 // before
+		want  uint16 // R1 afterwards
+		wantZ bool   // zero flag afterwards
+	}{
+		{"BTST set bit", ext(ExtBTST, R1, 0), FlagZero, value, false},
+		{"BTST clear bit", ext(ExtBTST, R1, 1), 0, value, true},
+		{"BTST top bit", ext(ExtBTST, R1, 15), FlagZero, value, false},
+		{"BSET clear bit", ext(ExtBSET, R1, 1), 0, value | 0b10, false},
+		{"BSET set bit", ext(ExtBSET, R1, 2), 0, value, false},
+		{"BSET top bit", ext(ExtBSET, R1, 14), 0, value | 1<<14, false},
+		{"BCLR set bit", ext(ExtBCLR, R1, 15), 0, value &^ (1 << 15), false},
+		{"BCLR clear bit", ext(ExtBCLR, R1, 3), 0, value, false},
+		{"BSET leaves the flag", ext(ExtBSET, R1, 1), FlagZero, value | 0b10, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := newTestComputer(t)
+			load(t, c, program(t, tt.ins))
+			c.Registers[R1] = value
+			c.Registers[R2] = 0x1234
+			c.Registers[SR] = tt.sr | FlagCarry
+			c.StepState()
+			if c.Registers[R1] != tt.want {
+				t.Errorf("R1 = %016b, want %016b", c.Registers[R1], tt.want)
+			}
+			if c.Registers[R2] != 0x1234 {
+				t.Error("another register changed")
+			}
+			if zero := c.Registers[SR]&FlagZero != 0; zero != tt.wantZ {
+				t.Errorf("zero flag = %v, want %v", zero, tt.wantZ)
+			}
+			if c.Registers[SR]&FlagCarry == 0 {
+				t.Error("another flag changed")
+			}
+		})
+	}
+}
+
+func TestTrappingArithmetic(t *testing.T) {
+	tests := []struct {
+		name string
+		ins  Instruction
+		a, b uint16 // R1 and R2
+		want uint16 // R1 afterwards
+		halt HaltReason
+	}{
+		{"ADDO", ext(ExtADDO, R1, R2), 2, 3, 5, HaltInstruction},
+		{"ADDO negative", ext(ExtADDO, R1, R2), 0xFFFE, 0xFFFD, 0xFFFB, HaltInstruction},
+		{"ADDO overflow", ext(ExtADDO, R1, R2), 0x7FFF, 1, 0x7FFF, HaltOverflow},
+		{"ADDO underflow", ext(ExtADDO, R1, R2), 0x8000, 0xFFFF, 0x8000, HaltOverflow},
+		{"ADD wraps", rrr(OpADD, R1, R1, R2), 0x7FFF, 1, 0x8000, HaltInstruction},
+		{"SUBO", ext(ExtSUBO, R1, R2), 2, 3, 0xFFFF, HaltInstruction},
+		{"SUBO to the minimum", ext(ExtSUBO, R1, R2), 0xFFFF, 0x7FFF, 0x8000, HaltInstruction},
+		{"SUBO overflow", ext(ExtSUBO, R1, R2), 0x7FFF, 0xFFFF, 0x7FFF, HaltOverflow},
+		{"SUBO of the minimum", ext(ExtSUBO, R1, R2), 0, 0x8000, 0, HaltOverflow},
+		{"SUB wraps", rrr(OpSUB, R1, R1, R2), 0x8000, 1, 0x7FFF, HaltInstruction},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := newTestComputer(t)
+			load(t, c, program(t, tt.ins, halt))
+			c.Registers[R1], c.Registers[R2] = tt.a, tt.b
+			result := c.RunToCompletion()
+			if result.HaltReason != tt.halt {
+				t.Fatalf("halt reason %q, want %q", result.HaltReason, tt.halt)
+			}
+			if c.Registers[R1] != tt.want {
+				t.Errorf("R1 = 0x%04X, want 0x%04X", c.Registers[R1], tt.want)
+			}
+			if tt.halt == HaltOverflow && (result.Fault == nil || result.Fault.PC != 0) {
+				t.Errorf("fault = %+v, want one at 0x0000", result.Fault)
+			}
+		})
+	}
+}
+
+func TestLoadData(t *testing.T) {
+	c := newTestComputer(t)
+	load(t, c, program(t, liw(R1, 5), nop, halt))
+	c.StepState()
+	before := c.Registers
+
+	data := []byte{1, 2, 3}
+	if err := c.LoadData(data, 0x100); err != nil {
+		t.Fatal(err)
+	}
+	if got, _ := c.ReadMemory(0x100, len(data)); !bytes.Equal(got, data) {
+		t.Errorf("memory at 0x100 = % X, want % X", got, data)
+	}
+	if c.Registers != before {
+		t.Errorf("registers = %v, want %v unchanged", c.Registers, before)
+	}
+	if c.InstructionCount() != 1 {
+		t.Errorf("instruction count %d, want 1", c.InstructionCount())
+	}
+
+	// Reloading the program puts the data back too.
+	if err := c.WriteMemory(0x100, []byte{9}); err != nil {
+		t.Fatal(err)
+	}
+	if err := c.ReloadProgram(nil); err != nil {
+		t.Fatal(err)
+	}
+	if got, _ := c.ReadMemory(0x100, len(data)); !bytes.Equal(got, data) {
+		t.Errorf("after reloading, memory at 0x100 = % X, want % X", got, data)
+	}
+	if c.Registers[PC] != 0 {
+		t.Errorf("after reloading, PC = %d, want the entry point", c.Registers[PC])
+	}
+}
+
+func TestDoubleWord(t *testing.T) {
+	c := newTestComputer(t)
+	load(t, c, program(t,
+		liw(HI, 0x1234),
+		liw(LO, 0x5678),
+		liw(R0, 0x100),
+		ext(ExtSW2, HI, R0),
+		ext(ExtLW2, R2, R0), // into R2:R3
+		halt,
+	))
+	if result := c.RunToCompletion(); result.HaltReason != HaltInstruction {
+		t.Fatalf("halt reason %q, want %q", result.HaltReason, HaltInstruction)
+	}
+	if got, _ := c.ReadMemory(0x100, 4); !bytes.Equal(got, []byte{0x12, 0x34, 0x56, 0x78}) {
+		t.Errorf("memory = % X, want the high word first", got)
+	}
+	if c.Registers[R2] != 0x1234 || c.Registers[R3] != 0x5678 {
+		t.Errorf("R2:R3 = %04X:%04X, want 1234:5678", c.Registers[R2], c.Registers[R3])
+	}
+
+	tests := []struct {
+		name string
+		ins  Instruction
+		addr uint16
+		halt HaltReason
+	}{
+		{"store second word outside", ext(ExtSW2, HI, R0), testMemorySize - WordSize, HaltMemoryFault},
+		{"load second word outside", ext(ExtLW2, R2, R0), testMemorySize - WordSize, HaltMemoryFault},
+		{"address wraps", ext(ExtLW2, R2, R0), 0xFFFE, HaltMemoryFault},
+		{"register pair past SR", ext(ExtLW2, SR, R0), 0x100, HaltUnknownInstruction},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := newTestComputer(t)
+			load(t, c, program(t, tt.ins, halt))
+			c.Registers[R0], c.Registers[HI], c.Registers[LO] = tt.addr, 0xAAAA, 0xBBBB
+			memory, registers := bytes.Clone(c.Memory), c.Registers
+			if result := c.RunToCompletion(); result.HaltReason != tt.halt {
+				t.Fatalf("halt reason %q, want %q", result.HaltReason, tt.halt)
+			}
+			if !bytes.Equal(c.Memory, memory) {
+				t.Error("faulting instruction wrote memory")
+			}
+			registers[PC] = c.Registers[PC]
+			if c.Registers != registers {
+				t.Error("faulting instruction changed registers")
+			}
+		})
+	}
+}
+
+func TestStateStack(t *testing.T) {
+	const top = testMemorySize - WordSize
+	tests := []struct {
+		name   string
+		pushed []uint16 // pushed in order onto the initial stack
+		sp     uint16   // replaces SP after pushing, if not zero
+		want   []StackWord
+	}{
+		{"initial", nil, 0, []StackWord{{top, 0}}},
+		{"pushed", []uint16{0xBEEF, 7}, 0, []StackWord{{top - 4, 7}, {top - 2, 0xBEEF}, {top, 0}}},
+		{"odd SP", nil, top - 1, []StackWord{{top - 1, 0}}},
+		{"SP past memory", nil, testMemorySize, []StackWord{}},
+		{"SP wrapped", []uint16{1}, 0xFFFE, []StackWord{}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := newTestComputer(t)
+			for _, v := range tt.pushed {
+				c.Registers[SP] -= WordSize
+				if err := c.WriteMemory(c.Registers[SP], []byte{byte(v >> 8), byte(v)}); err != nil {
+					t.Fatal(err)
+				}
+			}
+			if tt.sp != 0 {
+				c.Registers[SP] = tt.sp
+			}
+			got, ok := c.GetState()["stack"].([]StackWord)
+			if !ok || !slices.Equal(got, tt.want) {
+				t.Errorf("stack = %v, want %v", got, tt.want)
+			}
+		})
+	}
+
+	// A deep stack is cut off after stateStackWords words.
+	c := newTestComputer(t)
+	c.Registers[SP] = 0
+	if got := c.GetState()["stack"].([]StackWord); len(got) != stateStackWords || got[0].Address != 0 {
+		t.Errorf("deep stack has %d words from 0x%04X, want %d from 0", len(got), got[0].Address, stateStackWords)
+	}
+}
@@ -0,0 +1,113 @@
+package emulator
+
+import (
+	"fmt"
+	"runtime/debug"
+)
+
+// StateObserver is an Observer that accepts a snapshot of the state, as
+// GetState returns it, instead of the machine itself. Observers that only
+// need the state should implement it: Update is called while the machine is
+// locked, so it cannot call methods such as GetState, but UpdateState needs
+// nothing from the machine.
+type StateObserver interface {
+	Observer
+	UpdateState(state map[string]interface{})
+}
+
+// notification is one state change to deliver to the observers.
+type notification struct {
+	state     map[string]interface{}
+	observers []Observer
+}
+
+// WithAsyncObservers delivers observer notifications from a separate
+// goroutine rather than inside the execution loop, so a slow observer, such
+// as a WebSocket client on a stalled connection, cannot hold up the machine.
+// If the observers fall behind, states they have not yet been sent are
+// dropped in favour of the newest, so they always catch up to the current
+// state. Observers are then called without the machine locked; those that
+// are not StateObservers still get the machine, and may call its methods.
+// By default notifications are delivered synchronously, which keeps tests
+// deterministic.
+func WithAsyncObservers() Option {
+	return func(c *MonTanaMiniComputer) {
+		if c.notifications != nil {
+			return
+		}
+		c.notifications = make(chan notification, 1)
+		go c.deliverNotifications()
+	}
+}
+
+// notifyObservers notifies all observers of a state change. The caller must
+// hold the mutex. An observer that panics is removed; see callObserver.
+func (c *MonTanaMiniComputer) notifyObservers() {
+	if len(c.observers) == 0 {
+		return
+	}
+	if c.notifications == nil {
+		var state map[string]interface{}
+		getState := func() map[string]interface{} {
+			if state == nil {
+				state = c.state()
+			}
+			return state
+		}
+		// Filter in place rather than by comparing observers, which need
+		// not be comparable.
+		kept := c.observers[:0]
+		for _, o := range c.observers {
+			if c.callObserver(o, getState) {
+				kept = append(kept, o)
+			}
+		}
+		clear(c.observers[len(kept):])
+		c.observers = kept
+		return
+	}
+
+	n := notification{state: c.state(), observers: append([]Observer(nil), c.observers...)}
+	select {
+	case c.notifications <- n:
+	default:
+		// The observers are still busy with an earlier state. Replace it
+		// if it has not been picked up yet; this is the only sender, so
+		// the send below cannot block.
+		select {
+		case <-c.notifications:
+		default:
+		}
+		c.notifications <- n
+	}
+}
+
+// deliverNotifications calls the observers for each notification in turn.
+func (c *MonTanaMiniComputer) deliverNotifications() {
+	for n := range c.notifications {
+		for _, o := range n.observers {
+			if !c.callObserver(o, func() map[string]interface{} { return n.state }) {
+				c.RemoveObserver(o)
+			}
+		}
+	}
+}
+
+// callObserver notifies o, passing StateObservers the state that getState
+// returns. A panic in o is recovered and logged, so a faulty observer cannot
+// bring down the machine, and callObserver returns false so the caller can
+// remove it.
+func (c *MonTanaMiniComputer) callObserver(o Observer, getState func() map[string]interface{}) (ok bool) {
+	defer func() {
+		if r := recover(); r != nil {
+			c.logger.Error("observer panicked; removing it", "observer", fmt.Sprintf("%T", o), "panic", r, "stack", string(debug.Stack()))
+			ok = false
+		}
+	}()
+	if so, isState := o.(StateObserver); isState {
+		so.UpdateState(getState())
+	} else {
+		o.Update(c)
+	}
+	return true
+}
@@ -0,0 +1,128 @@
+package emulator
+
+import (
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// stateObserverFunc adapts a function to the StateObserver interface.
+type stateObserverFunc func(map[string]interface{})
+
+func (f stateObserverFunc) Update(*MonTanaMiniComputer)              {}
+func (f stateObserverFunc) UpdateState(state map[string]interface{}) { f(state) }
+
+func TestAsyncObserversDoNotStall(t *testing.T) {
+	const steps = 1000
+	c := newTestComputer(t, WithAsyncObservers())
+	load(t, c, program(t, ri(OpBZ, 0, -1))) // branches to itself forever
+
+	// The observer blocks until released, like a stalled WebSocket.
+	release := make(chan struct{})
+	counts := make(chan uint64, steps)
+	c.AddObserver(stateObserverFunc(func(state map[string]interface{}) {
+		<-release
+		counts <- state["instructionCount"].(uint64)
+	}))
+
+	done := make(chan struct{})
+	go func() {
+		stepN(c, steps)
+		close(done)
+	}()
+	select {
+	case <-done:
+	case <-time.After(5 * time.Second):
+		close(release)
+		t.Fatal("machine stalled behind a slow observer")
+	}
+
+	// Once released, the observer skips the states it missed and catches
+	// up with the newest.
+	close(release)
+	timeout := time.After(5 * time.Second)
+	var seen []uint64
+	for len(seen) == 0 || seen[len(seen)-1] != steps {
+		select {
+		case n := <-counts:
+			seen = append(seen, n)
+		case <-timeout:
+			t.Fatalf("observer saw instruction counts %v, never %d", seen, steps)
+		}
+	}
+	if len(seen) > 3 {
+		t.Errorf("observer was sent %d states, want the stale ones dropped", len(seen))
+	}
+}
+
+// panicObserver panics whenever it is notified, counting the times.
+type panicObserver struct{ calls *atomic.Int32 }
+
+func (o panicObserver) Update(*MonTanaMiniComputer) {
+	o.calls.Add(1)
+	panic("observer bug")
+}
+
+func TestPanickingObserverRemoved(t *testing.T) {
+	tests := []struct {
+		name  string
+		async bool
+		state bool // panic in UpdateState rather than Update
+	}{
+		{"Update", false, false},
+		{"UpdateState", false, true},
+		{"async Update", true, false},
+		{"async UpdateState", true, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var opts []Option
+			if tt.async {
+				opts = append(opts, WithAsyncObservers())
+			}
+			c := newTestComputer(t, opts...)
+			load(t, c, program(t, ri(OpBZ, 0, -1))) // branches to itself forever
+
+			var good atomic.Uint64 // the last instruction count the good observer saw
+			c.AddObserver(stateObserverFunc(func(state map[string]interface{}) { good.Store(state["instructionCount"].(uint64)) }))
+			var calls atomic.Int32
+			bad := stateObserverFunc(func(map[string]interface{}) {
+				calls.Add(1)
+				panic("observer bug")
+			})
+			switch {
+			case !tt.state:
+				c.AddObserver(panicObserver{&calls})
+			case tt.async:
+				// The asynchronous path removes observers by comparing
+				// them, which functions cannot be.
+				c.AddObserver(&bad)
+			default:
+				c.AddObserver(bad)
+			}
+
+			// Step once and wait for the faulty observer to be removed, so
+			// no notification already queued for it calls it again.
+			wait := func(count uint64) {
+				t.Helper()
+				deadline := time.Now().Add(5 * time.Second)
+				for c.ObserverCount() != 1 || good.Load() != count {
+					if time.Now().After(deadline) {
+						t.Fatalf("%d observers, the good one at count %d; want 1 at %d", c.ObserverCount(), good.Load(), count)
+					}
+					time.Sleep(time.Millisecond)
+				}
+			}
+			stepN(c, 1)
+			wait(1)
+			stepN(c, 2)
+			wait(3)
+			if n := calls.Load(); n != 1 {
+				t.Errorf("faulty observer called %d times, want once before it was removed", n)
+			}
+			if got := c.InstructionCount(); got != 3 {
+				t.Errorf("machine executed %d instructions, want 3", got)
+			}
+		})
+	}
+}
@@ -0,0 +1,15 @@
+package emulator
+
+// Profile returns how many times each opcode has executed since the last
+// reset, keyed by mnemonic. Executions of unassigned opcodes are not reported.
+func (c *MonTanaMiniComputer) Profile() map[string]uint64 {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	profile := make(map[string]uint64)
+	for op, info := range opcodes {
+		if info.Mnemonic != "" {
+			profile[info.Mnemonic] = c.opCounts[op]
+		}
+	}
+	return profile
+}
@@ -0,0 +1,35 @@
+package emulator
+
+import "testing"
+
+func TestProfile(t *testing.T) {
+	c := newTestComputer(t)
+	load(t, c, program(t,
+		liw(R0, 3),
+		liw(R2, 1),
+		rrr(OpSUB, R0, R0, R2), // loop: count R0 down
+		ri(OpBZ, 0, 2),         // to HALT once R0 is 0
+		rrr(OpADD, R1, R1, R2),
+		ri(OpBZ, 0, -4), // back to the SUB; SR is clear, so always taken
+		halt,
+	))
+	c.RunToCompletion()
+
+	want := map[string]uint64{"EXT": 2, "SUB": 3, "ADD": 2, "BZ": 5, "HALT": 1, "NOP": 0, "LW": 0}
+	profile := c.Profile()
+	for mnemonic, n := range want {
+		if got := profile[mnemonic]; got != n {
+			t.Errorf("%s count = %d, want %d", mnemonic, got, n)
+		}
+	}
+	if len(profile) != len(opcodes) {
+		t.Errorf("profile has %d opcodes, want all %d", len(profile), len(opcodes))
+	}
+
+	c.Reset()
+	for mnemonic, n := range c.Profile() {
+		if n != 0 {
+			t.Errorf("%s count after Reset = %d, want 0", mnemonic, n)
+		}
+	}
+}
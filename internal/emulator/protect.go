@@ -0,0 +1,33 @@
+package emulator
+
+import "fmt"
+
+// addressRange is an inclusive range of memory addresses.
+type addressRange struct {
+	start, end uint16
+}
+
+// SetReadOnly marks memory from start through end, inclusive, as read-only.
+// A program storing into it faults with HaltROMWrite, which catches wild
+// pointers overwriting code. Loading programs and writes from outside the
+// machine are unaffected. Protection is cleared by Reset.
+func (c *MonTanaMiniComputer) SetReadOnly(start, end uint16) error {
+	if start > end || int(end) >= len(c.Memory) {
+		return fmt.Errorf("invalid read-only range 0x%04X-0x%04X", start, end)
+	}
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	c.readOnly = append(c.readOnly, addressRange{start, end})
+	return nil
+}
+
+// isReadOnly reports whether any address from start through end, inclusive,
+// lies in a read-only range.
+func (c *MonTanaMiniComputer) isReadOnly(start, end uint16) bool {
+	for _, r := range c.readOnly {
+		if start <= r.end && end >= r.start {
+			return true
+		}
+	}
+	return false
+}
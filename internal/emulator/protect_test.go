@@ -0,0 +1,63 @@
+package emulator
+
+import "testing"
+
+func TestReadOnly(t *testing.T) {
+	const start, end = 0x100, 0x10F
+	tests := []struct {
+		name  string
+		store Instruction // stores R1 (and R2) at R0
+		addr  uint16
+		halt  HaltReason
+	}{
+		{"inside", ri(OpSW, R1, 0), 0x108, HaltROMWrite},
+		{"first word", ri(OpSW, R1, 0), start, HaltROMWrite},
+		{"last word", ri(OpSW, R1, 0), end - 1, HaltROMWrite},
+		{"straddling the start", ri(OpSW, R1, 0), start - 1, HaltROMWrite},
+		{"just below", ri(OpSW, R1, 0), start - 2, HaltInstruction},
+		{"just above", ri(OpSW, R1, 0), end + 1, HaltInstruction},
+		{"double word reaching in", ext(ExtSW2, R1, R0), start - 2, HaltROMWrite},
+		{"double word below", ext(ExtSW2, R1, R0), start - 4, HaltInstruction},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := newTestComputer(t)
+			load(t, c, program(t, liw(R0, tt.addr), liw(R1, 0xAAAA), liw(R2, 0xBBBB), tt.store, halt))
+			if err := c.SetReadOnly(start, end); err != nil {
+				t.Fatal(err)
+			}
+			result := c.RunToCompletion()
+			if result.HaltReason != tt.halt {
+				t.Fatalf("halt reason %q, want %q", result.HaltReason, tt.halt)
+			}
+			got, _ := c.ReadMemory(tt.addr, 2)
+			if stored := got[0] == 0xAA && got[1] == 0xAA; stored != (tt.halt == HaltInstruction) {
+				t.Errorf("memory at 0x%04X = % X after %q", tt.addr, got, tt.halt)
+			}
+		})
+	}
+}
+
+func TestReadOnlyOutsideProgram(t *testing.T) {
+	c := newTestComputer(t)
+	if err := c.SetReadOnly(0x100, 0x10F); err != nil {
+		t.Fatal(err)
+	}
+	// Writes from outside the machine are not the program's.
+	if err := c.WriteMemory(0x100, []byte{1, 2}); err != nil {
+		t.Errorf("WriteMemory into a read-only range: %v", err)
+	}
+
+	// Reset clears the protection.
+	c.Reset()
+	load(t, c, program(t, liw(R0, 0x100), ri(OpSW, R1, 0), halt))
+	if result := c.RunToCompletion(); result.HaltReason != HaltInstruction {
+		t.Errorf("after Reset, halt reason %q, want %q", result.HaltReason, HaltInstruction)
+	}
+
+	for _, r := range [][2]uint16{{0x10, 0x0F}, {0, testMemorySize}} {
+		if err := c.SetReadOnly(r[0], r[1]); err == nil {
+			t.Errorf("SetReadOnly(0x%04X, 0x%04X) succeeded", r[0], r[1])
+		}
+	}
+}
@@ -0,0 +1,94 @@
+package emulator
+
+import "math/rand/v2"
+
+// DefaultSeed seeds the RAND generator of every new machine, so runs are
+// reproducible unless the host picks another seed with SetSeed.
+const DefaultSeed uint64 = 0x4D544D43 // "MTMC"
+
+// rng is the machine's own random source. Each machine has one, so
+// concurrent machines never disturb each other's sequences.
+type rng struct {
+	seed uint64
+	pcg  *rand.PCG
+	r    *rand.Rand
+}
+
+// rngState is a position in an rng's sequence, for StepBack.
+type rngState struct {
+	pcg rand.PCG
+	ok  bool // false if the sequence had not started
+}
+
+// reseed restarts the sequence from seed.
+func (g *rng) reseed(seed uint64) {
+	g.seed = seed
+	g.pcg = rand.NewPCG(seed, seed)
+	g.r = rand.New(g.pcg)
+}
+
+// next returns the next 16-bit random value.
+func (g *rng) next() uint16 {
+	if g.r == nil {
+		g.reseed(g.seed)
+	}
+	return uint16(g.r.Uint32())
+}
+
+// state returns the current position in the sequence.
+func (g *rng) state() rngState {
+	if g.pcg == nil {
+		return rngState{}
+	}
+	return rngState{pcg: *g.pcg, ok: true}
+}
+
+// restore returns the sequence to a position state returned.
+func (g *rng) restore(s rngState) {
+	if !s.ok {
+		g.pcg, g.r = nil, nil
+		return
+	}
+	if g.pcg == nil {
+		g.reseed(g.seed)
+	}
+	*g.pcg = s.pcg
+}
+
+// encode returns s as bytes for a Snapshot, or nil if the sequence had not
+// started.
+func (s rngState) encode() []byte {
+	if !s.ok {
+		return nil
+	}
+	data, _ := s.pcg.MarshalBinary() // never fails
+	return data
+}
+
+// decodeRNGState decodes a state that encode returned.
+func decodeRNGState(data []byte) (rngState, error) {
+	if len(data) == 0 {
+		return rngState{}, nil
+	}
+	var s rngState
+	if err := s.pcg.UnmarshalBinary(data); err != nil {
+		return rngState{}, err
+	}
+	s.ok = true
+	return s, nil
+}
+
+// WithSeed sets the seed for RAND. It defaults to DefaultSeed.
+func WithSeed(seed uint64) Option {
+	return func(c *MonTanaMiniComputer) {
+		c.rng.reseed(seed)
+	}
+}
+
+// SetSeed restarts the RAND sequence from seed. Reset restarts it from the
+// same seed, so a program reloaded after a reset sees the same numbers.
+func (c *MonTanaMiniComputer) SetSeed(seed uint64) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	c.rng.reseed(seed)
+}
@@ -0,0 +1,58 @@
+package emulator
+
+import (
+	"slices"
+	"testing"
+)
+
+// randoms runs a program executing RAND n times and returns the values.
+func randoms(t *testing.T, c *MonTanaMiniComputer, n int) []uint16 {
+	t.Helper()
+	load(t, c, program(t, ext(ExtRAND, R1, 0)))
+	values := make([]uint16, n)
+	for i := range values {
+		c.Registers[PC] = 0
+		c.StepState()
+		values[i] = c.Registers[R1]
+	}
+	return values
+}
+
+func TestSeed(t *testing.T) {
+	const n = 8
+	defaults := randoms(t, newTestComputer(t), n)
+	tests := []struct {
+		name string
+		a, b func(*MonTanaMiniComputer)
+		same bool
+	}{
+		{"default seed", func(*MonTanaMiniComputer) {}, func(*MonTanaMiniComputer) {}, true},
+		{"same seed", func(c *MonTanaMiniComputer) { c.SetSeed(42) }, func(c *MonTanaMiniComputer) { c.SetSeed(42) }, true},
+		{"option and SetSeed", func(c *MonTanaMiniComputer) { WithSeed(42)(c) }, func(c *MonTanaMiniComputer) { c.SetSeed(42) }, true},
+		{"different seeds", func(c *MonTanaMiniComputer) { c.SetSeed(1) }, func(c *MonTanaMiniComputer) { c.SetSeed(2) }, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			a, b := newTestComputer(t), newTestComputer(t)
+			tt.a(a)
+			tt.b(b)
+			va, vb := randoms(t, a, n), randoms(t, b, n)
+			if slices.Equal(va, vb) != tt.same {
+				t.Errorf("sequences %v and %v, want equal %v", va, vb, tt.same)
+			}
+		})
+	}
+
+	// Another machine drawing in between does not disturb the sequence.
+	a, b := newTestComputer(t), newTestComputer(t)
+	load(t, a, program(t, ext(ExtRAND, R1, 0)))
+	load(t, b, program(t, ext(ExtRAND, R1, 0)))
+	for i := range n {
+		a.Registers[PC], b.Registers[PC] = 0, 0
+		b.StepState()
+		a.StepState()
+		if a.Registers[R1] != defaults[i] {
+			t.Fatalf("value %d = %d with another machine drawing, want %d", i, a.Registers[R1], defaults[i])
+		}
+	}
+}
@@ -92,6 +92,37 @@ func (r Register) IsTempRegister() bool {
 	return 0 <= r && r < 6
 }
 
+func (r Register) IsArgRegister() bool {
+	return A0 <= r && r <= A3
+}
+
+func (r Register) IsSpecialRegister() bool {
+	return RA <= r && r <= PC
+}
+
+// Role categories, as returned by Role.
+const (
+	RoleTemporary   = "temporary"
+	RoleArgument    = "argument"
+	RoleReturnValue = "return value"
+	RoleSpecial     = "special"  // return address, frame, stack and break pointers and PC
+	RoleInternal    = "internal" // not visible to programs
+)
+
+func (r Register) Role() string {
+	switch {
+	case r.IsTempRegister():
+		return RoleTemporary
+	case r.IsArgRegister():
+		return RoleArgument
+	case r == RV:
+		return RoleReturnValue
+	case r.IsSpecialRegister():
+		return RoleSpecial
+	}
+	return RoleInternal
+}
+
 func IsWritable(r string) bool {
 	if v, ok := registersByName[r]; !ok {
 		return false
@@ -116,3 +147,29 @@ func IsTempRegister(r string) bool {
 	}
 
 }
+
+func IsArgRegister(r string) bool {
+	if v, ok := registersByName[r]; !ok {
+		return false
+	} else {
+		return v.IsArgRegister()
+	}
+}
+
+func IsSpecialRegister(r string) bool {
+	if v, ok := registersByName[r]; !ok {
+		return false
+	} else {
+		return v.IsSpecialRegister()
+	}
+}
+
+// Role returns the role category of the register named r, or "" if there
+// is no such register.
+func Role(r string) string {
+	if v, ok := registersByName[r]; !ok {
+		return ""
+	} else {
+		return v.Role()
+	}
+}
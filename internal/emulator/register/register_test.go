@@ -0,0 +1,61 @@
+package register
+
+import "testing"
+
+func TestClassification(t *testing.T) {
+	tests := []struct {
+		reg                Register
+		temp, arg, special bool
+		readable           bool
+		role               string
+	}{
+		{T0, true, false, false, true, RoleTemporary},
+		{T5, true, false, false, true, RoleTemporary},
+		{A0, false, true, false, true, RoleArgument},
+		{A3, false, true, false, true, RoleArgument},
+		{RV, false, false, false, true, RoleReturnValue},
+		{RA, false, false, true, true, RoleSpecial},
+		{FP, false, false, true, true, RoleSpecial},
+		{SP, false, false, true, true, RoleSpecial},
+		{BP, false, false, true, true, RoleSpecial},
+		{PC, false, false, true, true, RoleSpecial},
+		{IR, false, false, false, false, RoleInternal},
+		{FLAGS, false, false, false, false, RoleInternal},
+	}
+	for _, tt := range tests {
+		name := Registers[tt.reg]
+		t.Run(name, func(t *testing.T) {
+			if got := tt.reg.IsTempRegister(); got != tt.temp {
+				t.Errorf("IsTempRegister() = %v, want %v", got, tt.temp)
+			}
+			if got := tt.reg.IsArgRegister(); got != tt.arg {
+				t.Errorf("IsArgRegister() = %v, want %v", got, tt.arg)
+			}
+			if got := tt.reg.IsSpecialRegister(); got != tt.special {
+				t.Errorf("IsSpecialRegister() = %v, want %v", got, tt.special)
+			}
+			if got := tt.reg.IsReadable(); got != tt.readable {
+				t.Errorf("IsReadable() = %v, want %v", got, tt.readable)
+			}
+			if got := tt.reg.Role(); got != tt.role {
+				t.Errorf("Role() = %q, want %q", got, tt.role)
+			}
+
+			// The by-name functions agree with the methods.
+			if IsTempRegister(name) != tt.temp || IsArgRegister(name) != tt.arg || IsSpecialRegister(name) != tt.special || Role(name) != tt.role {
+				t.Errorf("by-name classification of %q differs from the register's", name)
+			}
+		})
+	}
+}
+
+func TestUnknownName(t *testing.T) {
+	for _, name := range []string{"", "R0", "t0", "XYZ"} {
+		if IsTempRegister(name) || IsArgRegister(name) || IsSpecialRegister(name) || IsReadable(name) || IsWritable(name) {
+			t.Errorf("%q is classified as a register", name)
+		}
+		if role := Role(name); role != "" {
+			t.Errorf("Role(%q) = %q, want \"\"", name, role)
+		}
+	}
+}
@@ -0,0 +1,45 @@
+package emulator
+
+import "github.com/catdevman/go-mtmc/internal/emulator/register"
+
+// registerRoles maps each register index to the register of package
+// register's calling convention that does the same job. R0 holds the exit
+// code HALT reports, so it is the return value; R1 to R4 pass arguments; R5
+// to R7, HI and LO are scratch; GP marks the program's data, as the break
+// pointer does; and SR holds the flags.
+var registerRoles = [16]register.Register{
+	R0: register.RV,
+	R1: register.A0, R2: register.A1, R3: register.A2, R4: register.A3,
+	R5: register.T0, R6: register.T1, R7: register.T2,
+	HI: register.T3, LO: register.T4,
+	GP: register.BP, FP: register.FP, SP: register.SP, RA: register.RA, PC: register.PC,
+	SR: register.FLAGS,
+}
+
+// RegisterInfo is one register of the register file as a debugger shows
+// it: by its display name, with its role in the calling convention.
+type RegisterInfo struct {
+	Name  string `json:"name"`
+	Index int    `json:"index"`
+	Value uint16 `json:"value"`
+	// Role is a role category from package register, such as "argument"
+	// or "temporary".
+	Role string `json:"role"`
+}
+
+// RegisterFile describes each register, in index order. Roles belong to the
+// register itself, so an alias set with SetRegisterAlias changes its name
+// but not its role.
+func (c *MonTanaMiniComputer) RegisterFile() []RegisterInfo {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	return c.registerFile()
+}
+
+func (c *MonTanaMiniComputer) registerFile() []RegisterInfo {
+	file := make([]RegisterInfo, len(c.Registers))
+	for i, name := range c.registerNames() {
+		file[i] = RegisterInfo{Name: name, Index: i, Value: c.Registers[i], Role: registerRoles[i].Role()}
+	}
+	return file
+}
@@ -0,0 +1,50 @@
+package emulator
+
+import (
+	"testing"
+
+	"github.com/catdevman/go-mtmc/internal/emulator/register"
+)
+
+func TestRegisterFile(t *testing.T) {
+	c := newTestComputer(t)
+	c.Registers[R3] = 42
+	if err := c.SetRegisterAlias("R1", "A0"); err != nil {
+		t.Fatal(err)
+	}
+	file := c.RegisterFile()
+
+	tests := []struct {
+		index int
+		name  string
+		role  string
+	}{
+		{R0, "R0", register.RoleReturnValue},
+		{R1, "A0", register.RoleArgument}, // aliases rename, but do not change the role
+		{R4, "R4", register.RoleArgument},
+		{R5, "R5", register.RoleTemporary},
+		{R7, "R7", register.RoleTemporary},
+		{GP, "GP", register.RoleSpecial},
+		{FP, "FP", register.RoleSpecial},
+		{SP, "SP", register.RoleSpecial},
+		{RA, "RA", register.RoleSpecial},
+		{HI, "HI", register.RoleTemporary},
+		{LO, "LO", register.RoleTemporary},
+		{PC, "PC", register.RoleSpecial},
+		{SR, "SR", register.RoleInternal},
+	}
+	for _, tt := range tests {
+		info := file[tt.index]
+		if info.Index != tt.index || info.Name != tt.name || info.Role != tt.role {
+			t.Errorf("register %d = %+v, want name %q, role %q", tt.index, info, tt.name, tt.role)
+		}
+	}
+	for _, info := range file {
+		if info.Role == register.RoleInternal && info.Index != SR {
+			t.Errorf("register %s has role %q; only SR is hidden from the calling convention", info.Name, info.Role)
+		}
+	}
+	if file[R3].Value != 42 {
+		t.Errorf("R3 value = %d, want 42", file[R3].Value)
+	}
+}
@@ -0,0 +1,27 @@
+package emulator
+
+import "bytes"
+
+// SearchMemory returns the addresses at which pattern occurs in memory, in
+// ascending order, stopping after limit matches. Matches may overlap and
+// need not be word aligned. truncated reports whether the search stopped
+// early.
+func (c *MonTanaMiniComputer) SearchMemory(pattern []byte, limit int) (addrs []uint16, truncated bool) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	addrs = []uint16{}
+	if len(pattern) == 0 {
+		return addrs, false
+	}
+	for off := 0; ; off++ {
+		i := bytes.Index(c.Memory[off:], pattern)
+		if i < 0 {
+			return addrs, false
+		}
+		if len(addrs) == limit {
+			return addrs, true
+		}
+		off += i
+		addrs = append(addrs, uint16(off))
+	}
+}
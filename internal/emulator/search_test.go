@@ -0,0 +1,43 @@
+package emulator
+
+import (
+	"slices"
+	"testing"
+)
+
+func TestSearchMemory(t *testing.T) {
+	c := newTestComputer(t)
+	for addr, data := range map[uint16][]byte{
+		0x100: {0xBE, 0xEF},
+		0x201: {0xBE, 0xEF}, // not word aligned
+		0x300: {0xAA, 0xAA, 0xAA},
+		0xFFE: {0xBE, 0xEF}, // the last word
+	} {
+		if err := c.WriteMemory(addr, data); err != nil {
+			t.Fatal(err)
+		}
+	}
+	tests := []struct {
+		name      string
+		pattern   []byte
+		limit     int
+		want      []uint16
+		truncated bool
+	}{
+		{"word", []byte{0xBE, 0xEF}, 10, []uint16{0x100, 0x201, 0xFFE}, false},
+		{"byte", []byte{0xEF}, 10, []uint16{0x101, 0x202, 0xFFF}, false},
+		{"overlapping", []byte{0xAA, 0xAA}, 10, []uint16{0x300, 0x301}, false},
+		{"limited", []byte{0xBE, 0xEF}, 2, []uint16{0x100, 0x201}, true},
+		{"exactly the limit", []byte{0xBE, 0xEF}, 3, []uint16{0x100, 0x201, 0xFFE}, false},
+		{"absent", []byte{0x12, 0x34}, 10, []uint16{}, false},
+		{"empty pattern", nil, 10, []uint16{}, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, truncated := c.SearchMemory(tt.pattern, tt.limit)
+			if !slices.Equal(got, tt.want) || truncated != tt.truncated {
+				t.Errorf("SearchMemory(% X) = %X, %v; want %X, %v", tt.pattern, got, truncated, tt.want, tt.truncated)
+			}
+		})
+	}
+}
@@ -0,0 +1,99 @@
+package emulator
+
+// HaltSelfModifying is the halt reason when a program writes into its own
+// code and WithSelfModifyingFault is set.
+const HaltSelfModifying HaltReason = "self-modifying-code"
+
+// maxSelfModifyingWrites caps how many self-modifying writes are kept.
+const maxSelfModifyingWrites = 32
+
+// SelfModifyingWrite records a program storing into the loaded program's
+// code, which is worth pointing out to students whether it was deliberate or
+// a stray pointer.
+type SelfModifyingWrite struct {
+	Address uint16 `json:"address"` // the address written
+	PC      uint16 `json:"pc"`      // the instruction that wrote it
+}
+
+// selfModifying tracks writes into the loaded program.
+type selfModifying struct {
+	fatal  bool
+	writes []SelfModifyingWrite // most recent last
+}
+
+// WithSelfModifyingFault makes a write into the loaded program's code fault with
+// HaltSelfModifying instead of only being recorded.
+func WithSelfModifyingFault() Option {
+	return func(c *MonTanaMiniComputer) {
+		c.selfModifying.fatal = true
+	}
+}
+
+// SelfModifyingWrites returns the most recent writes the program made into
+// its own code since it was loaded, oldest first. Code is what the loaded
+// image's Code ranges cover, so stores to the program's own variables are
+// not flagged; for an image without them, such as a raw binary, all of its
+// segments count as code.
+func (c *MonTanaMiniComputer) SelfModifyingWrites() []SelfModifyingWrite {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	return c.selfModifying.list()
+}
+
+// list returns the recorded writes, never nil so it encodes as an empty
+// JSON array.
+func (s *selfModifying) list() []SelfModifyingWrite {
+	return append([]SelfModifyingWrite{}, s.writes...)
+}
+
+// checkSelfModifying records a write of the word at addr if it lies in the
+// loaded program's code, returning HaltSelfModifying if such writes are fatal.
+func (c *MonTanaMiniComputer) checkSelfModifying(addr uint16) HaltReason {
+	if !c.inCode(int(addr), int(addr)+WordSize) {
+		return HaltNone
+	}
+	c.selfModifying.record(addr, c.executing)
+	if c.selfModifying.fatal {
+		return HaltSelfModifying
+	}
+	c.logger.Debug("self-modifying write", "address", addr, "pc", c.executing)
+	return HaltNone
+}
+
+// selfModifyingFault reports whether writing memory from start up to, but
+// not including, end would fault as self-modifying, for instructions that
+// write several words and must write all or none of them.
+func (c *MonTanaMiniComputer) selfModifyingFault(start, end int) bool {
+	if c.selfModifying.fatal && c.inCode(start, end) {
+		c.selfModifying.record(uint16(start), c.executing)
+		return true
+	}
+	return false
+}
+
+// record remembers a write of addr by the instruction at pc.
+func (s *selfModifying) record(addr, pc uint16) {
+	if len(s.writes) == maxSelfModifyingWrites {
+		s.writes = append(s.writes[:0], s.writes[1:]...)
+	}
+	s.writes = append(s.writes, SelfModifyingWrite{Address: addr, PC: pc})
+}
+
+// inCode reports whether any address from start up to, but not including,
+// end lies in the loaded program's code.
+func (c *MonTanaMiniComputer) inCode(start, end int) bool {
+	if code := c.loaded.image.Code; code != nil {
+		for _, r := range code {
+			if start <= int(r.End) && end > int(r.Start) {
+				return true
+			}
+		}
+		return false
+	}
+	for _, seg := range c.loaded.image.Segments {
+		if start < int(seg.Address)+len(seg.Data) && end > int(seg.Address) {
+			return true
+		}
+	}
+	return false
+}
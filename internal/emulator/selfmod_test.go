@@ -0,0 +1,61 @@
+package emulator
+
+import "testing"
+
+func TestSelfModifyingWrites(t *testing.T) {
+	const data = 0x100
+	// The code stores R1 to the address in R0, which each test sets.
+	code := func(t *testing.T, addr uint16) Image {
+		t.Helper()
+		return Image{
+			Segments: []Segment{
+				{Address: 0, Data: program(t, liw(R0, addr), ri(OpSW, R1, 0), halt)},
+				{Address: data, Data: []byte{0, 0}},
+			},
+			Code: []CodeRange{{Start: 0, End: 7}},
+		}
+	}
+	tests := []struct {
+		name    string
+		img     func(t *testing.T) Image
+		flagged bool
+	}{
+		{"write into code", func(t *testing.T) Image { return code(t, 6) }, true},
+		{"write into data", func(t *testing.T) Image { return code(t, data) }, false},
+		{"write outside the program", func(t *testing.T) Image { return code(t, 0x200) }, false},
+		{"image without code ranges", func(t *testing.T) Image {
+			img := code(t, data)
+			img.Code = nil
+			return img
+		}, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			for _, fatal := range []bool{false, true} {
+				var opts []Option
+				if fatal {
+					opts = append(opts, WithSelfModifyingFault())
+				}
+				c := newTestComputer(t, opts...)
+				if err := c.LoadImage("test", tt.img(t)); err != nil {
+					t.Fatal(err)
+				}
+				stepN(c, 2)
+				writes := c.SelfModifyingWrites()
+				if got := len(writes) == 1; got != tt.flagged {
+					t.Fatalf("fatal=%v: writes = %v, want flagged %v", fatal, writes, tt.flagged)
+				}
+				if tt.flagged && writes[0].PC != 4 {
+					t.Errorf("fatal=%v: write PC = 0x%04X, want 0x0004", fatal, writes[0].PC)
+				}
+				wantHalt := HaltNone
+				if fatal && tt.flagged {
+					wantHalt = HaltSelfModifying
+				}
+				if got := c.GetState()["haltReason"]; got != wantHalt {
+					t.Errorf("fatal=%v: halt reason = %v, want %v", fatal, got, wantHalt)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,76 @@
+package emulator
+
+import (
+	"encoding/json"
+	"fmt"
+)
+
+// SnapshotDiff describes how two snapshots differ: what changed between the
+// first, a, and the second, b.
+type SnapshotDiff struct {
+	Registers []RegisterChange `json:"registers"`
+	Memory    []MemoryRange    `json:"memory"`
+	// Steps is how many more instructions b had executed than a, negative
+	// if b was taken earlier.
+	Steps      int64      `json:"steps"`
+	HaltReason HaltReason `json:"haltReason,omitempty"` // b's halt reason, if it differs from a's
+}
+
+// MemoryRange is a run of consecutive bytes that differ between two
+// snapshots, with their contents in each.
+type MemoryRange struct {
+	Start uint16   `json:"start"`
+	Old   HexBytes `json:"old"`
+	New   HexBytes `json:"new"`
+}
+
+// DiffSnapshots compares two snapshots encoded as JSON, such as those the
+// server lists at /snapshots, and reports what changed from a to b.
+// Both must pass the same checks as Restore and have the same amount of
+// memory.
+func DiffSnapshots(a, b []byte) (SnapshotDiff, error) {
+	var sa, sb Snapshot
+	if err := decodeSnapshot(a, &sa); err != nil {
+		return SnapshotDiff{}, fmt.Errorf("first snapshot: %w", err)
+	}
+	if err := decodeSnapshot(b, &sb); err != nil {
+		return SnapshotDiff{}, fmt.Errorf("second snapshot: %w", err)
+	}
+	if len(sa.Memory) != len(sb.Memory) {
+		return SnapshotDiff{}, fmt.Errorf("snapshots have %d and %d bytes of memory", len(sa.Memory), len(sb.Memory))
+	}
+
+	diff := SnapshotDiff{
+		Registers: []RegisterChange{},
+		Memory:    []MemoryRange{},
+		Steps:     int64(sb.InstructionCount - sa.InstructionCount),
+	}
+	for i, old := range sa.Registers {
+		if sb.Registers[i] != old {
+			diff.Registers = append(diff.Registers, RegisterChange{RegisterNames[i], i, old, sb.Registers[i]})
+		}
+	}
+	for i := 0; i < len(sa.Memory); {
+		if sa.Memory[i] == sb.Memory[i] {
+			i++
+			continue
+		}
+		start := i
+		for i < len(sa.Memory) && sa.Memory[i] != sb.Memory[i] {
+			i++
+		}
+		diff.Memory = append(diff.Memory, MemoryRange{uint16(start), sa.Memory[start:i], sb.Memory[start:i]})
+	}
+	if sa.HaltReason != sb.HaltReason {
+		diff.HaltReason = sb.HaltReason
+	}
+	return diff, nil
+}
+
+// decodeSnapshot decodes the JSON snapshot in data into s and verifies it.
+func decodeSnapshot(data []byte, s *Snapshot) error {
+	if err := json.Unmarshal(data, s); err != nil {
+		return err
+	}
+	return s.verify()
+}
@@ -0,0 +1,80 @@
+package emulator
+
+import (
+	"encoding/json"
+	"errors"
+	"reflect"
+	"testing"
+)
+
+func TestDiffSnapshots(t *testing.T) {
+	// encode returns base, changed by change, as JSON. It is resealed unless
+	// change set the checksum itself.
+	base := newTestComputer(t).Snapshot()
+	encode := func(t *testing.T, change func(*Snapshot)) []byte {
+		t.Helper()
+		s := base
+		s.Memory = append([]byte(nil), base.Memory...)
+		if change != nil {
+			change(&s)
+			if s.Checksum == base.Checksum {
+				s.Checksum = s.checksum()
+			}
+		}
+		data, err := json.Marshal(s)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return data
+	}
+	oneOfEach := func(s *Snapshot) {
+		s.Registers[R3] = 0x1234
+		s.Memory[0x40], s.Memory[0x41] = 0xBE, 0xEF
+		s.InstructionCount += 5
+	}
+	tests := []struct {
+		name string
+		a, b func(*Snapshot)
+		want SnapshotDiff
+		err  error // nil unless a particular error is wanted
+		fail bool
+	}{
+		{"identical", nil, nil, SnapshotDiff{Registers: []RegisterChange{}, Memory: []MemoryRange{}}, nil, false},
+		{"one register and one memory word", nil, oneOfEach, SnapshotDiff{
+			Registers: []RegisterChange{{"R3", 3, 0, 0x1234}},
+			Memory:    []MemoryRange{{0x40, HexBytes{0, 0}, HexBytes{0xBE, 0xEF}}},
+			Steps:     5,
+		}, nil, false},
+		{"the other way round", oneOfEach, nil, SnapshotDiff{
+			Registers: []RegisterChange{{"R3", 3, 0x1234, 0}},
+			Memory:    []MemoryRange{{0x40, HexBytes{0xBE, 0xEF}, HexBytes{0, 0}}},
+			Steps:     -5,
+		}, nil, false},
+		{"separate ranges", nil, func(s *Snapshot) {
+			s.Memory[0], s.Memory[1], s.Memory[3] = 1, 2, 3
+			s.HaltReason = HaltInstruction
+		}, SnapshotDiff{
+			Registers:  []RegisterChange{},
+			Memory:     []MemoryRange{{0, HexBytes{0, 0}, HexBytes{1, 2}}, {3, HexBytes{0}, HexBytes{3}}},
+			HaltReason: HaltInstruction,
+		}, nil, false},
+		{"tampered", nil, func(s *Snapshot) { s.Registers[R1], s.Checksum = 1, s.Checksum+1 }, SnapshotDiff{}, ErrSnapshotChecksum, true},
+		{"wrong version", func(s *Snapshot) { s.Version++ }, nil, SnapshotDiff{}, ErrSnapshotVersion, true},
+		{"different memory sizes", nil, func(s *Snapshot) { s.Memory = s.Memory[:0x100] }, SnapshotDiff{}, nil, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			a, b := encode(t, tt.a), encode(t, tt.b)
+			got, err := DiffSnapshots(a, b)
+			if (err != nil) != tt.fail || (tt.err != nil && !errors.Is(err, tt.err)) {
+				t.Fatalf("DiffSnapshots() error = %v, want %v (fails %v)", err, tt.err, tt.fail)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("DiffSnapshots() = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+	if _, err := DiffSnapshots([]byte("{"), encode(t, nil)); err == nil {
+		t.Error("DiffSnapshots accepted invalid JSON")
+	}
+}
@@ -0,0 +1,163 @@
+package emulator
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"hash/crc32"
+)
+
+// SnapshotVersion is the version of the Snapshot format this package writes
+// and restores. It changes whenever the fields or their meaning do.
+const SnapshotVersion = 2
+
+// Errors Restore returns, wrapped, for snapshots it will not restore.
+var (
+	ErrSnapshotVersion  = errors.New("unsupported snapshot version")
+	ErrSnapshotChecksum = errors.New("snapshot checksum mismatch")
+)
+
+// Snapshot is a copy of the machine's architectural state: everything a
+// program can observe, including input it has yet to read, the output it
+// has written and where RAND is in its sequence. Restoring one puts the
+// program back exactly where it was. Snapshots carry a format version and a
+// checksum of their contents, so a snapshot saved to a file and corrupted or
+// truncated there is rejected rather than restored.
+type Snapshot struct {
+	Version          int        `json:"version"`
+	Registers        [16]uint16 `json:"registers"`
+	Memory           []byte     `json:"memory"`
+	InstructionCount uint64     `json:"instructionCount"`
+	HaltReason       HaltReason `json:"haltReason"`
+	Input            []byte     `json:"input"`
+	Output           []byte     `json:"output"`
+	ExitCode         uint16     `json:"exitCode"`
+	// RNG is the RAND generator's state, empty if the program had not yet
+	// drawn a number.
+	RNG      []byte `json:"rng,omitempty"`
+	Checksum uint32 `json:"checksum"`
+}
+
+// checksum returns the CRC-32 of every field of s but Checksum. Variable
+// length fields after Memory are preceded by their length, so bytes cannot
+// move from one to the next without changing the sum.
+func (s *Snapshot) checksum() uint32 {
+	h := crc32.NewIEEE()
+	binary.Write(h, binary.BigEndian, int64(s.Version))
+	binary.Write(h, binary.BigEndian, s.Registers)
+	h.Write(s.Memory)
+	binary.Write(h, binary.BigEndian, s.InstructionCount)
+	h.Write([]byte(s.HaltReason))
+	for _, field := range [][]byte{s.Input, s.Output, s.RNG} {
+		binary.Write(h, binary.BigEndian, uint32(len(field)))
+		h.Write(field)
+	}
+	binary.Write(h, binary.BigEndian, s.ExitCode)
+	return h.Sum32()
+}
+
+// verify checks that s is of this package's format version and matches its
+// checksum.
+func (s *Snapshot) verify() error {
+	if s.Version != SnapshotVersion {
+		return fmt.Errorf("%w: snapshot is version %d, this machine reads version %d", ErrSnapshotVersion, s.Version, SnapshotVersion)
+	}
+	if sum := s.checksum(); sum != s.Checksum {
+		return fmt.Errorf("%w: snapshot says 0x%08X, contents give 0x%08X", ErrSnapshotChecksum, s.Checksum, sum)
+	}
+	return nil
+}
+
+// Snapshot captures the machine's current state.
+func (c *MonTanaMiniComputer) Snapshot() Snapshot {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	return c.snapshot()
+}
+
+func (c *MonTanaMiniComputer) snapshot() Snapshot {
+	s := Snapshot{
+		Version:          SnapshotVersion,
+		Registers:        c.Registers,
+		Memory:           append([]byte(nil), c.Memory...),
+		InstructionCount: c.instructionCount,
+		HaltReason:       c.haltReason,
+		Input:            append([]byte(nil), c.input...),
+		Output:           append([]byte(nil), c.output...),
+		ExitCode:         c.exitCode,
+		RNG:              c.rng.state().encode(),
+	}
+	s.Checksum = s.checksum()
+	return s
+}
+
+// Restore returns the machine to the state in s and pauses it. The step
+// history, trace, last fault and step delta are discarded, since they
+// describe how the machine reached a different state. It fails, leaving the
+// machine untouched, if s is of another format version, fails its checksum
+// or was taken from a machine with a different amount of memory.
+func (c *MonTanaMiniComputer) Restore(s Snapshot) error {
+	if err := s.verify(); err != nil {
+		return err
+	}
+	rng, err := decodeRNGState(s.RNG)
+	if err != nil {
+		return fmt.Errorf("snapshot RAND state: %w", err)
+	}
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	if len(s.Memory) != len(c.Memory) {
+		return fmt.Errorf("snapshot has %d bytes of memory, machine has %d", len(s.Memory), len(c.Memory))
+	}
+	copy(c.Memory, s.Memory)
+	c.Registers = s.Registers
+	c.instructionCount = s.InstructionCount
+	c.haltReason = s.HaltReason
+	c.input = append([]byte(nil), s.Input...)
+	c.output = append([]byte(nil), s.Output...)
+	c.exitCode = s.ExitCode
+	c.rng.restore(rng)
+	c.Running = false
+	c.lastFault = nil
+	c.history.clear()
+	c.trace.clear()
+	c.delta.last = nil
+	c.breakpoints.resumeFrom(c.Registers[PC])
+	c.notifyObservers()
+	return nil
+}
+
+// BreakpointSnapshot is the state captured when Run paused at a breakpoint.
+type BreakpointSnapshot struct {
+	Address  uint16   `json:"address"`
+	Snapshot Snapshot `json:"snapshot"`
+}
+
+// WithBreakpointSnapshots captures a Snapshot every time Run pauses at a
+// breakpoint, keeping the most recent limit of them, so the state at a known
+// point can be compared across runs. They survive Reset, like breakpoints.
+func WithBreakpointSnapshots(limit int) Option {
+	return func(c *MonTanaMiniComputer) {
+		c.breakpoints.snapshotLimit = limit
+	}
+}
+
+// BreakpointSnapshots returns the snapshots captured at breakpoints, oldest
+// first.
+func (c *MonTanaMiniComputer) BreakpointSnapshots() []BreakpointSnapshot {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	return append([]BreakpointSnapshot{}, c.breakpoints.snapshots...)
+}
+
+// captureBreakpoint records a snapshot at the breakpoint at pc, if enabled.
+func (c *MonTanaMiniComputer) captureBreakpoint(pc uint16) {
+	b := &c.breakpoints
+	if b.snapshotLimit <= 0 {
+		return
+	}
+	if len(b.snapshots) >= b.snapshotLimit {
+		b.snapshots = append(b.snapshots[:0], b.snapshots[len(b.snapshots)-b.snapshotLimit+1:]...)
+	}
+	b.snapshots = append(b.snapshots, BreakpointSnapshot{Address: pc, Snapshot: c.snapshot()})
+}
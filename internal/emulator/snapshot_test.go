@@ -0,0 +1,186 @@
+package emulator
+
+import (
+	"encoding/json"
+	"errors"
+	"testing"
+	"time"
+)
+
+// waitPaused waits for Run to pause the machine.
+func waitPaused(t *testing.T, c *MonTanaMiniComputer) {
+	t.Helper()
+	deadline := time.Now().Add(5 * time.Second)
+	for c.IsRunning() {
+		if time.Now().After(deadline) {
+			t.Fatal("machine never paused")
+		}
+		time.Sleep(time.Millisecond)
+	}
+}
+
+func TestBreakpointSnapshots(t *testing.T) {
+	ticks := make(chan time.Time)
+	c := newTestComputer(t, WithClock(ticks), WithBreakpointSnapshots(2))
+	load(t, c, program(t, ri(OpADDI, R1, 0x11), ri(OpBZ, 0, -2))) // R1 += 0x11 forever
+	if err := c.AddBreakpoint(2); err != nil {
+		t.Fatal(err)
+	}
+	stop := runClocked(c, ticks)
+	defer stop()
+
+	if got := c.BreakpointSnapshots(); len(got) != 0 {
+		t.Fatalf("%d snapshots before any breakpoint, want 0", len(got))
+	}
+	c.SetRunning(true)
+	for hit := 1; hit <= 3; hit++ {
+		waitPaused(t, c)
+		snapshots := c.BreakpointSnapshots()
+		latest := snapshots[len(snapshots)-1]
+		if latest.Address != 2 || latest.Snapshot.Registers[R1] != uint16(0x11*hit) || latest.Snapshot.Registers[PC] != 2 {
+			t.Errorf("hit %d: snapshot at 0x%04X with R1 0x%02X, PC %d; want 0x0002, 0x%02X, 2",
+				hit, latest.Address, latest.Snapshot.Registers[R1], latest.Snapshot.Registers[PC], 0x11*hit)
+		}
+		if latest.Snapshot.HaltReason != HaltBreakpoint {
+			t.Errorf("hit %d: snapshot halt reason %q, want %q", hit, latest.Snapshot.HaltReason, HaltBreakpoint)
+		}
+		if hit < 3 {
+			c.Continue()
+		}
+	}
+
+	// Only the newest two are kept.
+	snapshots := c.BreakpointSnapshots()
+	if len(snapshots) != 2 || snapshots[0].Snapshot.Registers[R1] != 0x22 || snapshots[1].Snapshot.Registers[R1] != 0x33 {
+		t.Errorf("kept %d snapshots %+v, want those of hits 2 and 3", len(snapshots), snapshots)
+	}
+	// Stepping onto a breakpoint does not capture one.
+	c.Reset()
+	if err := c.AddBreakpoint(2); err != nil {
+		t.Fatal(err)
+	}
+	load(t, c, program(t, ri(OpADDI, R1, 0x11), ri(OpBZ, 0, -2)))
+	stepN(c, 1)
+	if got := c.BreakpointSnapshots(); len(got) != 2 {
+		t.Errorf("%d snapshots after stepping onto the breakpoint, want still 2", len(got))
+	}
+}
+
+func TestRestore(t *testing.T) {
+	tests := []struct {
+		name   string
+		tamper func(*Snapshot)
+		fails  bool
+		err    error // the error a failure wraps, if one in particular
+	}{
+		{"good", nil, false, nil},
+		{"register changed", func(s *Snapshot) { s.Registers[R1]++ }, true, ErrSnapshotChecksum},
+		{"memory changed", func(s *Snapshot) { s.Memory[0x40] ^= 0xFF }, true, ErrSnapshotChecksum},
+		{"halt reason changed", func(s *Snapshot) { s.HaltReason = HaltNone }, true, ErrSnapshotChecksum},
+		{"input changed", func(s *Snapshot) { s.Input[0]++ }, true, ErrSnapshotChecksum},
+		{"output changed", func(s *Snapshot) { s.Output = nil }, true, ErrSnapshotChecksum},
+		{"input moved to output", func(s *Snapshot) {
+			s.Output, s.Input = append(s.Output, s.Input[0]), s.Input[1:]
+		}, true, ErrSnapshotChecksum},
+		{"exit code changed", func(s *Snapshot) { s.ExitCode++ }, true, ErrSnapshotChecksum},
+		{"RAND state changed", func(s *Snapshot) { s.RNG[0]++ }, true, ErrSnapshotChecksum},
+		{"RAND state resealed corrupt", func(s *Snapshot) {
+			s.RNG = s.RNG[:3]
+			s.Checksum = s.checksum()
+		}, true, nil},
+		{"checksum changed", func(s *Snapshot) { s.Checksum++ }, true, ErrSnapshotChecksum},
+		{"truncated", func(s *Snapshot) { s.Memory = s.Memory[:len(s.Memory)/2] }, true, ErrSnapshotChecksum},
+		{"older version", func(s *Snapshot) { s.Version = SnapshotVersion - 1 }, true, ErrSnapshotVersion},
+		{"newer version", func(s *Snapshot) { s.Version = SnapshotVersion + 1 }, true, ErrSnapshotVersion},
+		{"resealed at another size", func(s *Snapshot) {
+			s.Memory = s.Memory[:len(s.Memory)/2]
+			s.Checksum = s.checksum()
+		}, true, nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := newTestComputer(t)
+			load(t, c, program(t, ext(ExtIN, R2, 0), ext(ExtOUT, R2, 0), ext(ExtRAND, R3, 0),
+				liw(R1, 0x1234), ri(OpSW, R1, 0x40), liw(R0, 7), halt))
+			c.WriteInput([]byte("abc"))
+			c.RunToCompletion()
+			saved := c.Snapshot()
+
+			// Snapshots are saved to files as JSON, so tamper with one that
+			// has been through that.
+			data, err := json.Marshal(saved)
+			if err != nil {
+				t.Fatal(err)
+			}
+			var s Snapshot
+			if err := json.Unmarshal(data, &s); err != nil {
+				t.Fatal(err)
+			}
+			if tt.tamper != nil {
+				tt.tamper(&s)
+			}
+
+			c.Reset()
+			err = c.Restore(s)
+			if (err != nil) != tt.fails || (tt.err != nil && !errors.Is(err, tt.err)) {
+				t.Fatalf("Restore() error = %v, want %v (fails %v)", err, tt.err, tt.fails)
+			}
+			got := c.Snapshot()
+			if tt.fails {
+				if got.Registers[R1] != 0 || got.Memory[0x40] != 0 {
+					t.Errorf("a rejected snapshot changed the machine: R1 0x%04X, memory at 0x40 0x%02X", got.Registers[R1], got.Memory[0x40])
+				}
+				return
+			}
+			if got.Checksum != saved.Checksum || got.Registers != saved.Registers {
+				t.Errorf("restored to %+v, want %+v", got.Registers, saved.Registers)
+			}
+			if string(got.Input) != "bc" || string(got.Output) != "a" || got.ExitCode != 7 || c.ExitCode() != 7 {
+				t.Errorf("restored input %q, output %q, exit code %d; want \"bc\", \"a\", 7", got.Input, got.Output, got.ExitCode)
+			}
+		})
+	}
+}
+
+func TestRestoreResumes(t *testing.T) {
+	c := newTestComputer(t, WithTrace(8))
+	load(t, c, program(t, ext(ExtIN, R2, 0), ext(ExtOUT, R2, 0), ext(ExtRAND, R3, 0),
+		ext(ExtIN, R4, 0), ext(ExtOUT, R4, 0), ext(ExtRAND, R5, 0), liw(R0, 7), halt))
+	c.WriteInput([]byte("xyz"))
+	stepN(c, 3)
+	saved := c.Snapshot()
+
+	c.RunToCompletion()
+	want := c.Snapshot()
+	// Fault, so there is a fault, trace and delta for Restore to discard.
+	c.Registers[PC] = testMemorySize - 1
+	c.StepState()
+	if c.LastFault() == nil {
+		t.Fatal("stepping past the end of memory did not fault")
+	}
+
+	if err := c.Restore(saved); err != nil {
+		t.Fatal(err)
+	}
+	if f := c.LastFault(); f != nil {
+		t.Errorf("LastFault() = %+v after Restore, want nil", f)
+	}
+	if batch := c.Trace(0, 8); len(batch.Entries) != 0 {
+		t.Errorf("trace has %d entries after Restore, want none", len(batch.Entries))
+	}
+	if d := c.GetState()["delta"].(*StepDelta); d != nil {
+		t.Errorf("state delta = %+v after Restore, want none", d)
+	}
+
+	// Finishing the run again reads the same input and draws the same
+	// random numbers, so it ends in the same state.
+	c.RunToCompletion()
+	got := c.Snapshot()
+	if got.Registers != want.Registers || string(got.Output) != "xy" || string(got.Input) != "z" || got.ExitCode != 7 {
+		t.Errorf("resumed run ended with registers %v, output %q, input %q, exit %d; want %v, \"xy\", \"z\", 7",
+			got.Registers, got.Output, got.Input, got.ExitCode, want.Registers)
+	}
+	if got.Checksum != want.Checksum {
+		t.Errorf("resumed run checksum 0x%08X, want 0x%08X", got.Checksum, want.Checksum)
+	}
+}
@@ -0,0 +1,53 @@
+package emulator
+
+import "time"
+
+// Run measures throughput by sampling the instruction counter at most every
+// throughputInterval and averaging over the last throughputSamples samples,
+// about a second, which smooths out the jitter of individual ticks.
+const (
+	throughputInterval = 100 * time.Millisecond
+	throughputSamples  = 11
+)
+
+// throughput computes a rolling instructions-per-second figure.
+type throughput struct {
+	samples []throughputSample // oldest first
+	ips     float64
+}
+
+type throughputSample struct {
+	at    time.Time
+	count uint64
+}
+
+// record samples the instruction count at now and updates ips.
+func (t *throughput) record(now time.Time, count uint64) {
+	if n := len(t.samples); n > 0 {
+		last := t.samples[n-1]
+		if count < last.count {
+			// The counter was reset by a load or reset, so earlier samples
+			// no longer compare.
+			t.samples = t.samples[:0]
+		} else if now.Sub(last.at) < throughputInterval {
+			return
+		}
+	}
+	if len(t.samples) == throughputSamples {
+		t.samples = append(t.samples[:0], t.samples[1:]...)
+	}
+	t.samples = append(t.samples, throughputSample{now, count})
+	first, last := t.samples[0], t.samples[len(t.samples)-1]
+	t.ips = 0
+	if elapsed := last.at.Sub(first.at).Seconds(); elapsed > 0 {
+		t.ips = float64(last.count-first.count) / elapsed
+	}
+}
+
+// IPS returns the rate at which Run has been executing instructions over
+// roughly the last second, in instructions per second.
+func (c *MonTanaMiniComputer) IPS() float64 {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	return c.throughput.ips
+}
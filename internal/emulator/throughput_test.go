@@ -0,0 +1,51 @@
+package emulator
+
+import (
+	"math"
+	"testing"
+	"time"
+)
+
+// TestIPS runs the machine for two simulated seconds with a clock whose tick
+// times the test chooses, and checks the rate it reports.
+func TestIPS(t *testing.T) {
+	tests := []struct {
+		name    string
+		period  time.Duration
+		perTick int
+		running bool
+		want    float64
+	}{
+		{"1kHz", time.Millisecond, 1, true, 1000},
+		{"turbo", time.Millisecond, 4, true, 4000},
+		{"100Hz", 10 * time.Millisecond, 1, true, 100},
+		{"paused", time.Millisecond, 1, false, 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ticks := make(chan time.Time)
+			c := newTestComputer(t, WithClock(ticks), WithStepsPerTick(tt.perTick))
+			load(t, c, program(t, ri(OpBZ, 0, -1))) // branches to itself forever
+			c.SetRunning(tt.running)
+			done := make(chan struct{})
+			go func() {
+				c.Run()
+				close(done)
+			}()
+			start := time.Unix(0, 0)
+			for i := range int(2 * time.Second / tt.period) {
+				ticks <- start.Add(time.Duration(i) * tt.period)
+			}
+			close(ticks)
+			<-done
+
+			got := c.IPS()
+			if math.Abs(got-tt.want) > tt.want/100 {
+				t.Errorf("IPS = %.1f, want %.0f", got, tt.want)
+			}
+			if state := c.GetState()["ips"]; state != got {
+				t.Errorf("state ips = %v, want %v", state, got)
+			}
+		})
+	}
+}
@@ -0,0 +1,82 @@
+package emulator
+
+// trace is a bounded log of the most recently executed instructions, for
+// debuggers that show execution as it happens. Entries are numbered from 0
+// in the order they were recorded, and the numbering carries on across
+// resets, so readers can ask for what is new since the last entry they saw.
+type trace struct {
+	entries []traceEntry // ring buffer, indexed by seq modulo its length; nil when tracing is disabled
+	start   uint64       // seq of the oldest entry kept
+	next    uint64       // seq the next entry will get
+}
+
+type traceEntry struct {
+	pc  uint16
+	ins Instruction
+}
+
+// TraceEntry is one executed instruction in the trace.
+type TraceEntry struct {
+	Seq         uint64 `json:"seq"`
+	PC          uint16 `json:"pc"`
+	Instruction string `json:"instruction"` // its disassembly
+}
+
+// TraceBatch is a run of consecutive trace entries, as Trace returns it.
+type TraceBatch struct {
+	Entries []TraceEntry `json:"entries"`
+	// Dropped is how many entries from the requested position onward are
+	// no longer kept, because newer entries replaced them or the machine
+	// was reset, restarted or loaded since, and so are missing before
+	// Entries.
+	Dropped uint64 `json:"dropped"`
+	// Next is the position to ask for to continue after this batch.
+	Next uint64 `json:"next"`
+}
+
+// WithTrace records the last size executed instructions for Trace to
+// report. Tracing is disabled by default.
+func WithTrace(size int) Option {
+	return func(c *MonTanaMiniComputer) {
+		if size > 0 {
+			c.trace = trace{entries: make([]traceEntry, size)}
+		}
+	}
+}
+
+// Trace returns up to limit trace entries starting at position since,
+// oldest first. Positions past the newest entry return no entries, with
+// Next set to where the next recorded entry will be, so a reader can start
+// from there to see only new instructions.
+func (c *MonTanaMiniComputer) Trace(since uint64, limit int) TraceBatch {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	t := &c.trace
+	since = min(since, t.next)
+	from := max(since, t.start)
+	end := min(t.next, from+uint64(max(limit, 0)))
+	batch := TraceBatch{Entries: make([]TraceEntry, 0, end-from), Dropped: from - since, Next: end}
+	names := c.registerNames()
+	for seq := from; seq < end; seq++ {
+		e := t.entries[seq%uint64(len(t.entries))]
+		batch.Entries = append(batch.Entries, TraceEntry{Seq: seq, PC: e.pc, Instruction: e.ins.disassemble(e.pc, names)})
+	}
+	return batch
+}
+
+// record appends the instruction at pc to the trace, if enabled.
+func (t *trace) record(pc uint16, ins Instruction) {
+	if t.entries == nil {
+		return
+	}
+	t.entries[t.next%uint64(len(t.entries))] = traceEntry{pc, ins}
+	t.next++
+	if t.next-t.start > uint64(len(t.entries)) {
+		t.start = t.next - uint64(len(t.entries))
+	}
+}
+
+// clear drops every entry, without reusing their positions.
+func (t *trace) clear() {
+	t.start = t.next
+}
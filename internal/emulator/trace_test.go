@@ -0,0 +1,89 @@
+package emulator
+
+import (
+	"math"
+	"testing"
+)
+
+func TestTrace(t *testing.T) {
+	// Four instructions, then a branch back to the last one forever.
+	code := program(t, liw(R1, 1), ri(OpADDI, R2, 2), nop, ri(OpBZ, 0, -1))
+
+	tests := []struct {
+		name    string
+		size    int
+		steps   int
+		since   uint64
+		limit   int
+		seqs    []uint64
+		pcs     []uint16
+		dropped uint64
+		next    uint64
+	}{
+		{"all", 8, 3, 0, 10, []uint64{0, 1, 2}, []uint16{0, 4, 6}, 0, 3},
+		{"limit", 8, 3, 0, 2, []uint64{0, 1}, []uint16{0, 4}, 0, 2},
+		{"since", 8, 3, 1, 10, []uint64{1, 2}, []uint16{4, 6}, 0, 3},
+		{"caught up", 8, 3, 3, 10, nil, nil, 0, 3},
+		{"past newest", 8, 3, math.MaxUint64, 10, nil, nil, 0, 3},
+		{"wrapped", 2, 5, 0, 10, []uint64{3, 4}, []uint16{8, 8}, 3, 5},
+		{"disabled", 0, 3, 0, 10, nil, nil, 0, 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := newTestComputer(t, WithTrace(tt.size))
+			load(t, c, code)
+			stepN(c, tt.steps)
+			batch := c.Trace(tt.since, tt.limit)
+			if len(batch.Entries) != len(tt.seqs) {
+				t.Fatalf("got %d entries, want %d: %+v", len(batch.Entries), len(tt.seqs), batch.Entries)
+			}
+			for i, e := range batch.Entries {
+				if e.Seq != tt.seqs[i] || e.PC != tt.pcs[i] {
+					t.Errorf("entry %d = seq %d at 0x%04X, want seq %d at 0x%04X", i, e.Seq, e.PC, tt.seqs[i], tt.pcs[i])
+				}
+				if e.Instruction == "" {
+					t.Errorf("entry %d has no disassembly", i)
+				}
+			}
+			if batch.Dropped != tt.dropped || batch.Next != tt.next {
+				t.Errorf("Dropped, Next = %d, %d; want %d, %d", batch.Dropped, batch.Next, tt.dropped, tt.next)
+			}
+		})
+	}
+}
+
+// TestTraceCleared checks that starting the program over drops the trace
+// without reusing positions, so readers see the gap.
+func TestTraceCleared(t *testing.T) {
+	code := program(t, nop, nop, nop, ri(OpBZ, 0, -1))
+	tests := []struct {
+		name  string
+		clear func(*MonTanaMiniComputer) error
+	}{
+		{"reset", func(c *MonTanaMiniComputer) error { c.Reset(); return nil }},
+		{"restart", func(c *MonTanaMiniComputer) error { return c.Restart() }},
+		{"reload", func(c *MonTanaMiniComputer) error { return c.ReloadProgram(nil) }},
+		{"load", func(c *MonTanaMiniComputer) error { return c.LoadProgram(code, 0) }},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := newTestComputer(t, WithTrace(8))
+			load(t, c, code)
+			stepN(c, 3)
+			if err := tt.clear(c); err != nil {
+				t.Fatal(err)
+			}
+			if batch := c.Trace(0, 10); len(batch.Entries) != 0 || batch.Dropped != 3 || batch.Next != 3 {
+				t.Fatalf("after %s: %d entries, Dropped %d, Next %d; want 0, 3, 3", tt.name, len(batch.Entries), batch.Dropped, batch.Next)
+			}
+			if tt.name == "reset" {
+				load(t, c, code)
+			}
+			stepN(c, 1)
+			batch := c.Trace(0, 10)
+			if len(batch.Entries) != 1 || batch.Entries[0].Seq != 3 || batch.Entries[0].PC != 0 || batch.Dropped != 3 {
+				t.Errorf("after stepping again: %+v", batch)
+			}
+		})
+	}
+}
@@ -0,0 +1,54 @@
+package emulator
+
+// watchdogWindow is how many bytes either side of where a spin started the PC
+// may wander while still being considered stuck in a tight loop.
+const watchdogWindow = 8
+
+// watchdog tracks instruction counts so runaway programs can be stopped.
+type watchdog struct {
+	budget uint64 // max instructions since load without reaching HALT; 0 disables
+	spin   uint64 // max instructions spent inside one PC window; 0 disables
+
+	steps     uint64
+	spinBase  uint16
+	spinSteps uint64
+}
+
+// WithWatchdog enables the watchdog. The machine halts with HaltWatchdog once
+// budget instructions have executed since the program was loaded, or once spin
+// instructions in a row have executed without the PC leaving a small window
+// around where the spin began. A zero value disables that check; the watchdog
+// is off by default so interactive sessions can run indefinitely.
+func WithWatchdog(budget, spin uint64) Option {
+	return func(c *MonTanaMiniComputer) {
+		c.watchdog.budget = budget
+		c.watchdog.spin = spin
+	}
+}
+
+// reset clears the counters, e.g. when a new program is loaded.
+func (w *watchdog) reset() {
+	w.steps = 0
+	w.spinBase = 0
+	w.spinSteps = 0
+}
+
+// checkWatchdog records that the instruction word at pc executed and halts
+// the machine if a watchdog limit has been exceeded.
+func (c *MonTanaMiniComputer) checkWatchdog(pc, word uint16) {
+	w := &c.watchdog
+	if w.budget == 0 && w.spin == 0 {
+		return
+	}
+
+	w.steps++
+	if d := int(pc) - int(w.spinBase); d < -watchdogWindow || d > watchdogWindow {
+		w.spinBase = pc
+		w.spinSteps = 0
+	}
+	w.spinSteps++
+
+	if (w.budget > 0 && w.steps >= w.budget) || (w.spin > 0 && w.spinSteps >= w.spin) {
+		c.fault(HaltWatchdog, pc, word, true)
+	}
+}
@@ -0,0 +1,71 @@
+package emulator
+
+import "testing"
+
+// stepUntilHalt steps c until it halts, or limit steps have run, and
+// returns the halt reason and how many steps it took.
+func stepUntilHalt(c *MonTanaMiniComputer, limit int) (HaltReason, int) {
+	for i := 1; i <= limit; i++ {
+		if reason := c.StepState()["haltReason"].(HaltReason); reason != HaltNone {
+			return reason, i
+		}
+	}
+	return HaltNone, limit
+}
+
+func TestWatchdog(t *testing.T) {
+	loop := program(t, nop, ri(OpBZ, 0, -2)) // NOP; branch back to it
+	// Eight NOPs and a branch back to the first: the loop is wider than
+	// the spin window, so only the budget catches it.
+	wide := program(t, nop, nop, nop, nop, nop, nop, nop, nop, ri(OpBZ, 0, -9))
+	tests := []struct {
+		name      string
+		budget    uint64
+		spin      uint64
+		code      []byte
+		wantHalt  HaltReason
+		wantSteps int
+	}{
+		{"budget stops infinite loop", 10, 0, loop, HaltWatchdog, 10},
+		{"spin stops tight loop", 0, 6, loop, HaltWatchdog, 6},
+		{"spin ignores wide loop", 0, 6, wide, HaltNone, 100},
+		{"budget stops wide loop", 50, 6, wide, HaltWatchdog, 50},
+		{"program halting within budget", 10, 0, program(t, nop, halt), HaltInstruction, 2},
+		{"program halting on the last instruction of the budget", 2, 0, program(t, nop, halt), HaltInstruction, 2},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := newTestComputer(t, WithWatchdog(tt.budget, tt.spin))
+			load(t, c, tt.code)
+			reason, steps := stepUntilHalt(c, 100)
+			if reason != tt.wantHalt || steps != tt.wantSteps {
+				t.Errorf("halt reason %q after %d steps, want %q after %d", reason, steps, tt.wantHalt, tt.wantSteps)
+			}
+			if f := c.LastFault(); tt.wantHalt == HaltWatchdog && (f == nil || f.Reason != HaltWatchdog) {
+				t.Errorf("LastFault = %+v, want a watchdog fault", f)
+			}
+		})
+	}
+}
+
+func TestWatchdogResetOnReload(t *testing.T) {
+	c := newTestComputer(t, WithWatchdog(10, 0))
+	load(t, c, program(t, nop, ri(OpBZ, 0, -2)))
+	stepN(c, 6)
+	if err := c.ReloadProgram(nil); err != nil {
+		t.Fatal(err)
+	}
+	// A fresh budget of 10 steps, not the 4 left before the reload.
+	if reason, steps := stepUntilHalt(c, 100); reason != HaltWatchdog || steps != 10 {
+		t.Errorf("halt reason %q after %d steps, want %q after 10", reason, steps, HaltWatchdog)
+	}
+}
+
+func TestWatchdogOffByDefault(t *testing.T) {
+	c := newTestComputer(t)
+	load(t, c, program(t, nop, ri(OpBZ, 0, -2)))
+	stepN(c, 1000)
+	if got := c.GetState()["haltReason"]; got != HaltNone {
+		t.Errorf("halt reason = %v after 1000 steps, want none", got)
+	}
+}
@@ -0,0 +1,48 @@
+package web
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestBasicAuth(t *testing.T) {
+	tests := []struct {
+		name     string
+		target   string
+		user     string // "" sends no credentials
+		password string
+		status   int
+	}{
+		{"authorized", "/state", "admin", "secret", http.StatusOK},
+		{"no credentials", "/state", "", "", http.StatusUnauthorized},
+		{"wrong password", "/state", "admin", "guess", http.StatusUnauthorized},
+		{"wrong user", "/state", "root", "secret", http.StatusUnauthorized},
+		{"control guarded", "/control?action=run", "", "", http.StatusUnauthorized},
+		{"load guarded", "/load?program=x", "", "", http.StatusUnauthorized},
+		{"websocket guarded", "/ws", "", "", http.StatusUnauthorized},
+		{"health open", "/healthz", "", "", http.StatusOK},
+		{"static open", "/static/js/main.js", "", "", http.StatusOK},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			computer := newTestComputer()
+			h := newTestServer(computer, WithBasicAuth("admin", "secret")).Handler()
+			r := httptest.NewRequest(http.MethodGet, tt.target, nil)
+			if tt.user != "" {
+				r.SetBasicAuth(tt.user, tt.password)
+			}
+			w := serve(h, r)
+			if w.Code != tt.status {
+				t.Fatalf("status %d, want %d", w.Code, tt.status)
+			}
+			challenge := w.Header().Get("WWW-Authenticate")
+			if (challenge != "") != (tt.status == http.StatusUnauthorized) {
+				t.Errorf("WWW-Authenticate = %q with status %d", challenge, w.Code)
+			}
+			if computer.IsRunning() {
+				t.Error("unauthorized request started the machine")
+			}
+		})
+	}
+}
@@ -0,0 +1,42 @@
+package web
+
+import "encoding/binary"
+
+// Clients that connect to /ws?format=binary receive state messages as
+// compact binary frames instead of JSON, which is far cheaper to produce and
+// decode during fast runs. Other messages are still JSON text frames. A
+// binary frame is laid out as follows, with multi-byte values big-endian:
+//
+//	offset  size  field
+//	0       1     type tag, FrameState
+//	1       1     flags: bit 0 is set while running
+//	2       32    the 16 registers, 2 bytes each, in index order
+//	34      8     instruction count
+//	42      ...   the memory window, as raw bytes
+const (
+	// FrameState tags a binary frame holding machine state.
+	FrameState byte = 1
+
+	frameRunning     = 1 << 0
+	frameHeaderSize  = 42
+	frameRegisters   = 2
+	frameInstruction = 34
+)
+
+// encodeStateFrame encodes the state, as GetState returns it, as a binary
+// state frame.
+func encodeStateFrame(state map[string]interface{}) []byte {
+	registers, _ := state["registers"].([16]uint16)
+	memory, _ := state["memory"].([]byte)
+	frame := make([]byte, frameHeaderSize, frameHeaderSize+len(memory))
+	frame[0] = FrameState
+	if running, _ := state["running"].(bool); running {
+		frame[1] |= frameRunning
+	}
+	for i, value := range registers {
+		binary.BigEndian.PutUint16(frame[frameRegisters+2*i:], value)
+	}
+	count, _ := state["instructionCount"].(uint64)
+	binary.BigEndian.PutUint64(frame[frameInstruction:], count)
+	return append(frame, memory...)
+}
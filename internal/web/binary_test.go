@@ -0,0 +1,108 @@
+package web
+
+import (
+	"bytes"
+	"encoding/binary"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gorilla/websocket"
+)
+
+// stateFrame is a binary state frame decoded as a client would.
+type stateFrame struct {
+	running          bool
+	registers        [16]uint16
+	instructionCount uint64
+	memory           []byte
+}
+
+// decodeStateFrame decodes frame following the layout documented on
+// FrameState.
+func decodeStateFrame(t *testing.T, frame []byte) stateFrame {
+	t.Helper()
+	if len(frame) < 42 || frame[0] != FrameState {
+		t.Fatalf("frame % X is not a state frame", frame)
+	}
+	f := stateFrame{running: frame[1]&1 != 0, memory: frame[42:]}
+	for i := range f.registers {
+		f.registers[i] = binary.BigEndian.Uint16(frame[2+2*i:])
+	}
+	f.instructionCount = binary.BigEndian.Uint64(frame[34:])
+	return f
+}
+
+func TestBinaryStateFrames(t *testing.T) {
+	computer := newTestComputer()
+	// LIW R1, 0x1234; LIW R2, 0xBEEF; HALT
+	code := []byte{0xB1, 0x0B, 0x12, 0x34, 0xB2, 0x0B, 0xBE, 0xEF, 0xF0, 0x00}
+	if err := computer.LoadProgram(code, 0); err != nil {
+		t.Fatal(err)
+	}
+	srv := httptest.NewServer(newTestServer(computer).Handler())
+	defer srv.Close()
+	conn := dial(t, srv, "/ws?format=binary")
+	waitObservers(t, computer, 1)
+
+	const R1, R2, PC = 1, 2, 14
+	steps := []struct {
+		name   string
+		r1, r2 uint16
+		pc     uint16
+		count  uint64
+	}{
+		{"first LIW", 0x1234, 0, 4, 1},
+		{"second LIW", 0x1234, 0xBEEF, 8, 2},
+		{"HALT", 0x1234, 0xBEEF, 10, 3},
+	}
+	for _, step := range steps {
+		computer.StepState()
+		conn.SetReadDeadline(time.Now().Add(5 * time.Second))
+		typ, data, err := conn.ReadMessage()
+		if err != nil {
+			t.Fatalf("%s: reading frame: %v", step.name, err)
+		}
+		if typ != websocket.BinaryMessage {
+			t.Fatalf("%s: got a message of type %d, want a binary frame", step.name, typ)
+		}
+		f := decodeStateFrame(t, data)
+		if f.registers[R1] != step.r1 || f.registers[R2] != step.r2 || f.registers[PC] != step.pc {
+			t.Errorf("%s: R1 0x%04X, R2 0x%04X, PC %d; want 0x%04X, 0x%04X, %d",
+				step.name, f.registers[R1], f.registers[R2], f.registers[PC], step.r1, step.r2, step.pc)
+		}
+		if want := computer.GetState()["registers"].([16]uint16); f.registers != want {
+			t.Errorf("%s: registers %v, want %v", step.name, f.registers, want)
+		}
+		if f.instructionCount != step.count || f.running {
+			t.Errorf("%s: count %d, running %v; want %d, false", step.name, f.instructionCount, f.running, step.count)
+		}
+		if want := computer.GetState()["memory"].([]byte); !bytes.Equal(f.memory, want) {
+			t.Errorf("%s: memory % X, want % X", step.name, f.memory, want)
+		}
+	}
+}
+
+func TestEncodeStateFrame(t *testing.T) {
+	tests := []struct {
+		name  string
+		state map[string]interface{}
+		want  stateFrame
+	}{
+		{"empty", map[string]interface{}{}, stateFrame{memory: []byte{}}},
+		{"running", map[string]interface{}{
+			"running":          true,
+			"registers":        [16]uint16{0: 0xFFFF, 7: 0x8001, 15: 0x0102},
+			"instructionCount": uint64(1) << 40,
+			"memory":           []byte{0xDE, 0xAD},
+		}, stateFrame{true, [16]uint16{0: 0xFFFF, 7: 0x8001, 15: 0x0102}, 1 << 40, []byte{0xDE, 0xAD}}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			f := decodeStateFrame(t, encodeStateFrame(tt.state))
+			if f.running != tt.want.running || f.registers != tt.want.registers || f.instructionCount != tt.want.instructionCount || !bytes.Equal(f.memory, tt.want.memory) {
+				t.Errorf("decoded %+v, want %+v", f, tt.want)
+			}
+		})
+	}
+}
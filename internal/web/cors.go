@@ -0,0 +1,65 @@
+package web
+
+import (
+	"net/http"
+	"slices"
+	"strings"
+)
+
+// CORSConfig lists which cross-origin requests the server accepts.
+type CORSConfig struct {
+	// AllowedOrigins are origins such as "http://localhost:5173" that may
+	// call the API. "*" allows any origin.
+	AllowedOrigins []string
+	// AllowedMethods defaults to GET, POST, PUT and DELETE when empty.
+	AllowedMethods []string
+	// AllowedHeaders defaults to Content-Type and Accept when empty.
+	AllowedHeaders []string
+}
+
+// WithCORS lets browsers on other origins call the server, e.g. a separately
+// hosted frontend. Without it only same-origin requests are permitted.
+func WithCORS(config CORSConfig) Option {
+	return func(s *Server) {
+		if len(config.AllowedMethods) == 0 {
+			config.AllowedMethods = []string{http.MethodGet, http.MethodPost, http.MethodPut, http.MethodDelete}
+		}
+		if len(config.AllowedHeaders) == 0 {
+			config.AllowedHeaders = []string{"Content-Type", "Accept"}
+		}
+		s.cors = &config
+	}
+}
+
+// allows reports whether requests from origin are permitted.
+func (c *CORSConfig) allows(origin string) bool {
+	return slices.Contains(c.AllowedOrigins, "*") || slices.Contains(c.AllowedOrigins, origin)
+}
+
+// withCORS adds CORS headers for allowed origins and answers preflight
+// requests. Requests from other origins get no CORS headers, so browsers
+// block them.
+func (s *Server) withCORS(next http.Handler) http.Handler {
+	if s.cors == nil {
+		return next
+	}
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		origin := r.Header.Get("Origin")
+		preflight := r.Method == http.MethodOptions && r.Header.Get("Access-Control-Request-Method") != ""
+
+		if origin != "" && s.cors.allows(origin) {
+			h := w.Header()
+			h.Add("Vary", "Origin")
+			h.Set("Access-Control-Allow-Origin", origin)
+			if preflight {
+				h.Set("Access-Control-Allow-Methods", strings.Join(s.cors.AllowedMethods, ", "))
+				h.Set("Access-Control-Allow-Headers", strings.Join(s.cors.AllowedHeaders, ", "))
+			}
+		}
+		if preflight {
+			w.WriteHeader(http.StatusNoContent)
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
@@ -0,0 +1,60 @@
+package web
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestCORS(t *testing.T) {
+	const allowed = "http://localhost:5173"
+	tests := []struct {
+		name      string
+		config    *CORSConfig
+		method    string
+		origin    string
+		wantCode  int
+		wantAllow string
+		wantPre   bool // whether the preflight headers are set
+	}{
+		{"allowed", &CORSConfig{AllowedOrigins: []string{allowed}}, http.MethodGet, allowed, http.StatusOK, allowed, false},
+		{"disallowed", &CORSConfig{AllowedOrigins: []string{allowed}}, http.MethodGet, "http://evil.example", http.StatusOK, "", false},
+		{"wildcard", &CORSConfig{AllowedOrigins: []string{"*"}}, http.MethodGet, "http://any.example", http.StatusOK, "http://any.example", false},
+		{"same origin", &CORSConfig{AllowedOrigins: []string{allowed}}, http.MethodGet, "", http.StatusOK, "", false},
+		{"preflight allowed", &CORSConfig{AllowedOrigins: []string{allowed}}, http.MethodOptions, allowed, http.StatusNoContent, allowed, true},
+		{"preflight disallowed", &CORSConfig{AllowedOrigins: []string{allowed}}, http.MethodOptions, "http://evil.example", http.StatusNoContent, "", false},
+		{"not configured", nil, http.MethodGet, allowed, http.StatusOK, "", false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var opts []Option
+			if tt.config != nil {
+				opts = append(opts, WithCORS(*tt.config))
+			}
+			r := httptest.NewRequest(tt.method, "/healthz", nil)
+			if tt.origin != "" {
+				r.Header.Set("Origin", tt.origin)
+			}
+			if tt.method == http.MethodOptions {
+				r.Header.Set("Access-Control-Request-Method", http.MethodPost)
+			}
+			w := serve(newTestServer(newTestComputer(), opts...).Handler(), r)
+
+			if w.Code != tt.wantCode {
+				t.Errorf("status %d, want %d", w.Code, tt.wantCode)
+			}
+			if got := w.Header().Get("Access-Control-Allow-Origin"); got != tt.wantAllow {
+				t.Errorf("Access-Control-Allow-Origin = %q, want %q", got, tt.wantAllow)
+			}
+			methods := w.Header().Get("Access-Control-Allow-Methods")
+			headers := w.Header().Get("Access-Control-Allow-Headers")
+			if tt.wantPre {
+				if methods != "GET, POST, PUT, DELETE" || headers != "Content-Type, Accept" {
+					t.Errorf("preflight allows methods %q and headers %q, want the defaults", methods, headers)
+				}
+			} else if methods != "" || headers != "" {
+				t.Errorf("unexpected preflight headers: methods %q, headers %q", methods, headers)
+			}
+		})
+	}
+}
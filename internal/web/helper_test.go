@@ -0,0 +1,87 @@
+package web
+
+import (
+	"encoding/json"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gorilla/websocket"
+
+	"github.com/catdevman/go-mtmc/internal/emulator"
+)
+
+// discard is the logger tests give machines and servers.
+var discard = slog.New(slog.DiscardHandler)
+
+// newTestComputer returns a fresh machine with logging discarded.
+func newTestComputer(opts ...emulator.Option) *emulator.MonTanaMiniComputer {
+	return emulator.New(append([]emulator.Option{emulator.WithLogger(discard)}, opts...)...)
+}
+
+// newTestServer returns a server for computer with logging discarded.
+func newTestServer(computer *emulator.MonTanaMiniComputer, opts ...Option) *Server {
+	return NewServer(computer, append([]Option{WithLogger(discard)}, opts...)...)
+}
+
+// do sends a request to h from the client at remoteAddr and returns the
+// status code.
+func do(h http.Handler, method, target, body, remoteAddr string) int {
+	r := httptest.NewRequest(method, target, strings.NewReader(body))
+	r.RemoteAddr = remoteAddr
+	return serve(h, r).Code
+}
+
+// serve sends r to h and returns the recorded response.
+func serve(h http.Handler, r *http.Request) *httptest.ResponseRecorder {
+	w := httptest.NewRecorder()
+	h.ServeHTTP(w, r)
+	return w
+}
+
+// dial opens a WebSocket to path on srv and reads the MessageAck that
+// starts every stream.
+func dial(t *testing.T, srv *httptest.Server, path string) *websocket.Conn {
+	t.Helper()
+	conn, _, err := websocket.DefaultDialer.Dial("ws"+strings.TrimPrefix(srv.URL, "http")+path, nil)
+	if err != nil {
+		t.Fatalf("dial %s: %v", path, err)
+	}
+	t.Cleanup(func() { conn.Close() })
+	if typ, _ := readMessage(t, conn); typ != MessageAck {
+		t.Fatalf("first message is %q, want %q", typ, MessageAck)
+	}
+	return conn
+}
+
+// readMessage reads the next message from conn, failing the test if none
+// arrives within a few seconds, and returns its type and raw payload.
+func readMessage(t *testing.T, conn *websocket.Conn) (string, json.RawMessage) {
+	t.Helper()
+	conn.SetReadDeadline(time.Now().Add(5 * time.Second))
+	var msg struct {
+		Type    string          `json:"type"`
+		Payload json.RawMessage `json:"payload"`
+	}
+	if err := conn.ReadJSON(&msg); err != nil {
+		t.Fatalf("reading message: %v", err)
+	}
+	return msg.Type, msg.Payload
+}
+
+// waitObservers waits for computer to have n observers. A WebSocket client
+// is sent MessageAck before it is added as an observer, so tests that need
+// it to see updates wait for it here.
+func waitObservers(t *testing.T, computer *emulator.MonTanaMiniComputer, n int) {
+	t.Helper()
+	deadline := time.Now().Add(5 * time.Second)
+	for computer.ObserverCount() != n {
+		if time.Now().After(deadline) {
+			t.Fatalf("%d observers, want %d", computer.ObserverCount(), n)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
@@ -0,0 +1,34 @@
+package web
+
+import (
+	"fmt"
+	"io"
+	"maps"
+	"net/http"
+	"slices"
+)
+
+// handleMetrics exposes usage counters in the Prometheus text format.
+func (s *Server) handleMetrics(w http.ResponseWriter, r *http.Request) {
+	m := s.computer.Metrics()
+	w.Header().Set("Content-Type", "text/plain; version=0.0.4; charset=utf-8")
+
+	metric(w, "mtmc_instructions_total", "counter", "Instructions executed.")
+	fmt.Fprintf(w, "mtmc_instructions_total %d\n", m.Instructions)
+
+	metric(w, "mtmc_faults_total", "counter", "Instruction faults, by reason.")
+	for _, reason := range slices.Sorted(maps.Keys(m.Faults)) {
+		fmt.Fprintf(w, "mtmc_faults_total{reason=%q} %d\n", reason, m.Faults[reason])
+	}
+
+	metric(w, "mtmc_programs_loaded_total", "counter", "Programs loaded, including reloads.")
+	fmt.Fprintf(w, "mtmc_programs_loaded_total %d\n", m.ProgramsLoaded)
+
+	metric(w, "mtmc_websocket_clients", "gauge", "Connected WebSocket clients.")
+	fmt.Fprintf(w, "mtmc_websocket_clients %d\n", s.wsClients.Load())
+}
+
+// metric writes the HELP and TYPE lines that introduce a metric.
+func metric(w io.Writer, name, kind, help string) {
+	fmt.Fprintf(w, "# HELP %s %s\n# TYPE %s %s\n", name, help, name, kind)
+}
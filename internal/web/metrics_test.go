@@ -0,0 +1,54 @@
+package web
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestMetrics(t *testing.T) {
+	computer := newTestComputer()
+	if err := computer.LoadProgram([]byte{0, 0, 0xF0, 0}, 0); err != nil { // NOP, HALT
+		t.Fatal(err)
+	}
+	computer.StepState()
+	computer.StepState()
+	if err := computer.LoadProgramAt([]byte{0, 0}, 14, 14); err != nil {
+		t.Fatal(err)
+	}
+	computer.StepState() // a NOP in the last word
+	computer.StepState() // runs off the end of memory
+
+	s := newTestServer(computer)
+	srv := httptest.NewServer(s.Handler())
+	defer srv.Close()
+	dial(t, srv, "/ws")
+	deadline := time.Now().Add(5 * time.Second)
+	for s.wsClients.Load() != 1 && time.Now().Before(deadline) {
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	w := serve(s.Handler(), httptest.NewRequest(http.MethodGet, "/metrics", nil))
+	if w.Code != http.StatusOK {
+		t.Fatalf("status %d, want %d", w.Code, http.StatusOK)
+	}
+	if ct := w.Header().Get("Content-Type"); !strings.HasPrefix(ct, "text/plain; version=0.0.4") {
+		t.Errorf("Content-Type = %q, want the Prometheus text format", ct)
+	}
+	for _, want := range []string{
+		"# TYPE mtmc_instructions_total counter",
+		"mtmc_instructions_total 3",
+		"# TYPE mtmc_faults_total counter",
+		`mtmc_faults_total{reason="pc-out-of-bounds"} 1`,
+		"# TYPE mtmc_programs_loaded_total counter",
+		"mtmc_programs_loaded_total 2",
+		"# TYPE mtmc_websocket_clients gauge",
+		"mtmc_websocket_clients 1",
+	} {
+		if !strings.Contains(w.Body.String(), want+"\n") {
+			t.Errorf("metrics missing %q:\n%s", want, w.Body)
+		}
+	}
+}
@@ -0,0 +1,29 @@
+package web
+
+import (
+	"github.com/catdevman/go-mtmc/internal/assembler"
+	"github.com/catdevman/go-mtmc/internal/emulator"
+	"strings"
+)
+
+// programImage converts a program file from the disk into a loadable image.
+// ELF executables are placed by their program headers, and assembly source,
+// named *.asm, is assembled with its source lines kept for the debugger and
+// the register aliases in aliases understood.
+// Anything else is a flat binary for address 0, starting at entry; flat
+// reports this case, so the caller can load it with LoadNamedProgram, which
+// pads it to whole words. Either way the load fails if it does not fit.
+func programImage(name string, data []byte, entry uint16, aliases map[string]string) (img emulator.Image, flat bool, err error) {
+	switch {
+	case emulator.IsELF(data):
+		img, err = emulator.ParseELF(data)
+		return img, false, err
+	case strings.HasSuffix(name, ".asm"):
+		program, err := assembler.Assemble(string(data), assembler.WithRegisterAliases(aliases))
+		if err != nil {
+			return emulator.Image{}, false, err
+		}
+		return program.Image(), false, nil
+	}
+	return emulator.Image{Segments: []emulator.Segment{{Address: 0, Data: data}}, Entry: entry}, true, nil
+}
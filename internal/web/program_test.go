@@ -0,0 +1,81 @@
+package web
+
+import (
+	"bytes"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/catdevman/go-mtmc/internal/disk"
+)
+
+func TestLoadEntry(t *testing.T) {
+	const name = "web-test-entry.bin"
+	if err := disk.WriteFile("disk/bin/"+name, []byte{0, 0, 0, 0, 0xF0, 0}); err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		name   string
+		query  string
+		status int
+		pc     uint16
+	}{
+		{"default entry", "", http.StatusOK, 0},
+		{"entry", "&entry=4", http.StatusOK, 4},
+		{"hex entry", "&entry=0x2", http.StatusOK, 2},
+		{"invalid entry", "&entry=four", http.StatusBadRequest, 0},
+		{"entry outside memory", "&entry=0xFFFF", http.StatusBadRequest, 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			computer := newTestComputer()
+			h := newTestServer(computer).Handler()
+			r := httptest.NewRequest(http.MethodPost, "/load?program="+name+tt.query, nil)
+			r.Header.Set("Accept", "application/json")
+			w := serve(h, r)
+			if w.Code != tt.status {
+				t.Fatalf("status %d, want %d: %s", w.Code, tt.status, w.Body)
+			}
+			if pc := computer.GetState()["pc"]; tt.status == http.StatusOK && pc != tt.pc {
+				t.Errorf("PC = %v, want %d", pc, tt.pc)
+			}
+		})
+	}
+}
+
+func TestLoadEmptyAndOdd(t *testing.T) {
+	files := map[string][]byte{
+		"web-test-empty.bin": {},
+		"web-test-odd.bin":   {0x00, 0x00, 0xF0},
+	}
+	for name, data := range files {
+		if err := disk.WriteFile("disk/bin/"+name, data); err != nil {
+			t.Fatal(err)
+		}
+	}
+	tests := []struct {
+		program string
+		status  int
+		body    string
+	}{
+		{"web-test-empty.bin", http.StatusBadRequest, "program is empty"},
+		{"web-test-odd.bin", http.StatusOK, `"ok":true`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.program, func(t *testing.T) {
+			computer := newTestComputer()
+			r := httptest.NewRequest(http.MethodPost, "/load?program="+tt.program, nil)
+			r.Header.Set("Accept", "application/json")
+			w := serve(newTestServer(computer).Handler(), r)
+			if w.Code != tt.status || !strings.Contains(w.Body.String(), tt.body) {
+				t.Fatalf("status %d, body %q; want %d, containing %q", w.Code, w.Body, tt.status, tt.body)
+			}
+			if tt.status == http.StatusOK {
+				if got, _ := computer.ReadMemory(0, 4); !bytes.Equal(got, []byte{0x00, 0x00, 0xF0, 0x00}) {
+					t.Errorf("memory = % X, want the program padded with a zero byte", got)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,106 @@
+package web
+
+import (
+	"net"
+	"net/http"
+	"sync"
+	"time"
+)
+
+// WithRateLimit limits each client IP to rate requests per second, with
+// bursts of up to burst, on the endpoints that change the machine or the
+// disk: /control, /load, /loaddata, /input, and writes to /memory, /file,
+// /breakpoints, /flags, /annotations and /aliases. Requests over the limit
+// get 429 Too Many Requests. Reads such as /state and GET /memory are never
+// limited.
+func WithRateLimit(rate float64, burst int) Option {
+	return func(s *Server) {
+		s.limiter = &rateLimiter{
+			rate:    rate,
+			burst:   float64(burst),
+			buckets: make(map[string]*bucket),
+			now:     time.Now,
+		}
+	}
+}
+
+// rateLimiter is a per-IP token bucket.
+type rateLimiter struct {
+	rate, burst float64
+	now         func() time.Time // time.Now, replaceable in tests
+
+	mu      sync.Mutex
+	buckets map[string]*bucket
+}
+
+type bucket struct {
+	tokens float64
+	last   time.Time
+}
+
+// maxIdleBuckets is how many buckets are kept before full ones, which carry
+// no information, are discarded.
+const maxIdleBuckets = 1024
+
+// allow takes a token from ip's bucket, reporting false if it is empty.
+func (l *rateLimiter) allow(ip string) bool {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	now := l.now()
+	b, ok := l.buckets[ip]
+	if !ok {
+		if len(l.buckets) >= maxIdleBuckets {
+			l.prune(now)
+		}
+		b = &bucket{tokens: l.burst, last: now}
+		l.buckets[ip] = b
+	}
+	b.tokens = min(l.burst, b.tokens+now.Sub(b.last).Seconds()*l.rate)
+	b.last = now
+	if b.tokens < 1 {
+		return false
+	}
+	b.tokens--
+	return true
+}
+
+// prune removes buckets that have refilled completely.
+func (l *rateLimiter) prune(now time.Time) {
+	for ip, b := range l.buckets {
+		if b.tokens+now.Sub(b.last).Seconds()*l.rate >= l.burst {
+			delete(l.buckets, ip)
+		}
+	}
+}
+
+// limit rejects requests from clients that are over the rate limit.
+func (s *Server) limit(next http.HandlerFunc) http.HandlerFunc {
+	if s.limiter == nil {
+		return next
+	}
+	return func(w http.ResponseWriter, r *http.Request) {
+		ip, _, err := net.SplitHostPort(r.RemoteAddr)
+		if err != nil {
+			ip = r.RemoteAddr
+		}
+		if !s.limiter.allow(ip) {
+			w.Header().Set("Retry-After", "1")
+			http.Error(w, "too many requests", http.StatusTooManyRequests)
+			return
+		}
+		next(w, r)
+	}
+}
+
+// limitWrites is like limit for an endpoint that also serves reads: GET and
+// HEAD requests pass through without taking a token.
+func (s *Server) limitWrites(next http.HandlerFunc) http.HandlerFunc {
+	limited := s.limit(next)
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method == http.MethodGet || r.Method == http.MethodHead {
+			next(w, r)
+			return
+		}
+		limited(w, r)
+	}
+}
@@ -0,0 +1,102 @@
+package web
+
+import (
+	"net/http"
+	"testing"
+	"time"
+)
+
+func TestMemoryReadsNotRateLimited(t *testing.T) {
+	h := newTestServer(newTestComputer(), WithRateLimit(0, 1)).Handler()
+	const client = "192.0.2.1:1234"
+
+	for i := range 5 {
+		if code := do(h, http.MethodGet, "/memory?address=0&length=2", "", client); code != http.StatusOK {
+			t.Fatalf("GET /memory %d: status %d, want %d", i, code, http.StatusOK)
+		}
+	}
+	if code := do(h, http.MethodPut, "/memory?address=0", "\x01\x02", client); code != http.StatusNoContent {
+		t.Fatalf("first PUT /memory: status %d, want %d", code, http.StatusNoContent)
+	}
+	if code := do(h, http.MethodPut, "/memory?address=0", "\x01\x02", client); code != http.StatusTooManyRequests {
+		t.Errorf("second PUT /memory: status %d, want %d", code, http.StatusTooManyRequests)
+	}
+	if code := do(h, http.MethodGet, "/memory?address=0&length=2", "", client); code != http.StatusOK {
+		t.Errorf("GET /memory after limit: status %d, want %d", code, http.StatusOK)
+	}
+}
+
+func TestWritesRateLimited(t *testing.T) {
+	tests := []struct {
+		read          string
+		method, write string
+		body          string
+		status        int
+	}{
+		{"/file?name=tmp/ratelimit.txt", http.MethodPut, "/file?name=tmp/ratelimit.txt", "data", http.StatusNoContent},
+		{"/file?name=tmp/ratelimit.txt", http.MethodPost, "/file?name=tmp/ratelimit.txt", "data", http.StatusNoContent},
+		{"/breakpoints", http.MethodPost, "/breakpoints?address=4", "", http.StatusOK},
+		{"/breakpoints", http.MethodDelete, "/breakpoints", "", http.StatusOK},
+		{"/flags", http.MethodPost, "/flags?zero=true", "", http.StatusOK},
+		{"/annotations", http.MethodPost, "/annotations?address=4&note=loop", "", http.StatusOK},
+		{"/annotations", http.MethodDelete, "/annotations?address=4", "", http.StatusOK},
+		{"/aliases", http.MethodPost, "/aliases?register=R1&alias=count", "", http.StatusOK},
+		{"/aliases", http.MethodDelete, "/aliases?register=R1", "", http.StatusOK},
+	}
+	for _, tt := range tests {
+		t.Run(tt.method+" "+tt.write, func(t *testing.T) {
+			h := newTestServer(newTestComputer(), WithRateLimit(0, 1)).Handler()
+			const client = "192.0.2.1:1234"
+
+			if code := do(h, tt.method, tt.write, tt.body, client); code != tt.status {
+				t.Fatalf("first %s: status %d, want %d", tt.method, code, tt.status)
+			}
+			if code := do(h, tt.method, tt.write, tt.body, client); code != http.StatusTooManyRequests {
+				t.Errorf("second %s: status %d, want %d", tt.method, code, http.StatusTooManyRequests)
+			}
+			for i := range 3 {
+				if code := do(h, http.MethodGet, tt.read, "", client); code != http.StatusOK {
+					t.Errorf("GET %s %d after limit: status %d, want %d", tt.read, i, code, http.StatusOK)
+				}
+			}
+		})
+	}
+}
+
+func TestRateLimit(t *testing.T) {
+	const burst = 3
+	s := newTestServer(newTestComputer(), WithRateLimit(1, burst))
+	now := time.Unix(0, 0)
+	s.limiter.now = func() time.Time { return now }
+	h := s.Handler()
+	const client, other = "192.0.2.1:1234", "192.0.2.2:1234"
+
+	steps := []struct {
+		name    string
+		advance time.Duration
+		target  string
+		from    string
+		status  int
+	}{
+		{"burst 1", 0, "/control?action=pause", client, http.StatusFound},
+		{"burst 2", 0, "/control?action=pause", client, http.StatusFound},
+		{"burst 3", 0, "/input", client, http.StatusNoContent},
+		{"beyond the burst", 0, "/control?action=pause", client, http.StatusTooManyRequests},
+		{"shared across endpoints", 0, "/load", client, http.StatusTooManyRequests},
+		{"another port, same client", 0, "/control?action=pause", "192.0.2.1:5678", http.StatusTooManyRequests},
+		{"other client", 0, "/control?action=pause", other, http.StatusFound},
+		{"reads unlimited", 0, "/state", client, http.StatusOK},
+		{"refilled", time.Second, "/control?action=pause", client, http.StatusFound},
+		{"one token only", 0, "/control?action=pause", client, http.StatusTooManyRequests},
+	}
+	for _, step := range steps {
+		now = now.Add(step.advance)
+		method := http.MethodPost
+		if step.target == "/state" {
+			method = http.MethodGet
+		}
+		if code := do(h, method, step.target, "", step.from); code != step.status {
+			t.Errorf("%s: status %d, want %d", step.name, code, step.status)
+		}
+	}
+}
@@ -1,14 +1,28 @@
 package web
 
 import (
+	"bytes"
 	"embed"
+	"encoding/binary"
+	"encoding/hex"
 	"encoding/json"
+	"errors"
+	"fmt"
 	"github.com/catdevman/go-mtmc/internal/disk"
 	"github.com/catdevman/go-mtmc/internal/emulator"
 	"html/template"
+	"io"
 	"io/fs"
-	"log"
+	"log/slog"
+	"math"
+	"net"
 	"net/http"
+	"os"
+	"slices"
+	"strconv"
+	"strings"
+	"sync/atomic"
+	"time"
 
 	"github.com/gorilla/websocket"
 )
@@ -19,6 +33,14 @@ var templatesFS embed.FS
 //go:embed static
 var staticFS embed.FS
 
+// WebSocket keepalive timing: the server drops a client that has not
+// answered a ping within pongWait, pinging often enough that a live client
+// always has the chance to.
+const (
+	pongWait  = 60 * time.Second
+	writeWait = 10 * time.Second
+)
+
 var upgrader = websocket.Upgrader{
 	ReadBufferSize:  1024,
 	WriteBufferSize: 1024,
@@ -26,15 +48,65 @@ var upgrader = websocket.Upgrader{
 
 // Server holds the dependencies for the web server.
 type Server struct {
-	computer  *emulator.MonTanaMiniComputer
-	templates map[string]*template.Template
+	computer   *emulator.MonTanaMiniComputer
+	templates  map[string]*template.Template
+	logger     *slog.Logger
+	addr       string
+	listener   net.Listener
+	cors       *CORSConfig
+	auth       *basicAuth
+	limiter    *rateLimiter
+	started    time.Time
+	wsClients  atomic.Int64
+	httpServer *http.Server
+	pongWait   time.Duration                      // pongWait, shortened in tests
+	readDir    func(dir string) ([]string, error) // disk.ReadDir, replaceable in tests
+}
+
+// DefaultAddr is the address the server listens on unless WithAddr is used.
+const DefaultAddr = ":8080"
+
+// Option configures a Server at construction time.
+type Option func(*Server)
+
+// WithLogger sets the logger used for server lifecycle (info) and control
+// action (debug) messages. It defaults to slog.Default().
+func WithLogger(logger *slog.Logger) Option {
+	return func(s *Server) {
+		s.logger = logger
+	}
+}
+
+// WithAddr sets the TCP address to listen on, such as "localhost:9000". Use
+// ":0" to pick a free port; Addr reports which one was chosen.
+func WithAddr(addr string) Option {
+	return func(s *Server) {
+		s.addr = addr
+	}
+}
+
+// WithAutoRestart makes the machine reload and rerun the program whenever it
+// executes HALT, looping a demo indefinitely. Without it the machine stops at
+// HALT and the exit code is reported in the state.
+func WithAutoRestart() Option {
+	return func(s *Server) {
+		s.computer.SetAutoRestart(true)
+	}
 }
 
 // NewServer creates a new web server.
-func NewServer(computer *emulator.MonTanaMiniComputer) *Server {
+func NewServer(computer *emulator.MonTanaMiniComputer, opts ...Option) *Server {
 	s := &Server{
 		computer:  computer,
 		templates: make(map[string]*template.Template),
+		logger:    slog.Default(),
+		addr:      DefaultAddr,
+		started:   time.Now(),
+		pongWait:  pongWait,
+		readDir:   disk.ReadDir,
+	}
+	for _, opt := range opts {
+		opt(s)
 	}
 	s.parseTemplates()
 	return s
@@ -44,35 +116,116 @@ func (s *Server) parseTemplates() {
 	s.templates["index"] = template.Must(template.ParseFS(templatesFS, "templates/index.html", "templates/layout.html"))
 }
 
-// Start begins listening for HTTP requests.
+// Start begins listening for HTTP requests and serves them until the
+// process exits.
 func (s *Server) Start() {
-	staticContent, err := fs.Sub(staticFS, "static")
-	if err != nil {
-		log.Fatal(err)
+	if _, err := s.Listen(); err != nil {
+		s.logger.Error("could not start server", "err", err)
+		os.Exit(1)
+	}
+
+	s.logger.Info("starting web server", "addr", s.Addr())
+	if err := s.Serve(); err != nil && !errors.Is(err, http.ErrServerClosed) {
+		s.logger.Error("server stopped", "err", err)
+		os.Exit(1)
 	}
-	http.Handle("/static/", http.StripPrefix("/static/", http.FileServer(http.FS(staticContent))))
+}
 
-	http.HandleFunc("/", s.handleIndex)
-	http.HandleFunc("/ws", s.handleWebSocket)
-	http.HandleFunc("/control", s.handleControl)
-	http.HandleFunc("/load", s.handleLoad)
+// Listen binds the server's listen address without serving requests yet,
+// returning the address actually bound. Start calls it automatically; call it
+// first when the bound port is needed up front, e.g. after WithAddr(":0").
+func (s *Server) Listen() (net.Addr, error) {
+	if s.listener == nil {
+		ln, err := net.Listen("tcp", s.addr)
+		if err != nil {
+			return nil, err
+		}
+		s.listener = ln
+		s.httpServer = &http.Server{Handler: s.Handler()}
+	}
+	return s.listener.Addr(), nil
+}
 
-	log.Println("Starting web server on :8080")
-	if err := http.ListenAndServe(":8080", nil); err != nil {
-		log.Fatalf("could not start server: %v", err)
+// Serve serves requests on the bound address, listening first if needed,
+// until Close is called, when it returns http.ErrServerClosed. Unlike Start
+// it returns errors rather than exiting, for embedding the server.
+func (s *Server) Serve() error {
+	if _, err := s.Listen(); err != nil {
+		return err
 	}
+	return s.httpServer.Serve(s.listener)
 }
 
-func (s *Server) handleIndex(w http.ResponseWriter, r *http.Request) {
-	files, err := fs.ReadDir(disk.FS, "disk/bin")
+// Close stops the server, closing its listener and any open connections.
+func (s *Server) Close() error {
+	if s.httpServer == nil {
+		return nil
+	}
+	return s.httpServer.Close()
+}
+
+// Addr returns the bound address once the server is listening, or the
+// configured address before then.
+func (s *Server) Addr() string {
+	if s.listener != nil {
+		return s.listener.Addr().String()
+	}
+	return s.addr
+}
+
+// Handler returns the server's routes.
+func (s *Server) Handler() http.Handler {
+	mux := http.NewServeMux()
+	staticContent, err := fs.Sub(staticFS, "static")
 	if err != nil {
-		http.Error(w, "could not read programs directory", http.StatusInternalServerError)
-		return
+		// The static directory is embedded at build time, so this cannot
+		// fail at runtime.
+		panic(err)
 	}
+	mux.Handle("/static/", http.StripPrefix("/static/", http.FileServer(http.FS(staticContent))))
+
+	mux.HandleFunc("/", s.handleIndex)
+	mux.HandleFunc("/ws", s.handleWebSocket)
+	mux.HandleFunc("/trace/ws", s.handleTraceWebSocket)
+	mux.HandleFunc("/control", s.limit(s.handleControl))
+	mux.HandleFunc("/load", s.limit(s.handleLoad))
+	mux.HandleFunc("/loaddata", s.limit(s.handleLoadData))
+	mux.HandleFunc("/heatmap", s.handleHeatmap)
+	mux.HandleFunc("/profile", s.handleProfile)
+	mux.HandleFunc("/file", s.limitWrites(s.handleFile))
+	mux.HandleFunc("/dump", s.handleDump)
+	mux.HandleFunc("/state", s.handleState)
+	mux.HandleFunc("/loaded", s.handleLoaded)
+	mux.HandleFunc("/input", s.limit(s.handleInput))
+	mux.HandleFunc("/breakpoints", s.limitWrites(s.handleBreakpoints))
+	mux.HandleFunc("/snapshots", s.handleSnapshots)
+	mux.HandleFunc("/snapshot/diff", s.limit(s.handleSnapshotDiff))
+	mux.HandleFunc("/coverage", s.handleCoverage)
+	mux.HandleFunc("/disasm", s.handleDisasm)
+	mux.HandleFunc("/callstack", s.handleCallStack)
+	mux.HandleFunc("/isa", s.handleISA)
+	mux.HandleFunc("/capabilities", s.handleCapabilities)
+	mux.HandleFunc("/flags", s.limitWrites(s.handleFlags))
+	mux.HandleFunc("/annotations", s.limitWrites(s.handleAnnotations))
+	mux.HandleFunc("/aliases", s.limitWrites(s.handleAliases))
+	mux.HandleFunc("/search", s.handleSearch)
+	mux.HandleFunc("/memory", s.limitWrites(s.handleMemory))
+	mux.HandleFunc("/healthz", s.handleHealth)
+	mux.HandleFunc("/metrics", s.handleMetrics)
+	return s.withCORS(s.withAuth(mux))
+}
 
-	var programs []string
-	for _, file := range files {
-		programs = append(programs, file.Name())
+func (s *Server) handleIndex(w http.ResponseWriter, r *http.Request) {
+	// A missing programs directory just means there is nothing to load;
+	// any other error is a real problem with the disk.
+	programs, err := s.readDir("disk/bin")
+	if errors.Is(err, fs.ErrNotExist) {
+		programs, err = []string{}, nil
+	}
+	if err != nil {
+		s.logger.Error("could not read programs directory", "err", err)
+		http.Error(w, "could not read programs directory: "+err.Error(), http.StatusInternalServerError)
+		return
 	}
 
 	data := s.computer.GetState()
@@ -87,75 +240,868 @@ func (s *Server) handleIndex(w http.ResponseWriter, r *http.Request) {
 func (s *Server) handleWebSocket(w http.ResponseWriter, r *http.Request) {
 	conn, err := upgrader.Upgrade(w, r, nil)
 	if err != nil {
-		log.Println(err)
+		s.logger.Warn("websocket upgrade failed", "err", err)
 		return
 	}
 	defer conn.Close()
 
-	// Register the WebSocket connection as an observer
-	observer := &WebSocketObserver{conn: conn}
+	// Register the WebSocket connection as an observer, acknowledging the
+	// subscription before any update can be sent.
+	observer := &WebSocketObserver{conn: conn, logger: s.logger, binary: r.URL.Query().Get("format") == "binary"}
+	observer.send(MessageAck, nil)
 	s.computer.AddObserver(observer)
+	defer s.computer.RemoveObserver(observer)
+	s.wsClients.Add(1)
+	defer s.wsClients.Add(-1)
+	<-s.watchConnection(conn)
+}
+
+// watchConnection keeps conn alive and returns a channel that is closed
+// once the client disconnects. It pings the client regularly and expects a
+// pong before the read deadline, so connections that die silently, e.g.
+// behind a NAT or proxy, are noticed too.
+func (s *Server) watchConnection(conn *websocket.Conn) <-chan struct{} {
+	conn.SetReadDeadline(time.Now().Add(s.pongWait))
+	conn.SetPongHandler(func(string) error {
+		return conn.SetReadDeadline(time.Now().Add(s.pongWait))
+	})
+	done := make(chan struct{})
+	go func() {
+		ticker := time.NewTicker(s.pongWait * 9 / 10)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-done:
+				return
+			case <-ticker.C:
+				if err := conn.WriteControl(websocket.PingMessage, nil, time.Now().Add(writeWait)); err != nil {
+					return
+				}
+			}
+		}
+	}()
+
+	// Read until the client disconnects or misses its pong. Reading is
+	// also what processes the pongs.
+	go func() {
+		defer close(done)
+		for {
+			if _, _, err := conn.NextReader(); err != nil {
+				s.logger.Debug("websocket closed", "err", err)
+				return
+			}
+		}
+	}()
+	return done
+}
+
+// Trace streaming limits: /trace/ws sends at most traceBatch entries every
+// traceInterval, so a machine running flat out cannot flood its clients.
+// Entries the machine outruns them by are reported as dropped.
+const (
+	traceInterval = 100 * time.Millisecond
+	traceBatch    = 512
+)
+
+// handleTraceWebSocket streams trace entries to a WebSocket client as the
+// machine records them, in MessageTrace messages, each a TraceBatch. The
+// stream starts with the next instruction executed, or at the since
+// parameter's trace position if given.
+func (s *Server) handleTraceWebSocket(w http.ResponseWriter, r *http.Request) {
+	if !s.computer.Capabilities().Features.Trace {
+		http.Error(w, "tracing is disabled", http.StatusNotFound)
+		return
+	}
+	next := s.computer.Trace(math.MaxUint64, 0).Next
+	if param := r.URL.Query().Get("since"); param != "" {
+		since, err := strconv.ParseUint(param, 10, 64)
+		if err != nil {
+			http.Error(w, "invalid since: "+param, http.StatusBadRequest)
+			return
+		}
+		next = since
+	}
+	conn, err := upgrader.Upgrade(w, r, nil)
+	if err != nil {
+		s.logger.Warn("websocket upgrade failed", "err", err)
+		return
+	}
+	defer conn.Close()
+	s.wsClients.Add(1)
+	defer s.wsClients.Add(-1)
 
-	// Keep the connection alive
+	observer := &WebSocketObserver{conn: conn, logger: s.logger}
+	observer.send(MessageAck, nil)
+	gone := s.watchConnection(conn)
+	ticker := time.NewTicker(traceInterval)
+	defer ticker.Stop()
 	for {
-		if _, _, err := conn.NextReader(); err != nil {
-			break
+		select {
+		case <-gone:
+			return
+		case <-ticker.C:
+			batch := s.computer.Trace(next, traceBatch)
+			next = batch.Next
+			if len(batch.Entries) > 0 || batch.Dropped > 0 {
+				observer.send(MessageTrace, batch)
+			}
 		}
 	}
 }
 
+// controlActions lists the actions handleControl accepts.
+var controlActions = []string{"run", "continue", "pause", "step", "reset", "restart", "reload", "delay", "speed", "skip", "stepback"}
+
 func (s *Server) handleControl(w http.ResponseWriter, r *http.Request) {
+	if !allowMethods(w, r, http.MethodGet, http.MethodPost) {
+		return
+	}
 	action := r.URL.Query().Get("action")
+	s.logger.Debug("control action", "action", action)
 	switch action {
 	case "run":
-		log.Println("sent action run")
-		s.computer.Running = true
+		s.computer.SetRunning(true)
+	case "continue":
+		s.computer.Continue()
 	case "pause":
-		log.Println("sent action pause")
-		s.computer.Running = false
+		s.computer.SetRunning(false)
 	case "step":
-		log.Println("sent action step")
-		s.computer.Step()
+		state := s.computer.StepState()
+		if wantsJSON(r) {
+			s.writeJSON(w, state)
+			return
+		}
 	case "reset":
-		log.Println("sent action reset")
-		s.computer.Registers[emulator.PC] = 0
-		s.computer.Running = false
+		s.computer.Reset()
+	case "restart":
+		if err := s.computer.Restart(); err != nil {
+			http.Error(w, "could not restart program: "+err.Error(), http.StatusBadRequest)
+			return
+		}
+	case "reload":
+		if err := s.reloadProgram(); err != nil {
+			http.Error(w, "could not reload program: "+err.Error(), http.StatusBadRequest)
+			return
+		}
+	case "delay":
+		ms, err := strconv.ParseUint(r.URL.Query().Get("ms"), 10, 32)
+		if err != nil {
+			http.Error(w, "delay needs a whole number of milliseconds in ms", http.StatusBadRequest)
+			return
+		}
+		s.computer.SetStepDelay(time.Duration(ms) * time.Millisecond)
+	case "speed":
+		steps, err := strconv.ParseUint(r.URL.Query().Get("steps"), 10, 16)
+		if err != nil || steps == 0 {
+			http.Error(w, "speed needs a positive number of instructions per tick in steps", http.StatusBadRequest)
+			return
+		}
+		s.computer.SetStepsPerTick(int(steps))
+	case "skip":
+		s.computer.SkipInstruction()
+	case "stepback":
+		if err := s.computer.StepBack(); err != nil {
+			http.Error(w, "could not step back: "+err.Error(), http.StatusConflict)
+			return
+		}
+		if wantsJSON(r) {
+			s.writeJSON(w, s.computer.GetState())
+			return
+		}
+	default:
+		http.Error(w, fmt.Sprintf("unknown action %q; valid actions are: %s",
+			action, strings.Join(controlActions, ", ")), http.StatusBadRequest)
+		return
 	}
-	http.Redirect(w, r, "/", http.StatusFound)
+	s.respond(w, r, map[string]interface{}{"ok": true, "action": action})
 }
 
+// handleLoad loads a program from disk/bin: an ELF executable, assembly
+// source ending in .asm, or a flat binary for address 0. For flat binaries
+// the optional entry parameter sets the starting PC when it differs from the
+// load address.
 func (s *Server) handleLoad(w http.ResponseWriter, r *http.Request) {
+	if !allowMethods(w, r, http.MethodGet, http.MethodPost) {
+		return
+	}
 	programName := r.URL.Query().Get("program")
 	if programName == "" {
 		http.Error(w, "program name is required", http.StatusBadRequest)
 		return
 	}
+	entry, err := parseUintParam(r.URL.Query().Get("entry"), 0)
+	if err != nil {
+		http.Error(w, "invalid entry: "+err.Error(), http.StatusBadRequest)
+		return
+	}
 
-	program, err := fs.ReadFile(disk.FS, "disk/bin/"+programName)
+	program, err := disk.ReadFile("disk/bin/" + programName)
 	if err != nil {
 		http.Error(w, "could not read program", http.StatusInternalServerError)
-		log.Println(err)
+		s.logger.Error("could not read program", "program", programName, "err", err)
+		return
+	}
+
+	img, flat, err := programImage(programName, program, entry, s.computer.RegisterAliases())
+	switch {
+	case err != nil:
+	case flat:
+		err = s.computer.LoadNamedProgram(programName, program, 0, entry)
+	default:
+		err = s.computer.LoadImage(programName, img)
+	}
+	if err != nil {
+		http.Error(w, "could not load program: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+	s.respond(w, r, map[string]interface{}{"ok": true, "program": programName})
+}
+
+// handleLoadData places the request body in memory at the address
+// parameter as data for the program, without changing the PC.
+func (s *Server) handleLoadData(w http.ResponseWriter, r *http.Request) {
+	if !allowMethods(w, r, http.MethodPost, http.MethodPut) {
+		return
+	}
+	addr, err := parseUintParam(r.URL.Query().Get("address"), 0)
+	if err != nil {
+		http.Error(w, "invalid address: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+	data, err := io.ReadAll(r.Body)
+	if err != nil {
+		http.Error(w, "could not read request body", http.StatusBadRequest)
+		return
+	}
+	if err := s.computer.LoadData(data, addr); err != nil {
+		http.Error(w, "could not load data: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+	s.writeJSON(w, map[string]interface{}{"ok": true, "address": addr, "length": len(data)})
+}
+
+// reloadProgram re-reads the currently loaded program from disk, so edits
+// are picked up, and reloads it into a freshly cleared machine.
+func (s *Server) reloadProgram() error {
+	name := s.computer.ProgramName()
+	if name == "" {
+		return s.computer.ReloadProgram(nil)
+	}
+	program, err := disk.ReadFile("disk/bin/" + name)
+	if err != nil {
+		return err
+	}
+	img, _, err := programImage(name, program, s.computer.EntryPoint(), s.computer.RegisterAliases())
+	if err != nil {
+		return err
+	}
+	return s.computer.ReloadProgram(&img)
+}
+
+// handleHeatmap reports per-address memory read/write counts for the range
+// given by the start and count parameters. Passing enabled=true or
+// enabled=false switches access counting on or off first.
+func (s *Server) handleHeatmap(w http.ResponseWriter, r *http.Request) {
+	query := r.URL.Query()
+	if enabled := query.Get("enabled"); enabled != "" {
+		on, err := strconv.ParseBool(enabled)
+		if err != nil {
+			http.Error(w, "enabled must be true or false", http.StatusBadRequest)
+			return
+		}
+		s.computer.SetAccessCounting(on)
+	}
+
+	start, err := parseUintParam(query.Get("start"), 0)
+	if err != nil {
+		http.Error(w, "invalid start: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+	count, err := parseUintParam(query.Get("count"), 256)
+	if err != nil {
+		http.Error(w, "invalid count: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	reads, writes := s.computer.Heatmap(int(start), int(count))
+	s.writeJSON(w, map[string]interface{}{
+		"start":  start,
+		"reads":  reads,
+		"writes": writes,
+	})
+}
+
+// tickTimeout is how long the emulator's clock may go without ticking
+// before /healthz reports the execution loop as dead.
+const tickTimeout = time.Second
+
+// handleHealth is a liveness check for load balancers and orchestrators. It
+// always answers 200 while the server is up, and reports how long it has been
+// up, whether the emulator's execution loop is ticking and how many observers
+// (mostly WebSocket clients) are connected.
+func (s *Server) handleHealth(w http.ResponseWriter, r *http.Request) {
+	lastTick := s.computer.LastTick()
+	s.writeJSON(w, map[string]interface{}{
+		"status":    "ok",
+		"uptime":    time.Since(s.started).Round(time.Second).String(),
+		"emulator":  !lastTick.IsZero() && time.Since(lastTick) < tickTimeout,
+		"observers": s.computer.ObserverCount(),
+	})
+}
+
+// handleState reports the machine state as JSON, in the same shape as the
+// WebSocket state pushes, for clients that would rather poll.
+func (s *Server) handleState(w http.ResponseWriter, r *http.Request) {
+	s.writeJSON(w, s.computer.GetState())
+}
+
+// handleLoaded reports the name of the currently loaded program, which is
+// empty if nothing has been loaded since the last reset.
+func (s *Server) handleLoaded(w http.ResponseWriter, r *http.Request) {
+	s.writeJSON(w, map[string]string{"program": s.computer.ProgramName()})
+}
+
+// handleInput queues the request body as input for the program's IN
+// instruction.
+func (s *Server) handleInput(w http.ResponseWriter, r *http.Request) {
+	if !allowMethods(w, r, http.MethodPost, http.MethodPut) {
+		return
+	}
+	data, err := io.ReadAll(r.Body)
+	if err != nil {
+		http.Error(w, "could not read request body", http.StatusBadRequest)
+		return
+	}
+	s.computer.WriteInput(data)
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// handleBreakpoints lists the breakpoints on GET, adds the one at the
+// address parameter on POST, and on DELETE removes the one at address, or
+// all of them if address is omitted. A POST may give a condition parameter
+// such as "R1 == 5" to make the breakpoint conditional. It always answers
+// with the resulting list.
+func (s *Server) handleBreakpoints(w http.ResponseWriter, r *http.Request) {
+	if !allowMethods(w, r, http.MethodGet, http.MethodPost, http.MethodDelete) {
+		return
+	}
+	param := r.URL.Query().Get("address")
+	var addr uint16
+	if param != "" || r.Method == http.MethodPost {
+		n, err := strconv.ParseUint(param, 0, 16)
+		if err != nil {
+			http.Error(w, "invalid address: "+param, http.StatusBadRequest)
+			return
+		}
+		addr = uint16(n)
+	}
+	switch {
+	case r.Method == http.MethodPost && r.URL.Query().Has("condition"):
+		cond, err := emulator.ParseCondition(r.URL.Query().Get("condition"))
+		if err != nil {
+			http.Error(w, "invalid condition: "+err.Error(), http.StatusBadRequest)
+			return
+		}
+		if err := s.computer.AddConditionalBreakpoint(addr, cond); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+	case r.Method == http.MethodPost:
+		if err := s.computer.AddBreakpoint(addr); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+	case r.Method == http.MethodDelete && param == "":
+		s.computer.ClearBreakpoints()
+	case r.Method == http.MethodDelete:
+		s.computer.RemoveBreakpoint(addr)
+	}
+	s.writeJSON(w, s.computer.Breakpoints())
+}
+
+// handleSnapshots lists the snapshots captured at breakpoints, oldest
+// first, optionally only those at the address parameter.
+func (s *Server) handleSnapshots(w http.ResponseWriter, r *http.Request) {
+	snapshots := s.computer.BreakpointSnapshots()
+	if param := r.URL.Query().Get("address"); param != "" {
+		addr, err := strconv.ParseUint(param, 0, 16)
+		if err != nil {
+			http.Error(w, "invalid address: "+param, http.StatusBadRequest)
+			return
+		}
+		snapshots = slices.DeleteFunc(snapshots, func(b emulator.BreakpointSnapshot) bool {
+			return b.Address != uint16(addr)
+		})
+	}
+	s.writeJSON(w, snapshots)
+}
+
+// handleSnapshotDiff compares the two snapshots posted as the a and b
+// fields of a JSON object and reports what changed from a to b.
+func (s *Server) handleSnapshotDiff(w http.ResponseWriter, r *http.Request) {
+	if !allowMethods(w, r, http.MethodPost) {
+		return
+	}
+	var body struct {
+		A json.RawMessage `json:"a"`
+		B json.RawMessage `json:"b"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+		http.Error(w, "invalid request body: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+	if body.A == nil || body.B == nil {
+		http.Error(w, "a and b snapshots are required", http.StatusBadRequest)
+		return
+	}
+	diff, err := emulator.DiffSnapshots(body.A, body.B)
+	if err != nil {
+		http.Error(w, "could not compare snapshots: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+	s.writeJSON(w, diff)
+}
+
+// handleDisasm lists count instructions (default 64) of memory from the
+// start address, with the source line of each where the loaded program was
+// assembled from source.
+func (s *Server) handleDisasm(w http.ResponseWriter, r *http.Request) {
+	query := r.URL.Query()
+	start, err := parseUintParam(query.Get("start"), 0)
+	if err != nil {
+		http.Error(w, "invalid start: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+	count, err := parseUintParam(query.Get("count"), 64)
+	if err != nil {
+		http.Error(w, "invalid count: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+	s.writeJSON(w, s.computer.DisassembleMemory(start, int(count)))
+}
+
+// handleAnnotations lists the address annotations on GET. POST sets the
+// note parameter as the annotation of the address parameter, and DELETE
+// removes the annotation at address.
+func (s *Server) handleAnnotations(w http.ResponseWriter, r *http.Request) {
+	if !allowMethods(w, r, http.MethodGet, http.MethodPost, http.MethodDelete) {
+		return
+	}
+	if r.Method != http.MethodGet {
+		param := r.URL.Query().Get("address")
+		addr, err := strconv.ParseUint(param, 0, 16)
+		if err != nil {
+			http.Error(w, "invalid address: "+param, http.StatusBadRequest)
+			return
+		}
+		note := r.URL.Query().Get("note")
+		if r.Method == http.MethodPost && note == "" {
+			http.Error(w, "note is required", http.StatusBadRequest)
+			return
+		}
+		if r.Method == http.MethodDelete {
+			note = ""
+		}
+		s.computer.AnnotateAddress(uint16(addr), note)
+	}
+	s.writeJSON(w, s.computer.Annotations(0, math.MaxUint16+1))
+}
+
+// handleAliases lists the register aliases, by canonical name, on GET. POST
+// makes the alias parameter the alias of the register parameter, and DELETE
+// removes register's alias.
+func (s *Server) handleAliases(w http.ResponseWriter, r *http.Request) {
+	if !allowMethods(w, r, http.MethodGet, http.MethodPost, http.MethodDelete) {
+		return
+	}
+	if r.Method != http.MethodGet {
+		query := r.URL.Query()
+		alias := query.Get("alias")
+		if r.Method == http.MethodPost && alias == "" {
+			http.Error(w, "alias is required", http.StatusBadRequest)
+			return
+		}
+		if r.Method == http.MethodDelete {
+			alias = ""
+		}
+		if err := s.computer.SetRegisterAlias(query.Get("register"), alias); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+	}
+	s.writeJSON(w, s.computer.RegisterAliases())
+}
+
+// handleFlags reports the status register's flags. A POST first sets each
+// flag named by a parameter, such as zero=true or carry=false.
+func (s *Server) handleFlags(w http.ResponseWriter, r *http.Request) {
+	if !allowMethods(w, r, http.MethodGet, http.MethodPost) {
+		return
+	}
+	if r.Method == http.MethodPost {
+		query := r.URL.Query()
+		updates := make(map[uint16]bool, len(query))
+		for name := range query {
+			flag, ok := emulator.FlagNames[name]
+			if !ok {
+				http.Error(w, "unknown flag: "+name, http.StatusBadRequest)
+				return
+			}
+			on, err := strconv.ParseBool(query.Get(name))
+			if err != nil {
+				http.Error(w, name+" must be true or false", http.StatusBadRequest)
+				return
+			}
+			updates[flag] = on
+		}
+		for flag, on := range updates {
+			s.computer.SetFlag(flag, on)
+		}
+	}
+	s.writeJSON(w, s.computer.Flags())
+}
+
+// handleISA describes the instruction set.
+func (s *Server) handleISA(w http.ResponseWriter, r *http.Request) {
+	s.writeJSON(w, emulator.ISA())
+}
+
+// handleCapabilities reports the implemented instructions and which optional
+// features the machine has.
+func (s *Server) handleCapabilities(w http.ResponseWriter, r *http.Request) {
+	s.writeJSON(w, s.computer.Capabilities())
+}
+
+// handleCallStack reports the reconstructed call stack, innermost frame
+// first.
+func (s *Server) handleCallStack(w http.ResponseWriter, r *http.Request) {
+	s.writeJSON(w, s.computer.CallStack())
+}
+
+// handleCoverage reports which instruction addresses of the loaded program
+// have executed and which have not.
+func (s *Server) handleCoverage(w http.ResponseWriter, r *http.Request) {
+	executed, unexecuted := s.computer.Coverage()
+	s.writeJSON(w, map[string][]uint16{
+		"executed":   executed,
+		"unexecuted": unexecuted,
+	})
+}
+
+// handleMemory reads or writes memory at the address parameter. GET returns
+// length bytes (default 2) as hex in JSON; PUT or POST writes the request
+// body's raw bytes, so a device or test harness can poke values in.
+func (s *Server) handleMemory(w http.ResponseWriter, r *http.Request) {
+	if !allowMethods(w, r, http.MethodGet, http.MethodPut, http.MethodPost) {
+		return
+	}
+	query := r.URL.Query()
+	addr, err := parseUintParam(query.Get("address"), 0)
+	if err != nil {
+		http.Error(w, "invalid address: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	if r.Method == http.MethodGet {
+		length, err := parseUintParam(query.Get("length"), 2)
+		if err != nil {
+			http.Error(w, "invalid length: "+err.Error(), http.StatusBadRequest)
+			return
+		}
+		data, err := s.computer.ReadMemory(addr, int(length))
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		s.writeJSON(w, map[string]interface{}{
+			"address":     addr,
+			"data":        hex.EncodeToString(data),
+			"annotations": s.computer.Annotations(int(addr), int(addr)+len(data)),
+		})
+		return
+	}
+
+	data, err := io.ReadAll(r.Body)
+	if err != nil {
+		http.Error(w, "could not read request body", http.StatusBadRequest)
+		return
+	}
+	if err := s.computer.WriteMemory(addr, data); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// maxSearchResults caps how many addresses /search returns.
+const maxSearchResults = 256
+
+// handleSearch finds where a value occurs in memory. Either value gives a
+// number to find as a byte or, with size=word, a big-endian word; or pattern
+// gives any sequence of bytes in hex, such as "DEADBEEF".
+func (s *Server) handleSearch(w http.ResponseWriter, r *http.Request) {
+	query := r.URL.Query()
+	var pattern []byte
+	if hexPattern := query.Get("pattern"); hexPattern != "" {
+		var err error
+		if pattern, err = hex.DecodeString(hexPattern); err != nil {
+			http.Error(w, "pattern must be hex bytes: "+err.Error(), http.StatusBadRequest)
+			return
+		}
+	} else {
+		bits := 8
+		switch size := query.Get("size"); size {
+		case "", "byte":
+		case "word":
+			bits = 16
+		default:
+			http.Error(w, "size must be byte or word", http.StatusBadRequest)
+			return
+		}
+		value, err := strconv.ParseUint(query.Get("value"), 0, bits)
+		if err != nil {
+			http.Error(w, fmt.Sprintf("value must be a %d-bit number", bits), http.StatusBadRequest)
+			return
+		}
+		if bits == 16 {
+			pattern = binary.BigEndian.AppendUint16(nil, uint16(value))
+		} else {
+			pattern = []byte{byte(value)}
+		}
+	}
+
+	addrs, truncated := s.computer.SearchMemory(pattern, maxSearchResults)
+	s.writeJSON(w, map[string]interface{}{
+		"addresses": addrs,
+		"truncated": truncated,
+	})
+}
+
+// handleProfile reports how many times each opcode has executed.
+func (s *Server) handleProfile(w http.ResponseWriter, r *http.Request) {
+	s.writeJSON(w, s.computer.Profile())
+}
+
+// handleFile reads (GET) or writes (PUT/POST) a file on the disk, named
+// relative to the disk root, e.g. /file?name=data/out.txt. Written files go
+// to the disk's writable overlay and shadow embedded files of the same name.
+func (s *Server) handleFile(w http.ResponseWriter, r *http.Request) {
+	name := r.URL.Query().Get("name")
+	if name == "" {
+		http.Error(w, "file name is required", http.StatusBadRequest)
+		return
+	}
+	name = "disk/" + name
+
+	switch r.Method {
+	case http.MethodGet:
+		data, err := disk.ReadFile(name)
+		if err != nil {
+			http.Error(w, "could not read file", http.StatusNotFound)
+			return
+		}
+		w.Header().Set("Content-Type", "application/octet-stream")
+		w.Write(data)
+	case http.MethodPut, http.MethodPost:
+		data, err := io.ReadAll(r.Body)
+		if err != nil {
+			http.Error(w, "could not read request body", http.StatusBadRequest)
+			return
+		}
+		if err := disk.WriteFile(name, data); err != nil {
+			http.Error(w, "could not write file: "+err.Error(), http.StatusBadRequest)
+			s.logger.Warn("could not write file", "name", name, "err", err)
+			return
+		}
+		w.WriteHeader(http.StatusNoContent)
+	default:
+		w.Header().Set("Allow", "GET, PUT, POST")
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+	}
+}
+
+// handleDump downloads memory as a raw .bin image. The optional start and
+// length parameters select a range; by default all of memory is returned.
+// The PC at the time of the dump is reported in the X-MTMC-PC header so the
+// image can be reloaded with execution resuming where it left off. With
+// format=c the range is instead written as a C array, named by the name
+// parameter (default "prog"), for embedding in firmware.
+func (s *Server) handleDump(w http.ResponseWriter, r *http.Request) {
+	query := r.URL.Query()
+	start, err := parseUintParam(query.Get("start"), 0)
+	if err != nil {
+		http.Error(w, "invalid start: "+err.Error(), http.StatusBadRequest)
 		return
 	}
+	length := s.computer.MemorySize()
+	if l := query.Get("length"); l != "" {
+		n, err := strconv.ParseUint(l, 0, 32)
+		if err != nil {
+			http.Error(w, "invalid length: "+err.Error(), http.StatusBadRequest)
+			return
+		}
+		length = int(n)
+	}
+
+	data, pc := s.computer.DumpMemory(int(start), length)
+	if query.Get("format") == "c" {
+		name := query.Get("name")
+		if name == "" {
+			name = "prog"
+		}
+		var buf bytes.Buffer
+		if err := emulator.WriteCArray(&buf, name, data, start, s.computer.EntryPoint()); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		w.Header().Set("Content-Type", "text/x-c; charset=utf-8")
+		w.Header().Set("Content-Disposition", `attachment; filename="`+name+`.c"`)
+		w.Write(buf.Bytes())
+		return
+	}
+	w.Header().Set("Content-Type", "application/octet-stream")
+	w.Header().Set("Content-Disposition", `attachment; filename="mtmc-memory.bin"`)
+	w.Header().Set("X-MTMC-PC", strconv.Itoa(int(pc)))
+	w.Header().Set("X-MTMC-Start", strconv.Itoa(int(start)))
+	w.Write(data)
+}
+
+// parseUintParam parses a 16-bit query parameter, accepting decimal or
+// 0x-prefixed hex, and returns def when the parameter is absent.
+func parseUintParam(value string, def uint16) (uint16, error) {
+	if value == "" {
+		return def, nil
+	}
+	n, err := strconv.ParseUint(value, 0, 16)
+	if err != nil {
+		return 0, err
+	}
+	return uint16(n), nil
+}
 
-	s.computer.LoadProgram(program, 0)
+// allowMethods replies 405 Method Not Allowed, and reports false, unless the
+// request uses one of methods.
+func allowMethods(w http.ResponseWriter, r *http.Request, methods ...string) bool {
+	if slices.Contains(methods, r.Method) {
+		return true
+	}
+	w.Header().Set("Allow", strings.Join(methods, ", "))
+	http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+	return false
+}
+
+// respond finishes a request that changed the machine. API clients that
+// accept JSON get body; browsers following a link or submitting a form are
+// redirected back to the index page.
+func (s *Server) respond(w http.ResponseWriter, r *http.Request, body interface{}) {
+	if wantsJSON(r) {
+		s.writeJSON(w, body)
+		return
+	}
 	http.Redirect(w, r, "/", http.StatusFound)
 }
 
+// wantsJSON reports whether the client asked for a JSON response.
+func wantsJSON(r *http.Request) bool {
+	for _, accept := range r.Header.Values("Accept") {
+		for _, mediaType := range strings.Split(accept, ",") {
+			mediaType, _, _ = strings.Cut(mediaType, ";")
+			if strings.TrimSpace(mediaType) == "application/json" {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// writeJSON encodes v as the JSON response body.
+func (s *Server) writeJSON(w http.ResponseWriter, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(v); err != nil {
+		s.logger.Error("could not encode response", "err", err)
+	}
+}
+
+// WebSocket message types. Every message is an envelope of the form
+// {"type": ..., "payload": ...}, so clients can tell kinds apart and new
+// kinds can be added without breaking them.
+const (
+	// MessageAck confirms the connection is subscribed to updates. It is
+	// the first message sent and its payload is empty.
+	MessageAck = "ack"
+	// MessageState carries the machine state, as GetState returns it.
+	MessageState = "state"
+	// MessageOutput carries, as a string, the output the program wrote
+	// since the last output message.
+	MessageOutput = "output"
+	// MessageFault carries a fault, as LastFault returns it, once for each
+	// fault.
+	MessageFault = "fault"
+	// MessageTrace carries newly executed instructions, as an
+	// emulator.TraceBatch. It is only sent on /trace/ws.
+	MessageTrace = "trace"
+)
+
+// message is the envelope every WebSocket message is sent in.
+type message struct {
+	Type    string      `json:"type"`
+	Payload interface{} `json:"payload,omitempty"`
+}
+
 // WebSocketObserver sends computer state updates to a WebSocket client.
 type WebSocketObserver struct {
-	conn *websocket.Conn
+	conn   *websocket.Conn
+	logger *slog.Logger
+
+	binary     bool            // send state as binary frames; see FrameState
+	sentOutput int             // how much of the output has been sent
+	sentFault  *emulator.Fault // the last fault sent
 }
 
 // Update sends the computer's state to the WebSocket client.
 func (o *WebSocketObserver) Update(computer *emulator.MonTanaMiniComputer) {
-	state := computer.GetState()
-	data, err := json.Marshal(state)
+	o.UpdateState(computer.GetState())
+}
+
+// UpdateState sends state to the WebSocket client, preceded by any new
+// output and fault it reports.
+func (o *WebSocketObserver) UpdateState(state map[string]interface{}) {
+	if output, _ := state["output"].(string); len(output) != o.sentOutput {
+		if len(output) < o.sentOutput {
+			// The machine was reset, so everything is new.
+			o.sentOutput = 0
+		}
+		if len(output) > o.sentOutput {
+			o.send(MessageOutput, output[o.sentOutput:])
+		}
+		o.sentOutput = len(output)
+	}
+	if fault, _ := state["fault"].(*emulator.Fault); fault != nil && fault != o.sentFault {
+		o.send(MessageFault, fault)
+		o.sentFault = fault
+	}
+	if o.binary {
+		o.write(websocket.BinaryMessage, encodeStateFrame(state))
+		return
+	}
+	o.send(MessageState, state)
+}
+
+// send writes a message of kind typ to the client as JSON.
+func (o *WebSocketObserver) send(typ string, payload interface{}) {
+	data, err := json.Marshal(message{Type: typ, Payload: payload})
 	if err != nil {
-		log.Println("Error marshalling state:", err)
+		o.logger.Error("could not marshal websocket message", "type", typ, "err", err)
 		return
 	}
-	if err := o.conn.WriteMessage(websocket.TextMessage, data); err != nil {
+	o.write(websocket.TextMessage, data)
+}
+
+// write sends one frame of the given WebSocket message type. A client that
+// does not accept it within writeWait is treated as gone.
+func (o *WebSocketObserver) write(messageType int, data []byte) {
+	o.conn.SetWriteDeadline(time.Now().Add(writeWait))
+	if err := o.conn.WriteMessage(messageType, data); err != nil {
 		// Client has likely disconnected
 	}
 }
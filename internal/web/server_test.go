@@ -0,0 +1,1100 @@
+package web
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io/fs"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/catdevman/go-mtmc/internal/disk"
+	"github.com/catdevman/go-mtmc/internal/emulator"
+)
+
+func TestTraceWebSocket(t *testing.T) {
+	computer := newTestComputer(emulator.WithTrace(64))
+	if err := computer.LoadProgram(make([]byte, 8), 0); err != nil { // four NOPs
+		t.Fatal(err)
+	}
+	srv := httptest.NewServer(newTestServer(computer).Handler())
+	defer srv.Close()
+	conn := dial(t, srv, "/trace/ws")
+
+	// receive collects trace entries until it has n, checking they
+	// continue in order from seq.
+	receive := func(seq uint64, n int) []emulator.TraceEntry {
+		t.Helper()
+		var entries []emulator.TraceEntry
+		for len(entries) < n {
+			typ, payload := readMessage(t, conn)
+			if typ != MessageTrace {
+				t.Fatalf("got %q message, want %q", typ, MessageTrace)
+			}
+			var batch emulator.TraceBatch
+			if err := json.Unmarshal(payload, &batch); err != nil {
+				t.Fatal(err)
+			}
+			if batch.Dropped != 0 {
+				t.Fatalf("%d entries dropped", batch.Dropped)
+			}
+			for _, e := range batch.Entries {
+				if e.Seq != seq {
+					t.Fatalf("got entry %d, want %d", e.Seq, seq)
+				}
+				seq++
+			}
+			entries = append(entries, batch.Entries...)
+		}
+		if len(entries) != n {
+			t.Fatalf("got %d entries, want %d", len(entries), n)
+		}
+		return entries
+	}
+
+	for range 3 {
+		computer.StepState()
+	}
+	for i, e := range receive(0, 3) {
+		if want := uint16(2 * i); e.PC != want {
+			t.Errorf("entry %d at 0x%04X, want 0x%04X", e.Seq, e.PC, want)
+		}
+	}
+	computer.StepState()
+	if e := receive(3, 1)[0]; e.PC != 6 {
+		t.Errorf("entry %d at 0x%04X, want 0x0006", e.Seq, e.PC)
+	}
+}
+
+func TestTraceWebSocketDisabled(t *testing.T) {
+	h := newTestServer(newTestComputer()).Handler()
+	if code := do(h, http.MethodGet, "/trace/ws", "", "192.0.2.1:1234"); code != http.StatusNotFound {
+		t.Errorf("status %d, want %d", code, http.StatusNotFound)
+	}
+}
+
+func TestLoadDataSizeLimit(t *testing.T) {
+	tests := []struct {
+		name    string
+		address int
+		size    int
+		status  int
+	}{
+		{"all of memory", 0, emulator.MemorySize, http.StatusOK},
+		{"one byte over", 0, emulator.MemorySize + 1, http.StatusBadRequest},
+		{"up to the end", 4, emulator.MemorySize - 4, http.StatusOK},
+		{"past the end", 4, emulator.MemorySize - 3, http.StatusBadRequest},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			h := newTestServer(newTestComputer()).Handler()
+			target := fmt.Sprintf("/loaddata?address=%d", tt.address)
+			if code := do(h, http.MethodPost, target, strings.Repeat("x", tt.size), "192.0.2.1:1234"); code != tt.status {
+				t.Errorf("status %d, want %d", code, tt.status)
+			}
+		})
+	}
+}
+
+// TestControlPerMachine checks that each server's control endpoints act on
+// its own machine only, so users given separate machines do not interfere.
+func TestControlPerMachine(t *testing.T) {
+	a, b := newTestComputer(), newTestComputer()
+	h := newTestServer(a).Handler()
+	newTestServer(b) // b has a server of its own, which nothing is sent to
+
+	for _, target := range []string{"/control?action=run", "/control?action=speed&steps=4", "/control?action=delay&ms=5"} {
+		if code := do(h, http.MethodPost, target, "", "192.0.2.1:1234"); code >= http.StatusBadRequest {
+			t.Fatalf("POST %s: status %d", target, code)
+		}
+	}
+	if !a.IsRunning() || a.StepsPerTick() != 4 || a.StepDelay() != 5*time.Millisecond {
+		t.Errorf("controlled machine: running %v, %d steps per tick, delay %v", a.IsRunning(), a.StepsPerTick(), a.StepDelay())
+	}
+	if b.IsRunning() || b.StepsPerTick() != 1 || b.StepDelay() != 0 {
+		t.Errorf("other machine: running %v, %d steps per tick, delay %v; want the defaults", b.IsRunning(), b.StepsPerTick(), b.StepDelay())
+	}
+}
+
+func TestDump(t *testing.T) {
+	program := []byte{0xB1, 0x10, 0xF0, 0x00, 0xDE, 0xAD}
+	newComputer := func(t *testing.T, size int) *emulator.MonTanaMiniComputer {
+		t.Helper()
+		computer := newTestComputer(emulator.WithMemorySize(size))
+		if err := computer.LoadProgramAt(program, 0, 2); err != nil {
+			t.Fatal(err)
+		}
+		return computer
+	}
+
+	tests := []struct {
+		name   string
+		size   int
+		target string
+		start  int
+		length int
+	}{
+		{"all of memory", emulator.MemorySize, "/dump", 0, emulator.MemorySize},
+		{"range", emulator.MemorySize, "/dump?start=2&length=4", 2, 4},
+		{"clipped to memory", emulator.MemorySize, "/dump?start=4&length=100", 4, emulator.MemorySize - 4},
+		{"all of larger memory", 0x400, "/dump", 0, 0x400},
+		{"rest of larger memory", 0x400, "/dump?start=0x100", 0x100, 0x300},
+		{"all of smaller memory", 8, "/dump", 0, 8},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			computer := newComputer(t, tt.size)
+			h := newTestServer(computer).Handler()
+			w := serve(h, httptest.NewRequest(http.MethodGet, tt.target, nil))
+			if w.Code != http.StatusOK {
+				t.Fatalf("status %d: %s", w.Code, w.Body)
+			}
+			want, _ := computer.DumpMemory(tt.start, tt.length)
+			if got := w.Body.Bytes(); len(got) != tt.length {
+				t.Errorf("body has %d bytes, want %d", len(got), tt.length)
+			} else if !bytes.Equal(got, want) {
+				t.Errorf("body = % X, want % X", got, want)
+			}
+			if pc := w.Header().Get("X-MTMC-PC"); pc != "2" {
+				t.Errorf("X-MTMC-PC = %q, want \"2\"", pc)
+			}
+			if start := w.Header().Get("X-MTMC-Start"); start != strconv.Itoa(tt.start) {
+				t.Errorf("X-MTMC-Start = %q, want %d", start, tt.start)
+			}
+			if ct := w.Header().Get("Content-Type"); ct != "application/octet-stream" {
+				t.Errorf("Content-Type = %q", ct)
+			}
+		})
+	}
+
+	h := newTestServer(newComputer(t, emulator.MemorySize)).Handler()
+	w := serve(h, httptest.NewRequest(http.MethodGet, "/dump?start=zz", nil))
+	if w.Code != http.StatusBadRequest {
+		t.Errorf("invalid start: status %d, want %d", w.Code, http.StatusBadRequest)
+	}
+}
+
+func TestListenEphemeralPort(t *testing.T) {
+	s := newTestServer(newTestComputer(), WithAddr("127.0.0.1:0"))
+	addr, err := s.Listen()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer s.Close()
+	port := addr.(*net.TCPAddr).Port
+	if port == 0 {
+		t.Fatal("Listen on port 0 resolved to port 0")
+	}
+	if want := "127.0.0.1:" + strconv.Itoa(port); s.Addr() != want {
+		t.Errorf("Addr() = %q, want %q", s.Addr(), want)
+	}
+
+	done := make(chan error, 1)
+	go func() { done <- s.Serve() }()
+	resp, err := http.Get("http://" + s.Addr() + "/healthz")
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		t.Errorf("GET /healthz: status %d, want %d", resp.StatusCode, http.StatusOK)
+	}
+	s.Close()
+	if err := <-done; err != http.ErrServerClosed {
+		t.Errorf("Serve returned %v, want %v", err, http.ErrServerClosed)
+	}
+}
+
+func TestState(t *testing.T) {
+	computer := newTestComputer()
+	h := newTestServer(computer).Handler()
+
+	w := serve(h, httptest.NewRequest(http.MethodGet, "/state", nil))
+	if w.Code != http.StatusOK {
+		t.Fatalf("status %d, want %d", w.Code, http.StatusOK)
+	}
+	if ct := w.Header().Get("Content-Type"); ct != "application/json" {
+		t.Errorf("Content-Type = %q, want application/json", ct)
+	}
+	var state map[string]json.RawMessage
+	if err := json.Unmarshal(w.Body.Bytes(), &state); err != nil {
+		t.Fatalf("decoding state: %v", err)
+	}
+	for _, key := range []string{"registers", "namedRegisters", "running", "memory"} {
+		if _, ok := state[key]; !ok {
+			t.Errorf("state has no %q key", key)
+		}
+	}
+	var registers []uint16
+	if err := json.Unmarshal(state["registers"], &registers); err != nil || len(registers) != len(computer.Registers) {
+		t.Errorf("registers = %s, want %d values", state["registers"], len(computer.Registers))
+	}
+}
+
+func TestControlStepReturnsState(t *testing.T) {
+	computer := newTestComputer()
+	if err := computer.LoadProgram(make([]byte, 8), 0); err != nil { // four NOPs
+		t.Fatal(err)
+	}
+	h := newTestServer(computer).Handler()
+
+	r := httptest.NewRequest(http.MethodPost, "/control?action=step", nil)
+	r.Header.Set("Accept", "application/json")
+	w := serve(h, r)
+	if w.Code != http.StatusOK {
+		t.Fatalf("status %d, want %d", w.Code, http.StatusOK)
+	}
+	var state struct {
+		PC               uint16 `json:"pc"`
+		InstructionCount uint64 `json:"instructionCount"`
+	}
+	if err := json.Unmarshal(w.Body.Bytes(), &state); err != nil {
+		t.Fatalf("decoding state: %v", err)
+	}
+	if state.PC != 2 || state.InstructionCount != 1 {
+		t.Errorf("after one step: pc %d, instructionCount %d; want 2, 1", state.PC, state.InstructionCount)
+	}
+}
+
+func TestRespond(t *testing.T) {
+	const name = "web-test-respond.bin"
+	if err := disk.WriteFile("disk/bin/"+name, []byte{0xF0, 0}); err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		name   string
+		target string
+		accept string
+		want   string // the JSON body, or "" for a redirect
+	}{
+		{"control form", "/control?action=run", "", ""},
+		{"control API", "/control?action=run", "application/json", `{"action":"run","ok":true}`},
+		{"control API among others", "/control?action=pause", "text/html, application/json;q=0.9", `{"action":"pause","ok":true}`},
+		{"load form", "/load?program=" + name, "text/html", ""},
+		{"load API", "/load?program=" + name, "application/json", `{"ok":true,"program":"` + name + `"}`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			h := newTestServer(newTestComputer()).Handler()
+			r := httptest.NewRequest(http.MethodPost, tt.target, nil)
+			if tt.accept != "" {
+				r.Header.Set("Accept", tt.accept)
+			}
+			w := serve(h, r)
+			if tt.want == "" {
+				if w.Code != http.StatusFound || w.Header().Get("Location") != "/" {
+					t.Errorf("status %d to %q, want a redirect to /", w.Code, w.Header().Get("Location"))
+				}
+				return
+			}
+			if w.Code != http.StatusOK || w.Header().Get("Content-Type") != "application/json" {
+				t.Fatalf("status %d, Content-Type %q; want JSON", w.Code, w.Header().Get("Content-Type"))
+			}
+			if got := strings.TrimSpace(w.Body.String()); got != tt.want {
+				t.Errorf("body %s, want %s", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestControlErrors(t *testing.T) {
+	tests := []struct {
+		name   string
+		method string
+		target string
+		status int
+	}{
+		{"unknown action", http.MethodPost, "/control?action=jump", http.StatusBadRequest},
+		{"missing action", http.MethodPost, "/control", http.StatusBadRequest},
+		{"empty action", http.MethodPost, "/control?action=", http.StatusBadRequest},
+		{"wrong method", http.MethodDelete, "/control?action=run", http.StatusMethodNotAllowed},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			computer := newTestComputer()
+			w := serve(newTestServer(computer).Handler(), httptest.NewRequest(tt.method, tt.target, nil))
+			if w.Code != tt.status {
+				t.Fatalf("status %d, want %d", w.Code, tt.status)
+			}
+			if tt.status == http.StatusBadRequest {
+				for _, action := range controlActions {
+					if !strings.Contains(w.Body.String(), action) {
+						t.Errorf("error %q does not list action %q", w.Body, action)
+					}
+				}
+			} else if allow := w.Header().Get("Allow"); allow != "GET, POST" {
+				t.Errorf("Allow = %q, want %q", allow, "GET, POST")
+			}
+			if computer.IsRunning() {
+				t.Error("rejected request started the machine")
+			}
+		})
+	}
+}
+
+func TestLoaded(t *testing.T) {
+	const name = "web-test-loaded.bin"
+	if err := disk.WriteFile("disk/bin/"+name, []byte{0xF0, 0}); err != nil {
+		t.Fatal(err)
+	}
+	computer := newTestComputer()
+	h := newTestServer(computer).Handler()
+	loaded := func() string {
+		t.Helper()
+		w := serve(h, httptest.NewRequest(http.MethodGet, "/loaded", nil))
+		var body struct{ Program string }
+		if err := json.Unmarshal(w.Body.Bytes(), &body); err != nil {
+			t.Fatalf("decoding /loaded: %v", err)
+		}
+		return body.Program
+	}
+
+	if got := loaded(); got != "" {
+		t.Errorf("before loading, program %q, want none", got)
+	}
+	if code := do(h, http.MethodPost, "/load?program="+name, "", "192.0.2.1:1234"); code >= http.StatusBadRequest {
+		t.Fatalf("load: status %d", code)
+	}
+	if got := loaded(); got != name {
+		t.Errorf("after loading, program %q, want %q", got, name)
+	}
+	if got := computer.GetState()["program"]; got != name {
+		t.Errorf("state program %q, want %q", got, name)
+	}
+	if code := do(h, http.MethodPost, "/control?action=reset", "", "192.0.2.1:1234"); code >= http.StatusBadRequest {
+		t.Fatalf("reset: status %d", code)
+	}
+	if got := loaded(); got != "" {
+		t.Errorf("after reset, program %q, want none", got)
+	}
+}
+
+func TestBreakpoints(t *testing.T) {
+	computer := newTestComputer()
+	h := newTestServer(computer).Handler()
+
+	// Each request sees the breakpoints the ones before it left.
+	steps := []struct {
+		method string
+		target string
+		status int
+		want   string // the breakpoints listed afterwards
+	}{
+		{http.MethodGet, "/breakpoints", http.StatusOK, "[]"},
+		{http.MethodPost, "/breakpoints?address=4", http.StatusOK, "[4]"},
+		{http.MethodPost, "/breakpoints?address=0x2", http.StatusOK, "[2,4]"},
+		{http.MethodPost, "/breakpoints?address=8&condition=R1==3", http.StatusOK, "[2,4,8]"},
+		{http.MethodGet, "/breakpoints", http.StatusOK, "[2,4,8]"},
+		{http.MethodPost, "/breakpoints?address=0x10", http.StatusBadRequest, "[2,4,8]"},
+		{http.MethodPost, "/breakpoints", http.StatusBadRequest, "[2,4,8]"},
+		{http.MethodPost, "/breakpoints?address=6&condition=R1", http.StatusBadRequest, "[2,4,8]"},
+		{http.MethodDelete, "/breakpoints?address=4", http.StatusOK, "[2,8]"},
+		{http.MethodDelete, "/breakpoints", http.StatusOK, "[]"},
+		{http.MethodPut, "/breakpoints?address=4", http.StatusMethodNotAllowed, "[]"},
+	}
+	for _, step := range steps {
+		w := serve(h, httptest.NewRequest(step.method, step.target, nil))
+		if w.Code != step.status {
+			t.Fatalf("%s %s: status %d, want %d: %s", step.method, step.target, w.Code, step.status, w.Body)
+		}
+		got, err := json.Marshal(computer.Breakpoints())
+		if err != nil {
+			t.Fatal(err)
+		}
+		if string(got) != step.want {
+			t.Errorf("after %s %s: breakpoints %s, want %s", step.method, step.target, got, step.want)
+		}
+		if w.Code == http.StatusOK && strings.TrimSpace(w.Body.String()) != step.want {
+			t.Errorf("%s %s listed %s, want %s", step.method, step.target, w.Body, step.want)
+		}
+	}
+	if bps, _ := json.Marshal(computer.GetState()["breakpoints"]); string(bps) != "[]" {
+		t.Errorf("state breakpoints %s, want []", bps)
+	}
+}
+
+func TestSearch(t *testing.T) {
+	computer := newTestComputer()
+	if err := computer.WriteMemory(4, []byte{0xBE, 0xEF}); err != nil {
+		t.Fatal(err)
+	}
+	if err := computer.WriteMemory(9, []byte{0xEF}); err != nil {
+		t.Fatal(err)
+	}
+	h := newTestServer(computer).Handler()
+
+	tests := []struct {
+		name   string
+		query  string
+		status int
+		want   string
+	}{
+		{"word", "value=0xBEEF&size=word", http.StatusOK, `{"addresses":[4],"truncated":false}`},
+		{"byte", "value=0xEF", http.StatusOK, `{"addresses":[5,9],"truncated":false}`},
+		{"pattern", "pattern=beef", http.StatusOK, `{"addresses":[4],"truncated":false}`},
+		{"absent", "value=0x1234&size=word", http.StatusOK, `{"addresses":[],"truncated":false}`},
+		{"value too large for a byte", "value=0x100", http.StatusBadRequest, ""},
+		{"unknown size", "value=1&size=long", http.StatusBadRequest, ""},
+		{"bad pattern", "pattern=xyz", http.StatusBadRequest, ""},
+		{"no value", "", http.StatusBadRequest, ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := serve(h, httptest.NewRequest(http.MethodGet, "/search?"+tt.query, nil))
+			if w.Code != tt.status {
+				t.Fatalf("status %d, want %d: %s", w.Code, tt.status, w.Body)
+			}
+			if got := strings.TrimSpace(w.Body.String()); tt.want != "" && got != tt.want {
+				t.Errorf("body %s, want %s", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestWebSocketKeepalive(t *testing.T) {
+	computer := newTestComputer()
+	s := newTestServer(computer)
+	s.pongWait = 200 * time.Millisecond
+	srv := httptest.NewServer(s.Handler())
+	defer srv.Close()
+
+	// Reading is what answers the server's pings, so the live client
+	// reads and the dead one does not.
+	live := dial(t, srv, "/ws")
+	go func() {
+		for {
+			live.SetReadDeadline(time.Now().Add(5 * time.Second))
+			if _, _, err := live.ReadMessage(); err != nil {
+				return
+			}
+		}
+	}()
+	dial(t, srv, "/ws")
+
+	waitObservers(t, computer, 2)
+	waitObservers(t, computer, 1) // the dead client is dropped
+	// The live client outlasts several timeouts.
+	time.Sleep(3 * s.pongWait)
+	if n := computer.ObserverCount(); n != 1 {
+		t.Errorf("%d observers, want the live client to remain", n)
+	}
+}
+
+func TestHealth(t *testing.T) {
+	tests := []struct {
+		name     string
+		tick     bool // whether the emulator's clock has ticked
+		emulator bool
+	}{
+		{"execution loop not started", false, false},
+		{"execution loop ticking", true, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ticks := make(chan time.Time)
+			computer := newTestComputer(emulator.WithClock(ticks))
+			done := make(chan struct{})
+			go func() {
+				computer.Run()
+				close(done)
+			}()
+			defer func() {
+				close(ticks)
+				<-done
+			}()
+			if tt.tick {
+				ticks <- time.Now()
+				ticks <- time.Now() // the first tick has been recorded once the second is taken
+			}
+			// Auth must not apply to the health check.
+			w := serve(newTestServer(computer, WithBasicAuth("admin", "secret")).Handler(), httptest.NewRequest(http.MethodGet, "/healthz", nil))
+			if w.Code != http.StatusOK {
+				t.Fatalf("status %d, want %d", w.Code, http.StatusOK)
+			}
+			var health struct {
+				Status    string `json:"status"`
+				Uptime    string `json:"uptime"`
+				Emulator  bool   `json:"emulator"`
+				Observers *int   `json:"observers"`
+			}
+			if err := json.Unmarshal(w.Body.Bytes(), &health); err != nil {
+				t.Fatalf("decoding %s: %v", w.Body, err)
+			}
+			if health.Status != "ok" || health.Uptime == "" || health.Observers == nil || *health.Observers != 0 {
+				t.Errorf("health = %s, want status ok, an uptime and 0 observers", w.Body)
+			}
+			if health.Emulator != tt.emulator {
+				t.Errorf("emulator = %v, want %v", health.Emulator, tt.emulator)
+			}
+		})
+	}
+}
+
+func TestIndexPrograms(t *testing.T) {
+	tests := []struct {
+		name     string
+		programs []string
+		err      error
+		status   int
+		want     string
+	}{
+		{"programs", []string{"hello", "snake"}, nil, http.StatusOK, `<a href="/load?program=snake">snake</a>`},
+		{"empty directory", []string{}, nil, http.StatusOK, "No programs available."},
+		{"missing directory", nil, fs.ErrNotExist, http.StatusOK, "No programs available."},
+		{"disk error", nil, fs.ErrPermission, http.StatusInternalServerError, "could not read programs directory"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := newTestServer(newTestComputer())
+			s.readDir = func(dir string) ([]string, error) {
+				if dir != "disk/bin" {
+					t.Errorf("listed %q, want disk/bin", dir)
+				}
+				return tt.programs, tt.err
+			}
+			w := serve(s.Handler(), httptest.NewRequest(http.MethodGet, "/", nil))
+			if w.Code != tt.status {
+				t.Fatalf("status %d, want %d", w.Code, tt.status)
+			}
+			if !strings.Contains(w.Body.String(), tt.want) {
+				t.Errorf("page does not contain %q", tt.want)
+			}
+		})
+	}
+}
+
+func TestControlStepBack(t *testing.T) {
+	ticks := make(chan time.Time)
+	computer := newTestComputer(emulator.WithHistory(8), emulator.WithClock(ticks))
+	// ADDI R1, 1 three times, then HALT.
+	if err := computer.LoadProgram([]byte{0x91, 0x01, 0x91, 0x01, 0x91, 0x01, 0xF0, 0x00}, 0); err != nil {
+		t.Fatal(err)
+	}
+	h := newTestServer(computer).Handler()
+	control := func(action string) (int, map[string]json.RawMessage) {
+		t.Helper()
+		r := httptest.NewRequest(http.MethodPost, "/control?action="+action, nil)
+		r.Header.Set("Accept", "application/json")
+		w := serve(h, r)
+		var state map[string]json.RawMessage
+		if w.Code == http.StatusOK {
+			if err := json.Unmarshal(w.Body.Bytes(), &state); err != nil {
+				t.Fatalf("%s: decoding state: %v", action, err)
+			}
+		}
+		return w.Code, state
+	}
+
+	control("step")
+	control("step")
+	if err := computer.AddBreakpoint(2); err != nil {
+		t.Fatal(err)
+	}
+	code, state := control("stepback")
+	if code != http.StatusOK {
+		t.Fatalf("stepback: status %d", code)
+	}
+	var registers [16]uint16
+	json.Unmarshal(state["registers"], &registers)
+	if pc := string(state["pc"]); pc != "2" || registers[1] != 1 {
+		t.Errorf("after stepping back, pc %s and R1 %d; want 2, 1", pc, registers[1])
+	}
+
+	// Running on from the breakpoint stepped back onto does not stop at it.
+	control("run")
+	done := make(chan struct{})
+	go func() {
+		computer.Run()
+		close(done)
+	}()
+	ticks <- time.Now()
+	close(ticks)
+	<-done
+	if st := computer.GetState(); st["pc"] != uint16(4) || st["haltReason"] == emulator.HaltBreakpoint {
+		t.Errorf("after running one tick, pc %v and halt reason %q; want to have run past the breakpoint", st["pc"], st["haltReason"])
+	}
+
+	for range 2 {
+		if code, _ := control("stepback"); code != http.StatusOK {
+			t.Fatalf("stepback: status %d", code)
+		}
+	}
+	if code, _ := control("stepback"); code != http.StatusConflict {
+		t.Errorf("stepback with no history left: status %d, want %d", code, http.StatusConflict)
+	}
+}
+
+func TestDisasm(t *testing.T) {
+	files := map[string]string{
+		"web-test-disasm.asm": "NOP ; first\nLIW R1, 5\nHALT",
+		"web-test-disasm.bin": "\x00\x00\xB1\x0B\x00\x05\xF0\x00",
+	}
+	for name, data := range files {
+		if err := disk.WriteFile("disk/bin/"+name, []byte(data)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	tests := []struct {
+		name    string
+		program string
+		query   string
+		status  int
+		sources []string // one per line listed; LIW is one line of two words
+	}{
+		{"assembly source", "web-test-disasm.asm", "count=4", http.StatusOK, []string{"NOP ; first", "LIW R1, 5", "HALT"}},
+		{"from start", "web-test-disasm.asm", "start=6&count=2", http.StatusOK, []string{"HALT", ""}},
+		{"clipped to memory", "web-test-disasm.asm", "start=14&count=4", http.StatusOK, []string{""}},
+		{"binary has no source", "web-test-disasm.bin", "count=4", http.StatusOK, []string{"", "", ""}},
+		{"invalid start", "web-test-disasm.asm", "start=x", http.StatusBadRequest, nil},
+		{"invalid count", "web-test-disasm.asm", "count=-1", http.StatusBadRequest, nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			h := newTestServer(newTestComputer()).Handler()
+			if code := do(h, http.MethodPost, "/load?program="+tt.program, "", "192.0.2.1:1234"); code >= http.StatusBadRequest {
+				t.Fatalf("load: status %d", code)
+			}
+			w := serve(h, httptest.NewRequest(http.MethodGet, "/disasm?"+tt.query, nil))
+			if w.Code != tt.status {
+				t.Fatalf("status %d, want %d: %s", w.Code, tt.status, w.Body)
+			}
+			if tt.status != http.StatusOK {
+				return
+			}
+			var lines []struct{ Source string }
+			if err := json.Unmarshal(w.Body.Bytes(), &lines); err != nil {
+				t.Fatalf("decoding /disasm: %v", err)
+			}
+			var sources []string
+			for _, line := range lines {
+				sources = append(sources, line.Source)
+			}
+			if !slices.Equal(sources, tt.sources) {
+				t.Errorf("sources %q, want %q", sources, tt.sources)
+			}
+		})
+	}
+}
+
+func TestCallStack(t *testing.T) {
+	h := newTestServer(newTestComputer()).Handler()
+	w := serve(h, httptest.NewRequest(http.MethodGet, "/callstack", nil))
+	if w.Code != http.StatusOK {
+		t.Fatalf("status %d: %s", w.Code, w.Body)
+	}
+	want := fmt.Sprintf(`[{"pc":0,"fp":%d}]`, emulator.MemorySize-emulator.WordSize)
+	if got := strings.TrimSpace(w.Body.String()); got != want {
+		t.Errorf("body %s, want %s", got, want)
+	}
+}
+
+func TestControlRestart(t *testing.T) {
+	computer := newTestComputer()
+	h := newTestServer(computer).Handler()
+	if code := do(h, http.MethodPost, "/control?action=restart", "", "192.0.2.1:1234"); code != http.StatusBadRequest {
+		t.Errorf("restart with nothing loaded: status %d, want %d", code, http.StatusBadRequest)
+	}
+
+	// ADDI R1, 1 twice, then HALT, entered at 2.
+	code := []byte{0x91, 0x01, 0x91, 0x01, 0xF0, 0x00}
+	if err := computer.LoadProgramAt(code, 0, 2); err != nil {
+		t.Fatal(err)
+	}
+	computer.RunToCompletion()
+	if code := do(h, http.MethodPost, "/control?action=restart", "", "192.0.2.1:1234"); code >= http.StatusBadRequest {
+		t.Fatalf("restart: status %d", code)
+	}
+	state := computer.GetState()
+	if state["pc"] != uint16(2) || state["registers"].([16]uint16)[1] != 0 {
+		t.Errorf("after restart, pc %v and R1 %d; want 2 and 0", state["pc"], state["registers"].([16]uint16)[1])
+	}
+	if got, _ := computer.ReadMemory(0, len(code)); !bytes.Equal(got, code) {
+		t.Errorf("program = % X, want % X", got, code)
+	}
+}
+
+func TestISA(t *testing.T) {
+	w := serve(newTestServer(newTestComputer()).Handler(), httptest.NewRequest(http.MethodGet, "/isa", nil))
+	if w.Code != http.StatusOK {
+		t.Fatalf("status %d: %s", w.Code, w.Body)
+	}
+	var specs []struct {
+		Mnemonic string
+		Summary  string
+	}
+	if err := json.Unmarshal(w.Body.Bytes(), &specs); err != nil {
+		t.Fatalf("decoding /isa: %v", err)
+	}
+	listed := make(map[string]bool)
+	for _, spec := range specs {
+		listed[spec.Mnemonic] = spec.Summary != ""
+	}
+	for op := range emulator.Opcode(16) {
+		if op.Valid() && op != emulator.OpEXT && !listed[op.String()] {
+			t.Errorf("opcode %s not listed with a summary", op)
+		}
+	}
+	for ext := range emulator.ExtOp(16) {
+		if ext.Valid() && !listed[ext.String()] {
+			t.Errorf("extended operation %s not listed with a summary", ext)
+		}
+	}
+}
+
+func TestCapabilities(t *testing.T) {
+	w := serve(newTestServer(newTestComputer()).Handler(), httptest.NewRequest(http.MethodGet, "/capabilities", nil))
+	if w.Code != http.StatusOK {
+		t.Fatalf("status %d: %s", w.Code, w.Body)
+	}
+	var caps struct {
+		Instructions []string
+		Features     map[string]bool
+	}
+	if err := json.Unmarshal(w.Body.Bytes(), &caps); err != nil {
+		t.Fatalf("decoding /capabilities: %v", err)
+	}
+	var want []string
+	for _, spec := range emulator.ISA() {
+		want = append(want, spec.Mnemonic)
+	}
+	if !slices.Equal(caps.Instructions, want) {
+		t.Errorf("instructions %v, want %v", caps.Instructions, want)
+	}
+	for feature, on := range map[string]bool{"interrupts": false, "mmio": false, "blockDevice": false, "turbo": true, "stepBack": false} {
+		if got, ok := caps.Features[feature]; !ok || got != on {
+			t.Errorf("feature %s = %v (listed %v), want %v", feature, got, ok, on)
+		}
+	}
+}
+
+func TestWebSocketMessageTypes(t *testing.T) {
+	computer := newTestComputer()
+	// LIW R1, 'A'; OUT R1; LW R2, 15 (past the end of memory); HALT
+	if err := computer.LoadProgram([]byte{0xB1, 0x0B, 0x00, 'A', 0xB1, 0x02, 0xC2, 0x0F, 0xF0, 0x00}, 0); err != nil {
+		t.Fatal(err)
+	}
+	srv := httptest.NewServer(newTestServer(computer).Handler())
+	defer srv.Close()
+	conn := dial(t, srv, "/ws")
+	waitObservers(t, computer, 1)
+
+	steps := []struct {
+		name     string
+		types    []string
+		payloads []string // checked where not empty
+	}{
+		{"LIW", []string{MessageState}, nil},
+		{"OUT", []string{MessageOutput, MessageState}, []string{`"A"`}},
+		{"faulting LW", []string{MessageFault, MessageState}, []string{`"reason":"memory-fault"`}},
+	}
+	for _, step := range steps {
+		computer.StepState()
+		for i, want := range step.types {
+			typ, payload := readMessage(t, conn)
+			if typ != want {
+				t.Fatalf("%s: message %d is %q, want %q", step.name, i, typ, want)
+			}
+			if i < len(step.payloads) && !strings.Contains(string(payload), step.payloads[i]) {
+				t.Errorf("%s: %s payload %s, want it to contain %s", step.name, typ, payload, step.payloads[i])
+			}
+			if typ == MessageState && !strings.Contains(string(payload), `"registers"`) {
+				t.Errorf("%s: state payload %s has no registers", step.name, payload)
+			}
+		}
+	}
+}
+
+func TestFlags(t *testing.T) {
+	computer := newTestComputer()
+	h := newTestServer(computer).Handler()
+
+	// Each request sees the flags the ones before it left.
+	steps := []struct {
+		method string
+		query  string
+		status int
+		sr     uint16 // afterwards
+	}{
+		{http.MethodGet, "", http.StatusOK, 0},
+		{http.MethodPost, "carry=true", http.StatusOK, emulator.FlagCarry},
+		{http.MethodPost, "zero=1&negative=true", http.StatusOK, emulator.FlagCarry | emulator.FlagZero | emulator.FlagNegative},
+		{http.MethodPost, "carry=false", http.StatusOK, emulator.FlagZero | emulator.FlagNegative},
+		{http.MethodGet, "carry=true", http.StatusOK, emulator.FlagZero | emulator.FlagNegative},
+		{http.MethodPost, "zero=false&sign=true", http.StatusBadRequest, emulator.FlagZero | emulator.FlagNegative},
+		{http.MethodPost, "zero=false&carry=maybe", http.StatusBadRequest, emulator.FlagZero | emulator.FlagNegative},
+		{http.MethodDelete, "", http.StatusMethodNotAllowed, emulator.FlagZero | emulator.FlagNegative},
+	}
+	for _, step := range steps {
+		w := serve(h, httptest.NewRequest(step.method, "/flags?"+step.query, nil))
+		if w.Code != step.status {
+			t.Fatalf("%s /flags?%s: status %d, want %d: %s", step.method, step.query, w.Code, step.status, w.Body)
+		}
+		if sr := computer.GetState()["registers"].([16]uint16)[15]; sr != step.sr {
+			t.Errorf("after %s /flags?%s: SR 0x%04X, want 0x%04X", step.method, step.query, sr, step.sr)
+		}
+		if w.Code != http.StatusOK {
+			continue
+		}
+		var flags emulator.Flags
+		if err := json.Unmarshal(w.Body.Bytes(), &flags); err != nil {
+			t.Fatalf("decoding /flags: %v", err)
+		}
+		if flags != emulator.DecodeFlags(step.sr) {
+			t.Errorf("%s /flags?%s = %+v, want %+v", step.method, step.query, flags, emulator.DecodeFlags(step.sr))
+		}
+	}
+}
+
+func TestDumpCArray(t *testing.T) {
+	computer := newTestComputer()
+	if err := computer.LoadProgramAt([]byte{0xB1, 0x10, 0xF0, 0x00, 0xDE, 0xAD}, 0, 2); err != nil {
+		t.Fatal(err)
+	}
+	h := newTestServer(computer).Handler()
+
+	tests := []struct {
+		name   string
+		query  string
+		status int
+		want   string
+	}{
+		{"range", "start=2&length=4&name=code", http.StatusOK, "" +
+			"/* MTMC program: 4 bytes loaded at 0x0002, entry point 0x0002 */\n" +
+			"const unsigned char code[] = {\n" +
+			"    0xF0, 0x00, 0xDE, 0xAD,\n" +
+			"};\n" +
+			"const unsigned int code_len = 4;\n"},
+		{"default name", "length=2", http.StatusOK, "" +
+			"/* MTMC program: 2 bytes loaded at 0x0000, entry point 0x0002 */\n" +
+			"const unsigned char prog[] = {\n" +
+			"    0xB1, 0x10,\n" +
+			"};\n" +
+			"const unsigned int prog_len = 2;\n"},
+		{"bad name", "name=my-prog", http.StatusBadRequest, ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := serve(h, httptest.NewRequest(http.MethodGet, "/dump?format=c&"+tt.query, nil))
+			if w.Code != tt.status {
+				t.Fatalf("status %d, want %d: %s", w.Code, tt.status, w.Body)
+			}
+			if tt.status != http.StatusOK {
+				return
+			}
+			if got := w.Body.String(); got != tt.want {
+				t.Errorf("body:\n%s\nwant:\n%s", got, tt.want)
+			}
+			if ct := w.Header().Get("Content-Type"); !strings.HasPrefix(ct, "text/x-c") {
+				t.Errorf("Content-Type = %q, want text/x-c", ct)
+			}
+		})
+	}
+}
+
+func TestAnnotations(t *testing.T) {
+	computer := newTestComputer()
+	h := newTestServer(computer).Handler()
+
+	// Each request sees the annotations the ones before it left.
+	steps := []struct {
+		method string
+		target string
+		status int
+		want   string // the annotations listed afterwards
+	}{
+		{http.MethodGet, "/annotations", http.StatusOK, `{}`},
+		{http.MethodPost, "/annotations?address=4&note=loop", http.StatusOK, `{"4":"loop"}`},
+		{http.MethodPost, "/annotations?address=0xA&note=counter", http.StatusOK, `{"10":"counter","4":"loop"}`},
+		{http.MethodPost, "/annotations?address=4&note=top", http.StatusOK, `{"10":"counter","4":"top"}`},
+		{http.MethodPost, "/annotations?address=4", http.StatusBadRequest, `{"10":"counter","4":"top"}`},
+		{http.MethodPost, "/annotations?address=0x10000&note=x", http.StatusBadRequest, `{"10":"counter","4":"top"}`},
+		{http.MethodDelete, "/annotations?address=10", http.StatusOK, `{"4":"top"}`},
+		{http.MethodDelete, "/annotations", http.StatusBadRequest, `{"4":"top"}`},
+		{http.MethodPut, "/annotations?address=4&note=x", http.StatusMethodNotAllowed, `{"4":"top"}`},
+	}
+	for _, step := range steps {
+		w := serve(h, httptest.NewRequest(step.method, step.target, nil))
+		if w.Code != step.status {
+			t.Fatalf("%s %s: status %d, want %d: %s", step.method, step.target, w.Code, step.status, w.Body)
+		}
+		got, err := json.Marshal(computer.Annotations(0, 65536))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if string(got) != step.want {
+			t.Errorf("after %s %s: annotations %s, want %s", step.method, step.target, got, step.want)
+		}
+		if w.Code == http.StatusOK && strings.TrimSpace(w.Body.String()) != step.want {
+			t.Errorf("%s %s listed %s, want %s", step.method, step.target, w.Body, step.want)
+		}
+	}
+
+	// The views show the annotations of their range, until a reset.
+	views := []struct {
+		target      string
+		want, reset string
+	}{
+		{"/memory?address=4&length=2", `{"4":"top"}`, `{}`},
+		{"/memory?address=6&length=2", `{}`, `{}`},
+		{"/disasm?start=4&count=1", `[{"address":4,"annotation":"top"}]`, `[{"address":4}]`},
+	}
+	for _, reset := range []bool{false, true} {
+		if reset {
+			if code := do(h, http.MethodPost, "/control?action=reset", "", "192.0.2.1:1234"); code >= http.StatusBadRequest {
+				t.Fatalf("reset: status %d", code)
+			}
+		}
+		for _, view := range views {
+			w := serve(h, httptest.NewRequest(http.MethodGet, view.target, nil))
+			if w.Code != http.StatusOK {
+				t.Fatalf("GET %s: status %d: %s", view.target, w.Code, w.Body)
+			}
+			var got []byte
+			if strings.HasPrefix(view.target, "/memory") {
+				var body struct{ Annotations json.RawMessage }
+				if err := json.Unmarshal(w.Body.Bytes(), &body); err != nil {
+					t.Fatalf("decoding %s: %v", view.target, err)
+				}
+				got = body.Annotations
+			} else {
+				var lines []struct {
+					Address    uint16 `json:"address"`
+					Annotation string `json:"annotation,omitempty"`
+				}
+				if err := json.Unmarshal(w.Body.Bytes(), &lines); err != nil {
+					t.Fatalf("decoding %s: %v", view.target, err)
+				}
+				got, _ = json.Marshal(lines)
+			}
+			want := view.want
+			if reset {
+				want = view.reset
+			}
+			if string(got) != want {
+				t.Errorf("GET %s (reset %v) shows %s, want %s", view.target, reset, got, want)
+			}
+		}
+	}
+}
+
+func TestControlSpeed(t *testing.T) {
+	computer := newTestComputer()
+	h := newTestServer(computer).Handler()
+
+	// Each request sees the budget the ones before it left.
+	steps := []struct {
+		query  string
+		status int
+		want   int // instructions per tick afterwards
+	}{
+		{"steps=8", http.StatusFound, 8},
+		{"steps=1", http.StatusFound, 1},
+		{"steps=0", http.StatusBadRequest, 1},
+		{"steps=-2", http.StatusBadRequest, 1},
+		{"steps=65536", http.StatusBadRequest, 1},
+		{"", http.StatusBadRequest, 1},
+		{"steps=100", http.StatusFound, 100},
+	}
+	for _, step := range steps {
+		if code := do(h, http.MethodPost, "/control?action=speed&"+step.query, "", "192.0.2.1:1234"); code != step.status {
+			t.Errorf("speed %q: status %d, want %d", step.query, code, step.status)
+		}
+		if got := computer.StepsPerTick(); got != step.want {
+			t.Errorf("after speed %q: %d instructions per tick, want %d", step.query, got, step.want)
+		}
+	}
+}
+
+func TestSnapshotDiff(t *testing.T) {
+	computer := newTestComputer()
+	// LIW R1, 0xBEEF; SW R1, 4, which overwrites the SW itself
+	if err := computer.LoadProgram([]byte{0xB1, 0x0B, 0xBE, 0xEF, 0xD1, 0x04}, 0); err != nil {
+		t.Fatal(err)
+	}
+	before, err := json.Marshal(computer.Snapshot())
+	if err != nil {
+		t.Fatal(err)
+	}
+	computer.StepState()
+	computer.StepState()
+	after, err := json.Marshal(computer.Snapshot())
+	if err != nil {
+		t.Fatal(err)
+	}
+	h := newTestServer(computer).Handler()
+
+	tests := []struct {
+		name   string
+		method string
+		body   string
+		status int
+		want   string // in the response body
+	}{
+		{"diff", http.MethodPost, fmt.Sprintf(`{"a":%s,"b":%s}`, before, after), http.StatusOK,
+			`"registers":[{"register":"R1","index":1,"old":0,"new":48879},{"register":"PC","index":14,"old":0,"new":6}]`},
+		{"memory", http.MethodPost, fmt.Sprintf(`{"a":%s,"b":%s}`, before, after), http.StatusOK,
+			`"memory":[{"start":4,"old":"D104","new":"BEEF"}],"steps":2`},
+		{"missing b", http.MethodPost, fmt.Sprintf(`{"a":%s}`, before), http.StatusBadRequest, "required"},
+		{"invalid body", http.MethodPost, "{", http.StatusBadRequest, "invalid request body"},
+		{"tampered", http.MethodPost, fmt.Sprintf(`{"a":%s,"b":%s}`, before, bytes.Replace(after, []byte(`"checksum":`), []byte(`"checksum":1`), 1)),
+			http.StatusBadRequest, "second snapshot"},
+		{"wrong method", http.MethodGet, "", http.StatusMethodNotAllowed, ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := serve(h, httptest.NewRequest(tt.method, "/snapshot/diff", strings.NewReader(tt.body)))
+			if w.Code != tt.status {
+				t.Fatalf("status %d, want %d: %s", w.Code, tt.status, w.Body)
+			}
+			if !strings.Contains(w.Body.String(), tt.want) {
+				t.Errorf("body %s, want it to contain %s", w.Body, tt.want)
+			}
+		})
+	}
+}
+
+// TestWebSocketConsole checks the state messages carry what the console
+// and memory panes render: the console text, and memory as a byte slice,
+// which JSON encodes in base64.
+func TestWebSocketConsole(t *testing.T) {
+	computer := newTestComputer(emulator.WithConsole(8))
+	// LIW R1, 'h'; SW R1, R0, 8, the console's data port; HALT
+	if err := computer.LoadProgram([]byte{0xB1, 0x0B, 0x00, 'h', 0xD1, 0x08, 0xF0, 0x00}, 0); err != nil {
+		t.Fatal(err)
+	}
+	srv := httptest.NewServer(newTestServer(computer).Handler())
+	defer srv.Close()
+	conn := dial(t, srv, "/ws")
+	waitObservers(t, computer, 1)
+
+	for _, want := range []string{"", "h", "h"} {
+		computer.StepState()
+		typ, payload := readMessage(t, conn)
+		if typ != MessageState {
+			t.Fatalf("message %q, want %q", typ, MessageState)
+		}
+		var state struct {
+			Console string
+			Memory  json.RawMessage
+		}
+		if err := json.Unmarshal(payload, &state); err != nil {
+			t.Fatal(err)
+		}
+		if state.Console != want {
+			t.Errorf("console %q, want %q", state.Console, want)
+		}
+		var memory []byte
+		if err := json.Unmarshal(state.Memory, &memory); err != nil || !strings.HasPrefix(string(state.Memory), `"`) {
+			t.Fatalf("memory %s is not a base64 string: %v", state.Memory, err)
+		}
+		if mem, _ := computer.ReadMemory(0, emulator.MemorySize); !bytes.Equal(memory, mem) {
+			t.Errorf("memory % X, want % X", memory, mem)
+		}
+	}
+}
@@ -1,22 +1,35 @@
 const socket = new WebSocket("ws://" + location.host + "/ws");
 
 socket.onmessage = function(event) {
-    const state = JSON.parse(event.data);
-    updateUI(state);
+    const message = JSON.parse(event.data);
+    if (message.type === "state") {
+        updateUI(message.payload);
+    }
 };
 
 function updateUI(state) {
     const registersView = document.getElementById("registers-view");
     let regHTML = "";
-    for (let i = 0; i < state.registers.length; i++) {
-        regHTML += `R${i}: ${state.registers[i]}\n`;
+    for (const reg of state.registerFile) {
+        regHTML += `${reg.name}: ${reg.value} (${reg.role})\n`;
     }
     registersView.textContent = regHTML;
 
     const memoryView = document.getElementById("memory-view");
-    memoryView.textContent = state.memory.join(" ");
+    memoryView.textContent = decodeBytes(state.memory).join(" ");
+
+    const consoleView = document.getElementById("console-view");
+    consoleView.textContent = state.console;
+    consoleView.scrollTop = consoleView.scrollHeight;
 
+    document.getElementById("program-view").textContent = state.program;
     document.getElementById("pc-view").textContent = state.pc;
+    document.getElementById("instruction-view").textContent = state.instruction;
     document.getElementById("running-view").textContent = state.running;
 }
 
+// decodeBytes turns a byte slice from the state, which JSON carries as a
+// base64 string, into an array of numbers.
+function decodeBytes(encoded) {
+    return Array.from(atob(encoded || ""), c => c.charCodeAt(0));
+}
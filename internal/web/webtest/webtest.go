@@ -0,0 +1,42 @@
+// Package webtest wires an emulator and web server together for end-to-end
+// tests of the HTTP and WebSocket API.
+package webtest
+
+import (
+	"github.com/catdevman/go-mtmc/internal/emulator"
+	"github.com/catdevman/go-mtmc/internal/web"
+	"io"
+	"log/slog"
+)
+
+// Instance is a server listening on a free local port, in front of a
+// machine whose clock is not running. Nothing executes unless the test
+// steps it, through the API or Computer directly, so runs are
+// deterministic.
+type Instance struct {
+	Server   *web.Server
+	Computer *emulator.MonTanaMiniComputer
+	// URL is the server's base URL, such as "http://127.0.0.1:54321".
+	URL string
+}
+
+// NewTestInstance starts a server for a fresh machine on 127.0.0.1:0 and
+// returns once it accepts connections. Logging is discarded. Server options
+// are applied after the defaults. Call Close when done.
+func NewTestInstance(opts ...web.Option) (*Instance, error) {
+	logger := slog.New(slog.NewTextHandler(io.Discard, nil))
+	computer := emulator.New(emulator.WithLogger(logger))
+	opts = append([]web.Option{web.WithLogger(logger), web.WithAddr("127.0.0.1:0")}, opts...)
+	server := web.NewServer(computer, opts...)
+	addr, err := server.Listen()
+	if err != nil {
+		return nil, err
+	}
+	go server.Serve()
+	return &Instance{Server: server, Computer: computer, URL: "http://" + addr.String()}, nil
+}
+
+// Close shuts the server down.
+func (i *Instance) Close() error {
+	return i.Server.Close()
+}
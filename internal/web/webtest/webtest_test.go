@@ -0,0 +1,120 @@
+package webtest_test
+
+import (
+	"encoding/json"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gorilla/websocket"
+
+	"github.com/catdevman/go-mtmc/internal/disk"
+	"github.com/catdevman/go-mtmc/internal/web"
+	"github.com/catdevman/go-mtmc/internal/web/webtest"
+)
+
+// state is the part of the machine state the test looks at.
+type state struct {
+	PC               uint16     `json:"pc"`
+	Registers        [16]uint16 `json:"registers"`
+	InstructionCount uint64     `json:"instructionCount"`
+	Program          string     `json:"program"`
+}
+
+// TestLoadStepAndReadState drives a machine entirely over HTTP and
+// WebSocket: it loads a program, steps it and reads the state back.
+func TestLoadStepAndReadState(t *testing.T) {
+	const name = "webtest-add.bin"
+	// LIW R1, 5; ADD R2, R1, R1; HALT
+	if err := disk.WriteFile("disk/bin/"+name, []byte{0xB1, 0x0B, 0x00, 0x05, 0x12, 0x11, 0xF0, 0x00}); err != nil {
+		t.Fatal(err)
+	}
+	inst, err := webtest.NewTestInstance()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer inst.Close()
+
+	// request sends a JSON API request and decodes the response into v.
+	request := func(method, path string, v any) {
+		t.Helper()
+		req, err := http.NewRequest(method, inst.URL+path, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		req.Header.Set("Accept", "application/json")
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer resp.Body.Close()
+		if resp.StatusCode != http.StatusOK {
+			t.Fatalf("%s %s: status %d", method, path, resp.StatusCode)
+		}
+		if err := json.NewDecoder(resp.Body).Decode(v); err != nil {
+			t.Fatalf("%s %s: decoding response: %v", method, path, err)
+		}
+	}
+
+	ws, _, err := websocket.DefaultDialer.Dial("ws"+strings.TrimPrefix(inst.URL, "http")+"/ws", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ws.Close()
+	// readMessage returns the next WebSocket message.
+	readMessage := func() (string, json.RawMessage) {
+		t.Helper()
+		ws.SetReadDeadline(time.Now().Add(5 * time.Second))
+		var msg struct {
+			Type    string          `json:"type"`
+			Payload json.RawMessage `json:"payload"`
+		}
+		if err := ws.ReadJSON(&msg); err != nil {
+			t.Fatal(err)
+		}
+		return msg.Type, msg.Payload
+	}
+	if typ, _ := readMessage(); typ != web.MessageAck {
+		t.Fatalf("first message %q, want %q", typ, web.MessageAck)
+	}
+
+	// The acknowledgement can arrive just before the server subscribes the
+	// connection to updates.
+	for deadline := time.Now().Add(5 * time.Second); inst.Computer.ObserverCount() == 0; time.Sleep(10 * time.Millisecond) {
+		if time.Now().After(deadline) {
+			t.Fatal("WebSocket never subscribed")
+		}
+	}
+
+	var loaded map[string]any
+	request(http.MethodPost, "/load?program="+name, &loaded)
+
+	var stepped state
+	request(http.MethodPost, "/control?action=step", &stepped)
+	request(http.MethodPost, "/control?action=step", &stepped)
+	if stepped.PC != 6 || stepped.Registers[2] != 10 || stepped.InstructionCount != 2 {
+		t.Errorf("after two steps: pc %d, R2 %d, %d instructions; want 6, 10, 2", stepped.PC, stepped.Registers[2], stepped.InstructionCount)
+	}
+
+	// Each step was pushed to the WebSocket too.
+	var pushed state
+	for range 2 {
+		typ, payload := readMessage()
+		if typ != web.MessageState {
+			t.Fatalf("message %q, want %q", typ, web.MessageState)
+		}
+		if err := json.Unmarshal(payload, &pushed); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if pushed != stepped {
+		t.Errorf("pushed state %+v, want %+v", pushed, stepped)
+	}
+
+	var polled state
+	request(http.MethodGet, "/state", &polled)
+	if polled != stepped || polled.Program != name {
+		t.Errorf("polled state %+v, want %+v for %s", polled, stepped, name)
+	}
+}